@@ -2,11 +2,18 @@
 // database.
 package backend
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
 // Primitive represents all the data types that the database can store.
 type Primitive string
 
 const (
-	PrimitiveString Primitive = "string" // 256 runes max (1024 bytes)
+	PrimitiveString Primitive = "string" // variable length, see encodeStringCell (16 bytes)
 	PrimitiveInt    Primitive = "int"    // int64 (8 bytes)
 	PrimitiveFloat  Primitive = "float"  // float64 (8 bytes)
 	PrimitiveBool   Primitive = "bool"   // bool (1 byte)
@@ -24,7 +31,7 @@ func (p Primitive) IsValid() bool {
 func (p Primitive) Size() int64 {
 	switch p {
 	case PrimitiveString:
-		return 1024
+		return stringCellSize
 	case PrimitiveInt, PrimitiveFloat:
 		return 8
 	case PrimitiveBool:
@@ -37,21 +44,93 @@ func (p Primitive) Size() int64 {
 // Value is a single cell in a table. It allows type-safe operations between Go primitives and DB primitives (which is
 // represented using the Primitive type).
 //
-// Do not create using struct literal, instead use the NewValue method on the Field type.
+// Do not create using struct literal, instead use the NewValue method on the Field type. A Val of nil is NULL.
 type Value struct {
 	Type      Primitive
 	Val       interface{}
 	FieldName string
 }
 
+// IsNull returns whether the value is NULL, which is a value that does not exist.
+func (v *Value) IsNull() bool {
+	return v.Val == nil
+}
+
 func (v *Value) Bytes() []byte {
 	return anyToB(v.Val)
 }
 
-// Field is essentially a column in a table.
+// Field is essentially a column in a table. MaxLength is the maximum number of characters of a string field, or 0
+// if its length is not limited. Default is the value of the field in the rows that are inserted without one, which
+// is NULL if it is nil.
 type Field struct {
-	Name string
-	Type Primitive
+	Name      string
+	Type      Primitive
+	MaxLength int64
+	Default   interface{}
+}
+
+// fieldJSON is how a Field is stored in the headers of the files. The default is stored as a string, so that it is
+// read back as a value of the type of the field, which a JSON number or a float that is not finite would not be.
+type fieldJSON struct {
+	Name      string
+	Type      Primitive
+	MaxLength int64
+	Default   *string `json:",omitempty"`
+}
+
+func (f Field) MarshalJSON() ([]byte, error) {
+	stored := fieldJSON{Name: f.Name, Type: f.Type, MaxLength: f.MaxLength}
+
+	if f.Default != nil {
+		def, err := convertValue(f.Default, Field{Name: f.Name, Type: PrimitiveString})
+		if err != nil {
+			return nil, err
+		}
+
+		s := def.(string)
+		stored.Default = &s
+	}
+
+	return json.Marshal(stored)
+}
+
+func (f *Field) UnmarshalJSON(data []byte) error {
+	var stored fieldJSON
+
+	err := json.Unmarshal(data, &stored)
+	if err != nil {
+		return err
+	}
+
+	*f = Field{Name: stored.Name, Type: stored.Type, MaxLength: stored.MaxLength}
+
+	if stored.Default != nil {
+		f.Default, err = convertValue(*stored.Default, *f)
+		if err != nil {
+			return fmt.Errorf("could not read the default of field %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateValue returns an error if the value can not be stored in the field.
+func (f Field) validateValue(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return nil
+	}
+
+	if int64(len(s)) > math.MaxUint32 {
+		return fmt.Errorf("value for %s is longer than %d bytes", f.Name, uint32(math.MaxUint32))
+	}
+
+	if f.MaxLength > 0 && int64(utf8.RuneCountInString(s)) > f.MaxLength {
+		return fmt.Errorf("value for %s is longer than %d characters", f.Name, f.MaxLength)
+	}
+
+	return nil
 }
 
 func (t *table) GetName() string {
@@ -66,8 +145,47 @@ func (t *table) GetFields() []Field {
 	return returner
 }
 
+// RowCount returns the number of rows in the table.
+func (t *table) RowCount() int64 {
+	t.mrw.RLock()
+	defer t.mrw.RUnlock()
+
+	return t.rowCount
+}
+
+// Cleanup closes the files of the table. Statements that use the table after it is closed fail.
 func (t *table) Cleanup() error {
-	return t.file.Close()
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	t.failed = errTableClosed
+
+	return t.closeFiles()
+}
+
+// closeFiles closes the files of the table and its indexes. The table must be locked for writing.
+func (t *table) closeFiles() error {
+	t.closed = true
+
+	err := t.file.Close()
+
+	hErr := t.heapFile.Close()
+	if err == nil {
+		err = hErr
+	}
+
+	for _, index := range t.indexes {
+		iErr := index.file.Close()
+		if err == nil {
+			err = iErr
+		}
+	}
+
+	return err
 }
 
 func (t *table) FieldWithName(fieldName string) (Field, error) {
@@ -1,82 +1,162 @@
 package backend
 
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// ternary is the result of a predicate under SQL's three-valued logic, in which a comparison with NULL is neither
+// true nor false but unknown. The values are ordered so that AND is the minimum of its operands and OR the maximum.
+type ternary int8
+
+const (
+	ternaryFalse ternary = iota
+	ternaryUnknown
+	ternaryTrue
+)
+
+func ternaryOf(b bool) ternary {
+	if b {
+		return ternaryTrue
+	}
+
+	return ternaryFalse
+}
+
+func (t ternary) and(other ternary) ternary {
+	if other < t {
+		return other
+	}
+
+	return t
+}
+
+func (t ternary) or(other ternary) ternary {
+	if other > t {
+		return other
+	}
+
+	return t
+}
+
+func (t ternary) not() ternary {
+	return ternaryTrue - t
+}
+
+// compareNull evaluates a comparison filter against a NULL cell. Only IS NULL and IS NOT NULL have a known result.
+func compareNull(filter Filter) ternary {
+	switch filter.Operator {
+	case OperatorIsNull:
+		return ternaryTrue
+	case OperatorIsNotNull:
+		return ternaryFalse
+	}
+
+	return ternaryUnknown
+}
+
+// compareValues compares two encoded values of the given type with the operator. Values are compared in their encoded
+// form, so no Value needs to be allocated to evaluate a filter. Strings are compared as their UTF-8 bytes, which is
+// the same as comparing them by code point.
 func compareValues(v1 []byte, operator Operator, v2 []byte, as Primitive) bool {
-	if len(v1) != len(v2) {
+	if as != PrimitiveString && len(v1) != len(v2) {
 		panic("slice lengths do not match. cannot compare.")
 	}
 
+	// NaN is unordered, it is not equal to anything including itself
+	if as == PrimitiveFloat && (math.IsNaN(bToF64(v1)) || math.IsNaN(bToF64(v2))) {
+		return operator == OperatorNotEqual
+	}
+
+	c := compareCells(v1, v2, as)
+
 	switch operator {
 	case OperatorEqual:
-		return compareEqual(v1, v2, as)
+		return c == 0
 	case OperatorNotEqual:
-		return !compareEqual(v1, v2, as)
+		return c != 0
 	case OperatorLessThan:
-		return compareLessThan(v1, v2, as)
+		return c < 0
 	case OperatorLessThanOrEqual:
-		return compareLessThanOrEqual(v1, v2, as)
+		return c <= 0
 	case OperatorGreaterThan:
-		return compareGreaterThan(v1, v2, as)
+		return c > 0
 	case OperatorGreaterThanOrEqual:
-		return compareGreaterThanOrEqual(v1, v2, as)
+		return c >= 0
 	}
 
 	return false
 }
 
-func compareEqual(v1 []byte, v2 []byte, as Primitive) bool {
-	for i := range v1 {
-		if v1[i] != v2[i] {
-			return false
-		}
+// compareCells returns -1, 0 or 1 depending on whether the encoded cell v1 is less than, equal to or greater than v2.
+// The cell encodings are order preserving, so apart from floats, whose positive and negative zero must compare as
+// equal, the bytes can be compared directly. NaN is ordered after every other float.
+func compareCells(v1 []byte, v2 []byte, as Primitive) int {
+	if as == PrimitiveFloat {
+		return compareFloats(bToF64(v1), bToF64(v2))
 	}
 
-	return true
+	return bytes.Compare(v1, v2)
 }
 
-func compareLessThan(v1 []byte, v2 []byte, as Primitive) bool {
-	for i := range v1 {
-		if v1[i] < v2[i] {
-			return true
-		}
+func compareFloats(f1 float64, f2 float64) int {
+	n1, n2 := math.IsNaN(f1), math.IsNaN(f2)
+
+	switch {
+	case n1 && n2:
+		return 0
+	case n1:
+		return 1
+	case n2:
+		return -1
+	case f1 < f2:
+		return -1
+	case f1 > f2:
+		return 1
 	}
 
-	return false
+	return 0
 }
 
-func compareLessThanOrEqual(v1 []byte, v2 []byte, as Primitive) bool {
-	allEqual := true
-
-	for i := range v1 {
-		if v1[i] < v2[i] {
-			return true
-		}
-
-		allEqual = allEqual && v1[i] == v2[i]
+// Compare returns -1, 0 or 1 depending on whether v1 is less than, equal to or greater than v2 when ordered as the
+// primitive type. Both values must be of the Go type of the primitive or nil. Ints are ordered as signed integers,
+// floats numerically with NaN after every other float, false before true, and strings by Unicode code point. nil,
+// which is a value that does not exist, is ordered after every other value.
+func (p Primitive) Compare(v1 interface{}, v2 interface{}) int {
+	switch {
+	case v1 == nil && v2 == nil:
+		return 0
+	case v1 == nil:
+		return 1
+	case v2 == nil:
+		return -1
 	}
 
-	return allEqual
-}
-
-func compareGreaterThan(v1 []byte, v2 []byte, as Primitive) bool {
-	for i := range v1 {
-		if v1[i] > v2[i] {
-			return true
+	switch p {
+	case PrimitiveString:
+		return strings.Compare(v1.(string), v2.(string))
+	case PrimitiveInt:
+		i1, i2 := v1.(int64), v2.(int64)
+
+		switch {
+		case i1 < i2:
+			return -1
+		case i1 > i2:
+			return 1
 		}
-	}
-
-	return false
-}
-
-func compareGreaterThanOrEqual(v1 []byte, v2 []byte, as Primitive) bool {
-	allEqual := true
-
-	for i := range v1 {
-		if v1[i] > v2[i] {
-			return true
+	case PrimitiveFloat:
+		return compareFloats(v1.(float64), v2.(float64))
+	case PrimitiveBool:
+		b1, b2 := v1.(bool), v2.(bool)
+
+		switch {
+		case !b1 && b2:
+			return -1
+		case b1 && !b2:
+			return 1
 		}
-
-		allEqual = allEqual && v1[i] == v2[i]
 	}
 
-	return allEqual
+	return 0
 }
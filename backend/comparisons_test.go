@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEncodingsPreserveOrder(t *testing.T) {
+	for as, values := range map[Primitive][]interface{}{
+		PrimitiveInt: {int64(math.MinInt64), int64(-1 << 32), int64(-256), int64(-1), int64(0), int64(1), int64(255), int64(256), int64(math.MaxInt64)},
+		PrimitiveFloat: {math.Inf(-1), -math.MaxFloat64, -1.5, -1.0, -math.SmallestNonzeroFloat64, 0.0, math.SmallestNonzeroFloat64, 0.1, 1.0,
+			1.5, float64(1 << 53), math.MaxFloat64, math.Inf(1)},
+		PrimitiveString: {"", "A", "Z", "a", "ab", "b", "é", "中", "😀"},
+		PrimitiveBool:   {false, true},
+	} {
+		for i, val := range values {
+			b := anyToB(val)
+
+			if decoded := bToAny(b, as); decoded != val {
+				t.Errorf("%s %v was decoded as %v", as, val, decoded)
+			}
+
+			for j := i + 1; j < len(values); j++ {
+				if bytes.Compare(b, anyToB(values[j])) >= 0 {
+					t.Errorf("%s %v is not encoded before %v", as, val, values[j])
+				}
+
+				if c := as.Compare(val, values[j]); c != -1 {
+					t.Errorf("%s %v compared to %v is %d, want -1", as, val, values[j], c)
+				}
+
+				if c := compareCells(b, anyToB(values[j]), as); c != -1 {
+					t.Errorf("the cell of %s %v compared to %v is %d, want -1", as, val, values[j], c)
+				}
+			}
+		}
+	}
+}
+
+func TestFloatZerosAndNaN(t *testing.T) {
+	negativeZero, nan := math.Copysign(0, -1), math.NaN()
+	otherNaN := math.Float64frombits(0xfff8000000000001)
+
+	// the sign of zero is kept by the encoding, but both zeros are equal
+	if decoded := bToF64(f64ToB(negativeZero)); decoded != 0 || !math.Signbit(decoded) {
+		t.Errorf("-0 was decoded as %v", decoded)
+	}
+
+	if c := compareCells(f64ToB(negativeZero), f64ToB(0), PrimitiveFloat); c != 0 {
+		t.Errorf("-0 compared to 0 is %d", c)
+	}
+
+	if !compareValues(f64ToB(negativeZero), OperatorEqual, f64ToB(0), PrimitiveFloat) {
+		t.Error("-0 is not equal to 0")
+	}
+
+	// NaN is ordered after every other float, but it is not equal to anything in a filter, including itself
+	for _, f := range []float64{math.Inf(1), math.MaxFloat64, 0, math.Inf(-1)} {
+		if c := compareCells(f64ToB(nan), f64ToB(f), PrimitiveFloat); c != 1 {
+			t.Errorf("NaN compared to %v is %d, want 1", f, c)
+		}
+
+		if c := PrimitiveFloat.Compare(f, otherNaN); c != -1 {
+			t.Errorf("%v compared to a negative NaN is %d, want -1", f, c)
+		}
+	}
+
+	if c := compareCells(f64ToB(nan), f64ToB(otherNaN), PrimitiveFloat); c != 0 {
+		t.Errorf("NaN compared to another NaN is %d, want 0", c)
+	}
+
+	for _, operator := range []Operator{OperatorEqual, OperatorNotEqual, OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual} {
+		want := operator == OperatorNotEqual
+
+		if got := compareValues(f64ToB(nan), operator, f64ToB(nan), PrimitiveFloat); got != want {
+			t.Errorf("NaN %s NaN is %t, want %t", operator, got, want)
+		}
+
+		if got := compareValues(f64ToB(1), operator, f64ToB(nan), PrimitiveFloat); got != want {
+			t.Errorf("1 %s NaN is %t, want %t", operator, got, want)
+		}
+	}
+}
+
+func TestIndexKeys(t *testing.T) {
+	// values that are equal have the same key
+	for _, pair := range [][2]interface{}{
+		{math.Copysign(0, -1), 0.0},
+		{math.NaN(), math.Float64frombits(0xfff8000000000001)},
+		{math.NaN(), math.Float64frombits(0x7ff0000000000001)},
+	} {
+		k1, _ := indexKey(pair[0])
+		k2, _ := indexKey(pair[1])
+
+		if !bytes.Equal(k1, k2) {
+			t.Errorf("%v and %v have the keys %x and %x", pair[0], pair[1], k1, k2)
+		}
+	}
+
+	// and NaN is after every other float
+	nanKey, _ := indexKey(math.Float64frombits(0xfff8000000000001))
+	infKey, _ := indexKey(math.Inf(1))
+
+	if bytes.Compare(nanKey, infKey) <= 0 {
+		t.Errorf("the key of a negative NaN %x is not after the key of +Inf %x", nanKey, infKey)
+	}
+
+	if key, ok := indexKey(nil); ok {
+		t.Errorf("NULL has the key %x", key)
+	}
+}
+
+func TestCompareNull(t *testing.T) {
+	if c := PrimitiveInt.Compare(nil, int64(math.MaxInt64)); c != 1 {
+		t.Errorf("NULL compared to the largest int is %d, want 1", c)
+	}
+
+	if c := PrimitiveString.Compare(nil, nil); c != 0 {
+		t.Errorf("NULL compared to NULL is %d, want 0", c)
+	}
+
+	for operator, want := range map[Operator]ternary{
+		OperatorIsNull:    ternaryTrue,
+		OperatorIsNotNull: ternaryFalse,
+		OperatorEqual:     ternaryUnknown,
+		OperatorNotEqual:  ternaryUnknown,
+	} {
+		if got := compareNull(Filter{Operator: operator}); got != want {
+			t.Errorf("NULL %s is %d, want %d", operator, got, want)
+		}
+	}
+
+	if ternaryUnknown.and(ternaryFalse) != ternaryFalse || ternaryUnknown.or(ternaryTrue) != ternaryTrue || ternaryUnknown.not() != ternaryUnknown {
+		t.Error("unknown is not combined like in three-valued logic")
+	}
+}
@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A data directory can have more than one database, each with a catalog and tables of its own. The write-ahead log and
+// the xids are shared by every database of the data directory, see Store, so a transaction can use the tables of more
+// than one of them, while the names of tables only have to be unique in their database.
+
+// DefaultDatabase is the database that tables are created and opened in unless the context has another one. Its files
+// are stored in the data directory itself, where they were before there could be more than one database, while every
+// other database has a directory of its own in databasesDirName.
+const DefaultDatabase = "main"
+
+// databasesDirName is the directory of the data directory that the directories of databases are stored in.
+const databasesDirName = "databases"
+
+// databasePath returns the path of the file with the name in the directory of the database.
+func (s *Store) databasePath(database string, name string) string {
+	if database == DefaultDatabase {
+		return s.path(name)
+	}
+
+	return s.path(filepath.Join(databasesDirName, database, name))
+}
+
+type databaseKey struct{}
+
+// ContextWithDatabase returns a copy of the context that tables are created and opened in the database with.
+func ContextWithDatabase(ctx context.Context, database string) context.Context {
+	return context.WithValue(ctx, databaseKey{}, database)
+}
+
+// DatabaseFromContext returns the database of the context, which is DefaultDatabase unless another one was set with
+// ContextWithDatabase.
+func DatabaseFromContext(ctx context.Context) string {
+	database, ok := ctx.Value(databaseKey{}).(string)
+	if !ok {
+		return DefaultDatabase
+	}
+
+	return database
+}
+
+// DatabaseExists returns whether the store has a database with the name.
+func (s *Store) DatabaseExists(name string) (bool, error) {
+	_, unlock, err := s.lockCatalog(name)
+	if errors.Is(err, ErrDatabaseNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	unlock()
+
+	return true, nil
+}
+
+// CreateDatabase creates a database of the store without any tables.
+func (s *Store) CreateDatabase(ctx context.Context, name string) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("databases can not be created inside a transaction")
+	}
+
+	err := validateName("database", name)
+	if err != nil {
+		return err
+	}
+
+	err = s.recover()
+	if err != nil {
+		return err
+	}
+
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	_, err = s.openCatalog(name)
+	if err == nil {
+		return fmt.Errorf(`database with name "%s" already exists`, name)
+	} else if !errors.Is(err, ErrDatabaseNotExist) {
+		return err
+	}
+
+	// a directory without a catalog file was left behind by a DROP DATABASE that never finished
+	dir := s.databasePath(name, "")
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return fmt.Errorf("could not remove %s: %w", dir, err)
+	}
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not create database directory: %w", err)
+	}
+
+	file, err := s.createFile(s.getCatalogFilePath(name))
+	if err != nil {
+		return fmt.Errorf("could not create catalog file: %w", err)
+	}
+
+	c := &catalog{database: name, file: file}
+
+	batch := s.newWALBatch()
+
+	update, err := c.update(batch, nil)
+	if err == nil {
+		err = batch.commit()
+	}
+
+	if err != nil {
+		file.Close()
+		os.RemoveAll(dir)
+
+		return fmt.Errorf("could not write catalog: %w", err)
+	}
+
+	update()
+
+	s.catalogs[name] = c
+
+	return nil
+}
+
+// DropDatabase deletes the database of the store and every table of it. The tables of the database must not be open, since their
+// files are removed without waiting for the statements that use them. The default database can not be dropped.
+//
+// The files of the tables are removed together with the catalog file, all together or not at all, after which the
+// directory of the database is removed with the files that were left in it.
+func (s *Store) DropDatabase(ctx context.Context, name string) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("databases can not be dropped inside a transaction")
+	}
+
+	if name == DefaultDatabase {
+		return fmt.Errorf("the %s database can not be dropped", DefaultDatabase)
+	}
+
+	err := s.recover()
+	if err != nil {
+		return err
+	}
+
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	c, err := s.openCatalog(name)
+	if err != nil {
+		return err
+	}
+
+	batch := s.newWALBatch()
+
+	for _, t := range c.tables {
+		batch.remove(s.getTableFilePath(name, t.Name))
+		batch.remove(s.getHeapFilePath(name, t.Name))
+
+		for _, index := range t.Indexes {
+			batch.remove(s.getIndexFilePath(name, t.Name, index.Name))
+		}
+	}
+
+	batch.remove(s.getCatalogFilePath(name))
+
+	err = batch.commitFiles()
+	if err != nil {
+		return err
+	}
+
+	delete(s.catalogs, name)
+	c.file.Close()
+
+	dir := s.databasePath(name, "")
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return fmt.Errorf("could not remove %s: %w", dir, err)
+	}
+
+	return nil
+}
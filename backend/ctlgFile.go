@@ -0,0 +1,338 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The catalog of a database records every table of the database with its fields and indexes, so that tables are
+// found, listed and described without looking for their files. It is stored as JSON in the catalog file of the
+// directory of the database, and is only changed in the same walBatch as the files of the table it changes, so that
+// the catalog and the files always agree. A table is only in the catalog once the batch that creates it is committed,
+// and files of tables that are not in it are left behind by statements that never finished.
+//
+// A database exists as long as its catalog file does, except for the default database, which always exists. A default
+// database that was created before it had a catalog has the catalog built out of the headers of its table and index
+// files the first time it is opened.
+
+// TableInfo describes a table of the catalog. The indexes of the table are also its constraints, since a unique index
+// is the only constraint a table can have besides the maximum lengths of its fields.
+type TableInfo struct {
+	Name    string
+	Fields  []Field
+	Indexes []Index
+}
+
+// catalogFormatVersion is the version of the layout of the catalog file, see tableFormatVersion.
+const catalogFormatVersion = 1
+
+// catalogHeader is the contents of the catalog file.
+type catalogHeader struct {
+	Version int
+	Tables  []TableInfo
+}
+
+// catalog is the catalog of a database that is open. Its tables are sorted by name.
+type catalog struct {
+	database string
+	file     *os.File
+	tables   []TableInfo
+}
+
+func (s *Store) getCatalogFilePath(database string) string {
+	return s.databasePath(database, "catalog")
+}
+
+// lockCatalog returns the catalog of the database of the store, opening it if it is not open yet, with the catalogMu
+// of the store held until unlock is called. The write-ahead log is recovered first, since it can have writes to the
+// catalog. The error wraps ErrDatabaseNotExist if there is no such database.
+func (s *Store) lockCatalog(database string) (c *catalog, unlock func(), err error) {
+	err = s.recover()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.catalogMu.Lock()
+
+	c, err = s.openCatalog(database)
+	if err != nil {
+		s.catalogMu.Unlock()
+		return nil, nil, err
+	}
+
+	return c, s.catalogMu.Unlock, nil
+}
+
+// openCatalog returns the catalog of the database, opening it if it is not open yet. catalogMu must be held.
+func (s *Store) openCatalog(database string) (*catalog, error) {
+	if c, ok := s.catalogs[database]; ok {
+		return c, nil
+	}
+
+	err := validateName("database", database)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.loadCatalog(database)
+	if errors.Is(err, ErrDatabaseNotExist) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("could not open catalog: %w", err)
+	}
+
+	s.catalogs[database] = c
+
+	return c, nil
+}
+
+// loadCatalog reads the catalog file of the database, or builds it if it is empty.
+func (s *Store) loadCatalog(database string) (*catalog, error) {
+	err := s.ensureDir()
+	if err != nil {
+		return nil, err
+	}
+
+	flag := os.O_RDWR
+	if database == DefaultDatabase {
+		flag |= os.O_CREATE
+	}
+
+	file, err := os.OpenFile(s.getCatalogFilePath(database), flag, 0644)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotExist, database)
+	} else if err != nil {
+		return nil, err
+	}
+
+	c := &catalog{database: database, file: file}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if stat.Size() == 0 {
+		tables, err := s.readTableHeaders(database)
+		if err == nil {
+			var update func()
+
+			batch := s.newWALBatch()
+
+			update, err = c.update(batch, tables)
+			if err == nil {
+				err = batch.commit()
+			}
+
+			if err == nil {
+				update()
+			}
+		}
+
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return c, nil
+	}
+
+	data := make([]byte, stat.Size())
+
+	_, err = file.ReadAt(data, 0)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var header catalogHeader
+
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if header.Version != catalogFormatVersion {
+		file.Close()
+		return nil, fmt.Errorf("catalog is stored in format version %d, but only version %d is supported", header.Version, catalogFormatVersion)
+	}
+
+	c.tables = header.Tables
+
+	return c, nil
+}
+
+// readTableHeaders returns the tables of the table files of the database and the index files of each of them, sorted
+// by name, to build the catalog of a database that does not have one.
+func (s *Store) readTableHeaders(database string) ([]TableInfo, error) {
+	paths, err := filepath.Glob(s.getTableFilePath(database, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []TableInfo
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), "-db")
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		stat, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		t, err := readTableHeader(file, stat.Size())
+		file.Close()
+
+		// a table that can not be read is still in the catalog, and fails once it is opened
+		if errors.Is(err, ErrUnsupportedTableFormat) {
+			t, err = &table{}, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read table file %s: %w", path, err)
+		}
+
+		info := TableInfo{Name: name, Fields: t.Fields}
+
+		indexPaths, err := filepath.Glob(s.getIndexFilePath(database, name, "*"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, indexPath := range indexPaths {
+			file, err := os.Open(indexPath)
+			if err != nil {
+				return nil, err
+			}
+
+			i, err := readIndexFile(file)
+			file.Close()
+
+			if err != nil {
+				return nil, fmt.Errorf("could not read index file %s: %w", indexPath, err)
+			}
+
+			// the pattern also matches the indexes of tables whose name starts with the name of this table
+			if i.Table == name {
+				info.Indexes = append(info.Indexes, i.Index)
+			}
+		}
+
+		tables = append(tables, info)
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+
+	return tables, nil
+}
+
+// closeCatalogs closes every catalog of the store that is open, which are opened again once they are needed.
+func (s *Store) closeCatalogs() error {
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	var err error
+
+	for database, c := range s.catalogs {
+		if cErr := c.file.Close(); err == nil {
+			err = cErr
+		}
+
+		delete(s.catalogs, database)
+	}
+
+	return err
+}
+
+// table returns the table of the catalog with the name.
+func (c *catalog) table(name string) (TableInfo, bool) {
+	i := sort.Search(len(c.tables), func(i int) bool { return c.tables[i].Name >= name })
+	if i < len(c.tables) && c.tables[i].Name == name {
+		return c.tables[i], true
+	}
+
+	return TableInfo{}, false
+}
+
+// replace returns the tables of the catalog with the table with the name replaced by info, or removed if info is
+// nil. info is added if there is no table with the name.
+func (c *catalog) replace(name string, info *TableInfo) []TableInfo {
+	tables := make([]TableInfo, 0, len(c.tables)+1)
+	for _, t := range c.tables {
+		if t.Name != name {
+			tables = append(tables, t)
+		}
+	}
+
+	if info != nil {
+		tables = append(tables, *info)
+		sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	}
+
+	return tables
+}
+
+// update adds the write of the catalog with the tables to the batch. The tables are only the tables of the catalog
+// once the returned function is called, which must be done after the batch is committed.
+func (c *catalog) update(batch *walBatch, tables []TableInfo) (func(), error) {
+	data, err := json.Marshal(catalogHeader{Version: catalogFormatVersion, Tables: tables})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode catalog: %w", err)
+	}
+
+	batch.truncate(c.file, 0)
+	batch.writeAt(c.file, data, 0)
+
+	return func() { c.tables = tables }, nil
+}
+
+// Catalog returns every table of the database of the store, sorted by name. The error wraps ErrDatabaseNotExist if
+// there is no such database.
+func (s *Store) Catalog(database string) ([]TableInfo, error) {
+	c, unlock, err := s.lockCatalog(database)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return c.tables, nil
+}
+
+// TableNames returns the names of every table of the database of the store, sorted by name.
+func (s *Store) TableNames(database string) ([]string, error) {
+	tables, err := s.Catalog(database)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.Name
+	}
+
+	return names, nil
+}
+
+// info returns the table as it is described in the catalog. The table must be locked.
+func (t *table) info() TableInfo {
+	info := TableInfo{Name: t.Name, Fields: t.GetFields()}
+
+	for _, index := range t.indexes {
+		info.Indexes = append(info.Indexes, index.Index)
+	}
+
+	return info
+}
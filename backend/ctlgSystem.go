@@ -0,0 +1,354 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+)
+
+// systemSchema is the prefix of the names of the system tables, which are the tables of the catalog. Like the
+// information_schema of the SQL standard, they describe every table of the database, and are read like any other table.
+const systemSchema = "information_schema."
+
+// systemTable is a read-only table whose rows are made out of the catalog of its database every time it is read. rows
+// returns the values of each row, in the order of fields, out of the tables of the catalog followed by the system
+// tables.
+type systemTable struct {
+	store    *Store
+	database string
+	name     string
+	fields   []Field
+	rows     func(tables []TableInfo) [][]interface{}
+}
+
+// systemTables returns every system table.
+func systemTables() []*systemTable {
+	return []*systemTable{
+		{
+			name: systemSchema + "tables",
+			fields: []Field{
+				{Name: "table_name", Type: PrimitiveString},
+				{Name: "table_type", Type: PrimitiveString},
+			},
+			rows: func(tables []TableInfo) [][]interface{} {
+				var rows [][]interface{}
+
+				for _, t := range tables {
+					tableType := "BASE TABLE"
+					if _, ok := systemTableNamed(t.Name); ok {
+						tableType = "SYSTEM VIEW"
+					}
+
+					rows = append(rows, []interface{}{t.Name, tableType})
+				}
+
+				return rows
+			},
+		},
+		{
+			name: systemSchema + "columns",
+			fields: []Field{
+				{Name: "table_name", Type: PrimitiveString},
+				{Name: "column_name", Type: PrimitiveString},
+				{Name: "ordinal_position", Type: PrimitiveInt},
+				{Name: "data_type", Type: PrimitiveString},
+				{Name: "character_maximum_length", Type: PrimitiveInt},
+			},
+			rows: func(tables []TableInfo) [][]interface{} {
+				var rows [][]interface{}
+
+				for _, t := range tables {
+					for i, field := range t.Fields {
+						var maxLength interface{}
+						if field.MaxLength > 0 {
+							maxLength = field.MaxLength
+						}
+
+						rows = append(rows, []interface{}{t.Name, field.Name, int64(i + 1), string(field.Type), maxLength})
+					}
+				}
+
+				return rows
+			},
+		},
+		{
+			name: systemSchema + "indexes",
+			fields: []Field{
+				{Name: "table_name", Type: PrimitiveString},
+				{Name: "index_name", Type: PrimitiveString},
+				{Name: "column_name", Type: PrimitiveString},
+				{Name: "is_unique", Type: PrimitiveBool},
+			},
+			rows: func(tables []TableInfo) [][]interface{} {
+				var rows [][]interface{}
+
+				for _, t := range tables {
+					for _, index := range t.Indexes {
+						rows = append(rows, []interface{}{t.Name, index.Name, index.FieldName, index.Unique})
+					}
+				}
+
+				return rows
+			},
+		},
+		{
+			name: systemSchema + "table_constraints",
+			fields: []Field{
+				{Name: "table_name", Type: PrimitiveString},
+				{Name: "constraint_name", Type: PrimitiveString},
+				{Name: "constraint_type", Type: PrimitiveString},
+				{Name: "column_name", Type: PrimitiveString},
+			},
+			rows: func(tables []TableInfo) [][]interface{} {
+				var rows [][]interface{}
+
+				for _, t := range tables {
+					for _, index := range t.Indexes {
+						if index.Unique {
+							rows = append(rows, []interface{}{t.Name, index.Name, "UNIQUE", index.FieldName})
+						}
+					}
+				}
+
+				return rows
+			},
+		},
+	}
+}
+
+// systemTableNamed returns the system table with the name, which is not of any database until it is set. It returns
+// false if there is no system table with the name.
+func systemTableNamed(name string) (*systemTable, bool) {
+	for _, s := range systemTables() {
+		if s.name == name {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// IsSystemTable returns whether the name is the name of a system table.
+func IsSystemTable(name string) bool {
+	_, ok := systemTableNamed(name)
+
+	return ok
+}
+
+// schema returns a table with the name and fields of the system table, whose methods that only use them validate
+// the fields and filters of a scan.
+func (s *systemTable) schema() *table {
+	return &table{Name: s.name, Fields: s.fields}
+}
+
+// values returns the values of every row of the system table.
+func (s *systemTable) values() ([][]Value, error) {
+	tables, err := s.store.Catalog(s.database)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, system := range systemTables() {
+		tables = append(tables[:len(tables):len(tables)], TableInfo{Name: system.name, Fields: system.fields})
+	}
+
+	var values [][]Value
+
+	for _, row := range s.rows(tables) {
+		rowValues := make([]Value, len(s.fields))
+		for i, field := range s.fields {
+			rowValues[i] = Value{Type: field.Type, Val: row[i], FieldName: field.Name}
+		}
+
+		values = append(values, rowValues)
+	}
+
+	return values, nil
+}
+
+func (s *systemTable) errReadOnly() error {
+	return fmt.Errorf("%s is a system table and can not be changed", s.name)
+}
+
+func (s *systemTable) Cleanup() error {
+	return nil
+}
+
+func (s *systemTable) GetName() string {
+	return s.name
+}
+
+func (s *systemTable) GetFields() []Field {
+	return s.schema().GetFields()
+}
+
+func (s *systemTable) RowCount() int64 {
+	values, err := s.values()
+	if err != nil {
+		return 0
+	}
+
+	return int64(len(values))
+}
+
+func (s *systemTable) FieldWithName(fieldName string) (Field, error) {
+	return s.schema().FieldWithName(fieldName)
+}
+
+func (s *systemTable) HasField(fieldName string) bool {
+	return s.schema().HasField(fieldName)
+}
+
+func (s *systemTable) HasFieldWithType(fieldName string, fieldType Primitive) bool {
+	return s.schema().HasFieldWithType(fieldName, fieldType)
+}
+
+func (s *systemTable) InsertRow(ctx context.Context, vals []Value) (int, error) {
+	return 0, s.errReadOnly()
+}
+
+func (s *systemTable) GetRows(ctx context.Context, fields []string, filters []Filter, limit int) ([]Row, error) {
+	it, err := s.ScanRows(ctx, fields, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectRows(it)
+}
+
+// ScanRows returns an iterator over the selected fields of the rows of the system table that match the filter, in the
+// same way as the ScanRows of a table. The rows are of the catalog as it is when ScanRows is called.
+func (s *systemTable) ScanRows(ctx context.Context, fields []string, filters []Filter, limit int) (RowIterator, error) {
+	schema := s.schema()
+
+	selected, err := schema.selectedFields(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filter := range filters {
+		err = schema.validateFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := s.values()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+
+	for _, rowValues := range values {
+		if limit >= 0 && len(rows) == limit {
+			break
+		}
+
+		satisfies, err := ValuesSatisfy(rowValues, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		if !satisfies {
+			continue
+		}
+
+		if selected != nil {
+			selectedValues := make([]Value, 0, len(rowValues))
+			for i, val := range rowValues {
+				if selected[i] {
+					selectedValues = append(selectedValues, val)
+				}
+			}
+
+			rowValues = selectedValues
+		}
+
+		rows = append(rows, Row{Values: rowValues})
+	}
+
+	return &rowSlice{ctx: ctx, rows: rows, position: -1}, nil
+}
+
+func (s *systemTable) DeleteRows(ctx context.Context, filters []Filter) (int, error) {
+	return 0, s.errReadOnly()
+}
+
+func (s *systemTable) UpdateRows(ctx context.Context, values []Value, filters []Filter) (int, error) {
+	return 0, s.errReadOnly()
+}
+
+func (s *systemTable) GetIndexes() []Index {
+	return nil
+}
+
+func (s *systemTable) CreateIndex(ctx context.Context, index Index) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) DropIndex(ctx context.Context, name string) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) Vacuum(ctx context.Context) (int, error) {
+	return 0, s.errReadOnly()
+}
+
+func (s *systemTable) Drop(ctx context.Context) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) Truncate(ctx context.Context) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) Rename(ctx context.Context, newName string) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) AddField(ctx context.Context, field Field, def interface{}) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) DropField(ctx context.Context, name string) error {
+	return s.errReadOnly()
+}
+
+func (s *systemTable) AlterField(ctx context.Context, name string, field Field) error {
+	return s.errReadOnly()
+}
+
+// rowSlice is a RowIterator over rows that have already been read. It stops once the context is cancelled, like the
+// iterators of tables.
+type rowSlice struct {
+	ctx      context.Context
+	rows     []Row
+	position int
+	err      error
+}
+
+func (r *rowSlice) Next() bool {
+	if r.err != nil || r.position+1 >= len(r.rows) {
+		return false
+	}
+
+	if r.err = r.ctx.Err(); r.err != nil {
+		return false
+	}
+
+	r.position++
+
+	return true
+}
+
+func (r *rowSlice) Row() Row {
+	return r.rows[r.position]
+}
+
+func (r *rowSlice) Err() error {
+	return r.err
+}
+
+func (r *rowSlice) Close() error {
+	return nil
+}
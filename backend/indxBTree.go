@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"bytes"
+	"sort"
+)
+
+// indexEntry is a single entry of an index, the key of a row and the row it belongs to. Entries are ordered by key
+// and then by row, so every entry is unique even when keys are not.
+type indexEntry struct {
+	key []byte
+	row int64
+}
+
+func (e indexEntry) compare(other indexEntry) int {
+	c := bytes.Compare(e.key, other.key)
+	if c != 0 {
+		return c
+	}
+
+	switch {
+	case e.row < other.row:
+		return -1
+	case e.row > other.row:
+		return 1
+	}
+
+	return 0
+}
+
+// btreeOrder is the maximum number of entries in a node of a btree before it is split.
+const btreeOrder = 64
+
+// btree is a B+ tree of index entries. Every entry is stored in a leaf, and the leaves are linked in order so a range
+// of entries can be read without going back up the tree. Internal nodes only hold separators: the entries of
+// children[i] are at least entries[i-1] and less than entries[i].
+//
+// Deleting an entry does not merge nodes, so a tree that has many entries deleted can have nearly empty leaves. The
+// tree is rebuilt from scratch whenever its index file is compacted, which keeps this from growing without bound.
+type btree struct {
+	root *btreeNode
+	size int
+}
+
+type btreeNode struct {
+	leaf     bool
+	entries  []indexEntry
+	children []*btreeNode
+	next     *btreeNode
+}
+
+func newBTree() *btree {
+	return &btree{root: &btreeNode{leaf: true}}
+}
+
+// insert adds the entry to the tree. It returns false if the tree already has the entry.
+func (b *btree) insert(entry indexEntry) bool {
+	separator, right, inserted := b.root.insert(entry)
+
+	if right != nil {
+		b.root = &btreeNode{entries: []indexEntry{separator}, children: []*btreeNode{b.root, right}}
+	}
+
+	if inserted {
+		b.size++
+	}
+
+	return inserted
+}
+
+// insert adds the entry to the subtree of the node. If the node had to be split, it returns the new node that comes
+// after it and the separator between the two.
+func (n *btreeNode) insert(entry indexEntry) (separator indexEntry, right *btreeNode, inserted bool) {
+	if n.leaf {
+		i := sort.Search(len(n.entries), func(i int) bool { return n.entries[i].compare(entry) >= 0 })
+		if i < len(n.entries) && n.entries[i].compare(entry) == 0 {
+			return indexEntry{}, nil, false
+		}
+
+		n.entries = append(n.entries, indexEntry{})
+		copy(n.entries[i+1:], n.entries[i:])
+		n.entries[i] = entry
+		inserted = true
+	} else {
+		i := n.childFor(entry)
+
+		childSeparator, childRight, childInserted := n.children[i].insert(entry)
+		if childRight != nil {
+			n.entries = append(n.entries, indexEntry{})
+			copy(n.entries[i+1:], n.entries[i:])
+			n.entries[i] = childSeparator
+
+			n.children = append(n.children, nil)
+			copy(n.children[i+2:], n.children[i+1:])
+			n.children[i+1] = childRight
+		}
+
+		inserted = childInserted
+	}
+
+	if len(n.entries) <= btreeOrder {
+		return indexEntry{}, nil, inserted
+	}
+
+	separator, right = n.split()
+
+	return separator, right, inserted
+}
+
+// split moves the upper half of the node into a new node, which is returned with the separator between the two.
+func (n *btreeNode) split() (indexEntry, *btreeNode) {
+	mid := len(n.entries) / 2
+
+	if n.leaf {
+		right := &btreeNode{leaf: true, entries: append([]indexEntry(nil), n.entries[mid:]...), next: n.next}
+		n.entries = n.entries[:mid:mid]
+		n.next = right
+
+		return right.entries[0], right
+	}
+
+	// the separator moves up to the parent, so it is kept by neither node
+	separator := n.entries[mid]
+	right := &btreeNode{
+		entries:  append([]indexEntry(nil), n.entries[mid+1:]...),
+		children: append([]*btreeNode(nil), n.children[mid+1:]...),
+	}
+	n.entries = n.entries[:mid:mid]
+	n.children = n.children[: mid+1 : mid+1]
+
+	return separator, right
+}
+
+// childFor returns the position of the child of an internal node whose subtree the entry belongs to.
+func (n *btreeNode) childFor(entry indexEntry) int {
+	return sort.Search(len(n.entries), func(i int) bool { return n.entries[i].compare(entry) > 0 })
+}
+
+// remove deletes the entry from the tree. It returns false if the tree does not have the entry.
+func (b *btree) remove(entry indexEntry) bool {
+	n := b.root
+	for !n.leaf {
+		n = n.children[n.childFor(entry)]
+	}
+
+	i := sort.Search(len(n.entries), func(i int) bool { return n.entries[i].compare(entry) >= 0 })
+	if i == len(n.entries) || n.entries[i].compare(entry) != 0 {
+		return false
+	}
+
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+	b.size--
+
+	return true
+}
+
+// keyBound is one end of a range of keys. A nil bound leaves that end of the range open.
+type keyBound struct {
+	key       []byte
+	inclusive bool
+}
+
+// scan calls fn with every entry whose key is within the bounds, in order, until fn returns false.
+func (b *btree) scan(lower *keyBound, upper *keyBound, fn func(entry indexEntry) bool) {
+	n := b.root
+	i := 0
+
+	if lower == nil {
+		for !n.leaf {
+			n = n.children[0]
+		}
+	} else {
+		// rows are never negative, so this orders before every entry of the key
+		first := indexEntry{key: lower.key, row: -1}
+
+		for !n.leaf {
+			n = n.children[n.childFor(first)]
+		}
+
+		i = sort.Search(len(n.entries), func(i int) bool { return n.entries[i].compare(first) >= 0 })
+	}
+
+	for ; n != nil; n, i = n.next, 0 {
+		for ; i < len(n.entries); i++ {
+			entry := n.entries[i]
+
+			if lower != nil && !lower.inclusive && bytes.Equal(entry.key, lower.key) {
+				continue
+			}
+
+			if upper != nil {
+				c := bytes.Compare(entry.key, upper.key)
+				if c > 0 || (c == 0 && !upper.inclusive) {
+					return
+				}
+			}
+
+			if !fn(entry) {
+				return
+			}
+		}
+	}
+}
+
+// rowsWithKey returns the rows of every entry with the key.
+func (b *btree) rowsWithKey(key []byte) []int64 {
+	var rows []int64
+
+	bound := &keyBound{key: key, inclusive: true}
+	b.scan(bound, bound, func(entry indexEntry) bool {
+		rows = append(rows, entry.row)
+		return true
+	})
+
+	return rows
+}
@@ -0,0 +1,245 @@
+package backend
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func testEntry(key int, row int64) indexEntry {
+	return indexEntry{key: i64ToB(int64(key)), row: row}
+}
+
+// checkBTree checks that every node of the tree is ordered and within btreeOrder entries, that the separators of
+// internal nodes bound the entries of their children and that every leaf is at the same depth. It returns the depth
+// of the tree and its entries as they are linked through the leaves.
+func checkBTree(t *testing.T, b *btree) (int, []indexEntry) {
+	t.Helper()
+
+	leafDepth := -1
+
+	var check func(n *btreeNode, depth int, lower *indexEntry, upper *indexEntry)
+	check = func(n *btreeNode, depth int, lower *indexEntry, upper *indexEntry) {
+		if len(n.entries) > btreeOrder {
+			t.Fatalf("a node at depth %d has %d entries", depth, len(n.entries))
+		}
+
+		for i, entry := range n.entries {
+			if i > 0 && n.entries[i-1].compare(entry) >= 0 {
+				t.Fatalf("the entries of a node at depth %d are out of order", depth)
+			}
+
+			if (lower != nil && entry.compare(*lower) < 0) || (upper != nil && entry.compare(*upper) >= 0) {
+				t.Fatalf("an entry at depth %d is outside the separators of its parent", depth)
+			}
+		}
+
+		if n.leaf {
+			if leafDepth != -1 && depth != leafDepth {
+				t.Fatalf("there are leaves at depth %d and %d", leafDepth, depth)
+			}
+
+			leafDepth = depth
+			return
+		}
+
+		if len(n.children) != len(n.entries)+1 {
+			t.Fatalf("a node with %d separators has %d children", len(n.entries), len(n.children))
+		}
+
+		for i, child := range n.children {
+			childLower, childUpper := lower, upper
+			if i > 0 {
+				childLower = &n.entries[i-1]
+			}
+			if i < len(n.entries) {
+				childUpper = &n.entries[i]
+			}
+
+			check(child, depth+1, childLower, childUpper)
+		}
+	}
+
+	check(b.root, 0, nil, nil)
+
+	var entries []indexEntry
+	b.scan(nil, nil, func(entry indexEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+
+	if len(entries) != b.size {
+		t.Fatalf("the leaves link %d entries, but the tree has %d", len(entries), b.size)
+	}
+
+	for i := 1; i < len(entries); i++ {
+		if entries[i-1].compare(entries[i]) >= 0 {
+			t.Fatalf("entry %d of the leaves is out of order", i)
+		}
+	}
+
+	return leafDepth, entries
+}
+
+func TestBTreeSplits(t *testing.T) {
+	const count = 20000
+
+	ascending, descending := make([]int, count), make([]int, count)
+	for i := range ascending {
+		ascending[i], descending[i] = i, count-1-i
+	}
+
+	for name, keys := range map[string][]int{
+		"ascending":  ascending,
+		"descending": descending,
+		"shuffled":   rand.New(rand.NewSource(1)).Perm(count),
+	} {
+		b := newBTree()
+		for _, key := range keys {
+			if !b.insert(testEntry(key, 1)) {
+				t.Fatalf("%s: key %d was not inserted", name, key)
+			}
+		}
+
+		depth, entries := checkBTree(t, b)
+
+		// every level holds at most btreeOrder entries, so this many can not fit in two levels
+		if depth < 2 {
+			t.Errorf("%s: the tree is %d levels deep, want at least 3", name, depth+1)
+		}
+
+		if len(entries) != count {
+			t.Errorf("%s: the tree has %d entries, want %d", name, len(entries), count)
+		}
+
+		if b.insert(testEntry(keys[0], 1)) {
+			t.Errorf("%s: an entry that is already in the tree was inserted again", name)
+		}
+	}
+}
+
+func TestBTreeRangeScan(t *testing.T) {
+	const keys = 1000
+
+	b := newBTree()
+
+	// each key has three rows, inserted out of order
+	r := rand.New(rand.NewSource(2))
+	for _, i := range r.Perm(keys * 3) {
+		b.insert(testEntry(i/3, int64(i%3)))
+	}
+
+	checkBTree(t, b)
+
+	bound := func(key int, inclusive bool) *keyBound {
+		return &keyBound{key: i64ToB(int64(key)), inclusive: inclusive}
+	}
+
+	for _, c := range []struct {
+		lower, upper *keyBound
+		first, last  int // the keys of the first and last entries that are in the range
+	}{
+		{nil, nil, 0, keys - 1},
+		{bound(10, true), bound(20, true), 10, 20},
+		{bound(10, false), bound(20, false), 11, 19},
+		{bound(-5, true), bound(3, false), 0, 2},
+		{bound(990, false), nil, 991, keys - 1},
+		{nil, bound(0, true), 0, 0},
+		{bound(500, true), bound(500, true), 500, 500},
+		{bound(500, false), bound(501, false), 0, -1},
+		{bound(keys, true), nil, 0, -1},
+	} {
+		var got []indexEntry
+		b.scan(c.lower, c.upper, func(entry indexEntry) bool {
+			got = append(got, entry)
+			return true
+		})
+
+		var want []indexEntry
+		for key := c.first; key <= c.last; key++ {
+			for row := int64(0); row < 3; row++ {
+				want = append(want, testEntry(key, row))
+			}
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("scanning %v to %v found %d entries, want the %d of keys %d to %d", c.lower, c.upper, len(got), len(want), c.first, c.last)
+		}
+	}
+
+	// the scan stops once fn returns false
+	n := 0
+	b.scan(bound(100, true), nil, func(entry indexEntry) bool {
+		n++
+		return n < 5
+	})
+
+	if n != 5 {
+		t.Errorf("the scan called fn %d times after it returned false, want 5", n)
+	}
+
+	if rows := b.rowsWithKey(i64ToB(42)); fmt.Sprint(rows) != "[0 1 2]" {
+		t.Errorf("found the rows %v of key 42", rows)
+	}
+}
+
+func TestBTreeDelete(t *testing.T) {
+	const count = 5000
+
+	b := newBTree()
+	for i := 0; i < count; i++ {
+		b.insert(testEntry(i/2, int64(i%2)))
+	}
+
+	// every entry of a range that spans many leaves is removed, as well as every other entry outside of it
+	removed := map[int]bool{}
+	for i := 0; i < count; i++ {
+		key := i / 2
+		if (key >= 1000 && key < 2000) || i%3 == 0 {
+			if !b.remove(testEntry(key, int64(i%2))) {
+				t.Fatalf("entry %d was not removed", i)
+			}
+
+			removed[i] = true
+		}
+	}
+
+	if b.remove(testEntry(1500, 0)) || b.remove(testEntry(count, 0)) {
+		t.Error("an entry that is not in the tree was removed")
+	}
+
+	_, entries := checkBTree(t, b)
+
+	var want []indexEntry
+	for i := 0; i < count; i++ {
+		if !removed[i] {
+			want = append(want, testEntry(i/2, int64(i%2)))
+		}
+	}
+
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Fatalf("the tree has %d entries after removing, want %d", len(entries), len(want))
+	}
+
+	// a range over the emptied leaves continues with the leaves after them
+	var found []indexEntry
+	b.scan(&keyBound{key: i64ToB(1000), inclusive: true}, &keyBound{key: i64ToB(2000), inclusive: true}, func(entry indexEntry) bool {
+		found = append(found, entry)
+		return true
+	})
+
+	if want := []indexEntry{testEntry(2000, 0), testEntry(2000, 1)}; fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Errorf("found %v between the keys 1000 and 2000, want %v", found, want)
+	}
+
+	// the removed entries can be inserted again
+	for i := range removed {
+		if !b.insert(testEntry(i/2, int64(i%2))) {
+			t.Fatalf("entry %d was not inserted again", i)
+		}
+	}
+
+	if _, entries := checkBTree(t, b); len(entries) != count {
+		t.Errorf("the tree has %d entries, want %d", len(entries), count)
+	}
+}
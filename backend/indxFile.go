@@ -0,0 +1,366 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Index describes a secondary index of a table, which finds the rows with a value of FieldName without scanning the
+// table. A Unique index also ensures that no two rows have the same value, NULLs aside.
+type Index struct {
+	Name      string
+	FieldName string
+	Unique    bool
+}
+
+// tableIndex is an index that is open, its entries are kept in a btree in memory.
+//
+// The index file starts with a header in the same format as a table file's header, followed by a log of records
+// that each insert or delete a single entry. Opening the index replays the log to build the btree, and the log is
+// rewritten with only the entries that still exist once most of its records are stale.
+type tableIndex struct {
+	Index
+	Table   string
+	Version int
+
+	file            *os.File
+	tree            *btree
+	fieldIndex      int
+	headerByteCount int64
+	fileByteCount   int64
+	recordCount     int64
+}
+
+// indexFormatVersion is the version of the layout of index files, see tableFormatVersion.
+const indexFormatVersion = 1
+
+// The type of a record in the log of an index file.
+const (
+	indexRecordInsert byte = 1
+	indexRecordDelete byte = 2
+)
+
+func (s *Store) getIndexFilePath(database string, tableName string, indexName string) string {
+	return s.databasePath(database, fmt.Sprintf("%s-%s-idx", tableName, indexName))
+}
+
+// createIndexFile creates the file of a new index of the table, and adds the writes of its entries, which must be
+// sorted, to the batch. The file has to be removed if the batch is not committed.
+func createIndexFile(t *table, batch *walBatch, index Index, fieldIndex int, entries []indexEntry) (*tableIndex, error) {
+	path := t.store.getIndexFilePath(t.database, t.Name, index.Name)
+
+	// an index file that is not in the catalog was left behind by a CREATE INDEX that never finished
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not remove %s: %w", path, err)
+	}
+
+	file, err := t.store.createFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create index file: %w", err)
+	}
+
+	i := &tableIndex{Index: index, Table: t.Name, Version: indexFormatVersion, file: file, fieldIndex: fieldIndex}
+
+	err = i.writeIndexFile(batch, entries)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// writeIndexFile replaces the contents of the index file with a header and a log that inserts each of the entries,
+// which must be sorted, and rebuilds the btree out of them.
+func (i *tableIndex) writeIndexFile(batch *walBatch, entries []indexEntry) error {
+	b, err := i.encodeHeader()
+	if err != nil {
+		return err
+	}
+
+	headerByteCount := int64(len(b))
+
+	tree := newBTree()
+	for _, entry := range entries {
+		b = appendIndexRecord(b, indexRecordInsert, entry)
+		tree.insert(entry)
+	}
+
+	batch.truncate(i.file, 0)
+	batch.writeAt(i.file, b, 0)
+
+	i.tree = tree
+	i.headerByteCount = headerByteCount
+	i.fileByteCount = int64(len(b))
+	i.recordCount = int64(len(entries))
+
+	return nil
+}
+
+// encodeHeader returns the header of the index file, which is the metadata of the index as JSON.
+func (i *tableIndex) encodeHeader() ([]byte, error) {
+	data, err := json.Marshal(*i)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode index metadata: %w", err)
+	}
+
+	return append(i64ToB(int64(len(data)+8)), data...), nil
+}
+
+// openIndexes opens the indexes of the table.
+func openIndexes(t *table, indexes []Index) ([]*tableIndex, error) {
+	var opened []*tableIndex
+
+	for _, index := range indexes {
+		path := t.store.getIndexFilePath(t.database, t.Name, index.Name)
+
+		i, err := openIndex(t, path)
+		if err != nil {
+			closeIndexes(opened)
+			return nil, fmt.Errorf("could not open index file %s: %w", path, err)
+		}
+
+		opened = append(opened, i)
+	}
+
+	return opened, nil
+}
+
+// openIndex reads the index file at the path and builds its btree.
+func openIndex(t *table, path string) (*tableIndex, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := readIndexFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if i.Table != t.Name {
+		file.Close()
+		return nil, fmt.Errorf("index file is of table %s", i.Table)
+	}
+
+	i.fieldIndex = -1
+	for j, field := range t.Fields {
+		if field.Name == i.FieldName {
+			i.fieldIndex = j
+		}
+	}
+
+	if i.fieldIndex == -1 {
+		file.Close()
+		return nil, fieldNotExistErr(i.FieldName, t.Name)
+	}
+
+	// rewrite the log once more than half of its records are stale
+	if i.recordCount > 2*int64(i.tree.size) {
+		batch := t.store.newWALBatch()
+
+		err = i.compact(batch)
+		if err == nil {
+			err = batch.commit()
+		}
+
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return i, nil
+}
+
+// readIndexFile reads the header of an index file and replays its log.
+func readIndexFile(file *os.File) (*tableIndex, error) {
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+
+	headerSizeBytes := make([]byte, 8)
+
+	_, err := io.ReadFull(reader, headerSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := bToI64(headerSizeBytes)
+	header := make([]byte, headerSize-8)
+
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	var i tableIndex
+	err = json.Unmarshal(header, &i)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Version != indexFormatVersion {
+		return nil, fmt.Errorf("index %s is stored in format version %d, but only version %d is supported", i.Name, i.Version, indexFormatVersion)
+	}
+
+	i.file = file
+	i.tree = newBTree()
+	i.headerByteCount = headerSize
+	i.fileByteCount = headerSize
+
+	for {
+		op, entry, n, err := readIndexRecord(reader)
+		if err == io.EOF {
+			break
+		}
+
+		// a record that was only partially written is discarded
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = file.Truncate(i.fileByteCount)
+			if err != nil {
+				return nil, fmt.Errorf("could not discard partial index record: %w", err)
+			}
+
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case indexRecordInsert:
+			i.tree.insert(entry)
+		case indexRecordDelete:
+			i.tree.remove(entry)
+		default:
+			return nil, fmt.Errorf("invalid index record type %d", op)
+		}
+
+		i.fileByteCount += n
+		i.recordCount++
+	}
+
+	return &i, nil
+}
+
+// reload builds the btree out of the index file again, after the file was changed by rolling back a transaction.
+func (i *tableIndex) reload() error {
+	read, err := readIndexFile(i.file)
+	if err != nil {
+		return err
+	}
+
+	i.tree = read.tree
+	i.headerByteCount = read.headerByteCount
+	i.fileByteCount = read.fileByteCount
+	i.recordCount = read.recordCount
+
+	return nil
+}
+
+// compact rewrites the index file with only the entries of the btree.
+func (i *tableIndex) compact(batch *walBatch) error {
+	entries := make([]indexEntry, 0, i.tree.size)
+	i.tree.scan(nil, nil, func(entry indexEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+
+	return i.writeIndexFile(batch, entries)
+}
+
+// A record is the byte of its type, the row of the entry as an int64, the number of bytes of the key as an uint32 and
+// then the key itself.
+func appendIndexRecord(b []byte, op byte, entry indexEntry) []byte {
+	b = append(b, op)
+	b = append(b, i64ToB(entry.row)...)
+	b = appendUint32(b, uint32(len(entry.key)))
+
+	return append(b, entry.key...)
+}
+
+func readIndexRecord(reader io.Reader) (op byte, entry indexEntry, n int64, err error) {
+	head := make([]byte, 13)
+
+	// io.ReadFull only returns io.EOF if there is no record left at all
+	_, err = io.ReadFull(reader, head)
+	if err != nil {
+		return 0, indexEntry{}, 0, err
+	}
+
+	entry.row = bToI64(head[1:9])
+	entry.key = make([]byte, binary.BigEndian.Uint32(head[9:13]))
+
+	_, err = io.ReadFull(reader, entry.key)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return head[0], entry, int64(len(head) + len(entry.key)), err
+}
+
+// write appends records for the entries to the log and applies them to the btree.
+func (i *tableIndex) write(batch *walBatch, op byte, entries []indexEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	var b []byte
+	for _, entry := range entries {
+		b = appendIndexRecord(b, op, entry)
+	}
+
+	batch.writeAt(i.file, b, i.fileByteCount)
+
+	for _, entry := range entries {
+		if op == indexRecordInsert {
+			i.tree.insert(entry)
+		} else {
+			i.tree.remove(entry)
+		}
+	}
+
+	i.fileByteCount += int64(len(b))
+	i.recordCount += int64(len(entries))
+}
+
+// clear removes every entry from the index.
+func (i *tableIndex) clear(batch *walBatch) error {
+	return i.writeIndexFile(batch, nil)
+}
+
+func closeIndexes(indexes []*tableIndex) {
+	for _, i := range indexes {
+		i.file.Close()
+	}
+}
+
+// indexKey returns the key of a value in an index. NULL values are not indexed, so false is returned for them.
+//
+// Keys are the order preserving encoding of the value, except that all zeros and all NaNs of floats have the same
+// key, so that every value that compares equal to another also has the same key.
+func indexKey(val interface{}) ([]byte, bool) {
+	switch v := val.(type) {
+	case nil:
+		return nil, false
+	case float64:
+		if v == 0 {
+			v = 0
+		} else if math.IsNaN(v) {
+			v = math.NaN()
+		}
+
+		return f64ToB(v), true
+	}
+
+	return anyToB(val), true
+}
@@ -0,0 +1,294 @@
+package backend
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Rows are versioned so that reading a table never waits for the statements writing to it. Every statement that
+// writes, or the transaction it is in, has a transaction id, an xid, which is stored in the header of the row
+// versions it writes. Inserting a row creates a version with the xid as its xmin, deleting a row sets the xmax of its
+// version to the xid, and updating a row does both, leaving the old version in its slot and creating a new one in
+// another slot.
+//
+// A snapshot is the set of transactions that had ended when it was taken, and a version is visible to a snapshot if
+// the transaction that created it is in the set, while the one that deleted it, if any, is not. Statements read the
+// versions visible to their snapshot, so they never see the changes of transactions that have not ended, nor any
+// changes made after they began. Transactions that are rolled back are undone through the write-ahead log, so every
+// xid in the files either belongs to a transaction that committed or one that has not ended.
+//
+// Versions that were deleted before every snapshot that is still in use are dead, and vacuum reclaims their slots.
+
+// xidBlockSize is the number of xids that are reserved in the xid file at a time. xids are never reused, so that a
+// version is never taken to be created by a later transaction, and are handed out from the reserved block without
+// writing to the file. After a restart the rest of the block is skipped.
+const xidBlockSize = 1024
+
+func (s *Store) getXIDFilePath() string {
+	return s.path("xid")
+}
+
+// mvcc is the state of the versioning of rows. active are the xids of the transactions that have not ended, and
+// snapshots are the snapshots that are in use.
+type mvcc struct {
+	mu        sync.Mutex
+	file      *os.File
+	nextXID   uint64
+	reserved  uint64
+	active    map[uint64]bool
+	snapshots map[*snapshot]bool
+}
+
+// getMVCC returns the versioning state of the store, reading the next xid from the xid file if it has not been read
+// yet.
+func (s *Store) getMVCC() (*mvcc, error) {
+	s.mvccMu.Lock()
+	defer s.mvccMu.Unlock()
+
+	if s.mvcc != nil {
+		return s.mvcc, nil
+	}
+
+	err := s.ensureDir()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(s.getXIDFilePath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open xid file: %w", err)
+	}
+
+	b := make([]byte, 8)
+
+	_, err = io.ReadFull(file, b)
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("could not read xid file: %w", err)
+	}
+
+	// xid 0 is the xmax of versions that have not been deleted
+	next := uint64(1)
+	if err == nil {
+		next = binary.BigEndian.Uint64(b)
+	}
+
+	s.mvcc = &mvcc{
+		file:      file,
+		nextXID:   next,
+		reserved:  next,
+		active:    map[uint64]bool{},
+		snapshots: map[*snapshot]bool{},
+	}
+
+	return s.mvcc, nil
+}
+
+// begin returns a new xid, which is active until it is passed to end.
+func (m *mvcc) begin() (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nextXID == m.reserved {
+		_, err := m.file.WriteAt(appendUint64(nil, m.reserved+xidBlockSize), 0)
+		if err == nil {
+			err = m.file.Sync()
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("could not reserve transaction ids: %w", err)
+		}
+
+		m.reserved += xidBlockSize
+	}
+
+	xid := m.nextXID
+	m.nextXID++
+	m.active[xid] = true
+
+	return xid, nil
+}
+
+// end ends the transaction of the xid, making its changes visible to the snapshots taken after.
+func (m *mvcc) end(xid uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.active, xid)
+}
+
+// snapshot is the set of transactions whose changes are visible to a statement. Every transaction before xmin had
+// ended when it was taken, and none from xmax on had begun, while active are the ones in between that had not ended.
+// xid is the transaction the snapshot was taken for, whose own changes are always visible.
+type snapshot struct {
+	xmin   uint64
+	xmax   uint64
+	active map[uint64]bool
+	xid    uint64
+}
+
+// takeSnapshot returns a snapshot of the transactions that have ended, for the transaction of the xid. It must be
+// released once it is no longer used.
+func (m *mvcc) takeSnapshot(xid uint64) *snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &snapshot{xmin: m.nextXID, xmax: m.nextXID, active: make(map[uint64]bool, len(m.active)), xid: xid}
+
+	for active := range m.active {
+		s.active[active] = true
+
+		if active < s.xmin {
+			s.xmin = active
+		}
+	}
+
+	m.snapshots[s] = true
+
+	return s
+}
+
+// release marks the snapshot as no longer in use, so the versions only it could see can be reclaimed.
+func (m *mvcc) release(s *snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.snapshots, s)
+}
+
+// horizon returns the xid before which every transaction has ended for every snapshot in use, or that will be taken.
+// A version deleted by a transaction before the horizon can no longer be seen by anything.
+func (m *mvcc) horizon() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	horizon := m.nextXID
+
+	for xid := range m.active {
+		if xid < horizon {
+			horizon = xid
+		}
+	}
+
+	for s := range m.snapshots {
+		if s.xmin < horizon {
+			horizon = s.xmin
+		}
+	}
+
+	return horizon
+}
+
+// sees returns whether the changes of the transaction of the xid are visible to the snapshot.
+func (s *snapshot) sees(xid uint64) bool {
+	return xid == s.xid || (xid < s.xmax && !s.active[xid])
+}
+
+// visible returns whether the version with the xmin and xmax is visible to the snapshot.
+func (s *snapshot) visible(xmin uint64, xmax uint64) bool {
+	return s.sees(xmin) && (xmax == 0 || !s.sees(xmax))
+}
+
+// snapshotKey is the key of the snapshot of a statement of the store, which is only read from the tables of the store.
+type snapshotKey struct {
+	store *Store
+}
+
+// BeginStatement returns a copy of the context with the snapshot that a statement reads from, so that every table of
+// the store it reads sees the same changes. In a snapshot isolation transaction that is the snapshot of the
+// transaction, otherwise it is a new snapshot. The returned function must be called once the statement is done.
+func (s *Store) BeginStatement(ctx context.Context) (context.Context, func(), error) {
+	snap, release, err := s.statementSnapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return context.WithValue(ctx, snapshotKey{store: s}, snap), release, nil
+}
+
+// statementSnapshot returns the snapshot the statement of the context reads the tables of the store from, and a
+// function that releases it. Statements that were not begun with BeginStatement get a snapshot of their own.
+func (s *Store) statementSnapshot(ctx context.Context) (*snapshot, func(), error) {
+	if snap, ok := ctx.Value(snapshotKey{store: s}).(*snapshot); ok {
+		return snap, func() {}, nil
+	}
+
+	tx := transactionFromContext(ctx)
+
+	err := s.checkTransaction(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tx != nil && tx.Isolation == IsolationSnapshot {
+		return tx.snapshot, func() {}, nil
+	}
+
+	m, err := s.getMVCC()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var xid uint64
+	if tx != nil {
+		xid = tx.xid
+	}
+
+	snap := m.takeSnapshot(xid)
+
+	return snap, func() { m.release(snap) }, nil
+}
+
+// beginWrite returns the snapshot that a statement that writes to the table reads the rows it changes from, whose
+// xid is the one the statement writes with. It must be called once the table is locked for writing, so that the
+// snapshot has every change of the other statements that wrote to it. The returned function must be called once the
+// changes of the statement are committed, or have failed.
+//
+// In a snapshot isolation transaction, the snapshot of the transaction is used, so the rows it changes can have been
+// changed since by a transaction that is not visible to it, see checkConflicts.
+func (t *table) beginWrite(ctx context.Context) (*snapshot, func(), error) {
+	m, err := t.store.getMVCC()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := transactionFromContext(ctx)
+	if tx != nil {
+		if tx.Isolation == IsolationSnapshot {
+			return tx.snapshot, func() {}, nil
+		}
+
+		s := m.takeSnapshot(tx.xid)
+
+		return s, func() { m.release(s) }, nil
+	}
+
+	// a statement that is not in a transaction is a transaction of its own
+	xid, err := m.begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := m.takeSnapshot(xid)
+
+	return s, func() {
+		m.release(s)
+		m.end(xid)
+	}, nil
+}
+
+// checkConflicts returns an error if any of the rows, which were read with the snapshot of a statement that is
+// about to change them, has been deleted or updated by a transaction that the snapshot does not see.
+func (t *table) checkConflicts(rows []Row) error {
+	for _, row := range rows {
+		if row.xmax != 0 {
+			return fmt.Errorf("%w to table %s, a row was changed by a transaction that committed after this one began", ErrSerializationFailure, t.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"context"
+	"errors"
+)
+
+// A table is vacuumed once a statement or transaction that wrote to it is done, if it has more than
+// autoVacuumMinimum deleted row versions and they take up more than 1/autoVacuumFraction of its slots.
+const (
+	autoVacuumMinimum  = 64
+	autoVacuumFraction = 8
+)
+
+// Vacuum reclaims the slots of the deleted row versions of the table that can no longer be seen by any snapshot, and
+// removes them from the indexes, so that the slots are reused by the versions written after. The strings of the heap
+// file that only those versions referred to are freed as well. It returns the number of
+// versions that were reclaimed. It can not be run inside a transaction.
+func (t *table) Vacuum(ctx context.Context) (int, error) {
+	if transactionFromContext(ctx) != nil {
+		return 0, errors.New("tables can not be vacuumed inside a transaction")
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return t.vacuum()
+}
+
+// autoVacuum vacuums the table if enough of its versions are deleted and the horizon has moved since it was last
+// vacuumed, which is the only way more of them could have become dead. It must be called while the table is locked
+// for writing with lockForWrite, but not with mrw. A vacuum that fails leaves the table failed, so the error is
+// dropped.
+func (t *table) autoVacuum() {
+	t.mrw.RLock()
+	due := t.failed == nil && t.deadCount > autoVacuumMinimum && t.deadCount*autoVacuumFraction > t.slotCount
+	t.mrw.RUnlock()
+
+	if !due {
+		return
+	}
+
+	// versions can only have been deleted once the state was read
+	if t.store.mvcc.horizon() == t.vacuumHorizon {
+		return
+	}
+
+	_, _ = t.vacuum()
+}
+
+// vacuum reclaims the slots of every version that was deleted by a transaction before the horizon. The table is only
+// locked with mrw while the slots are freed, so statements can read it while the dead versions are found. It must be
+// locked for writing with lockForWrite.
+func (t *table) vacuum() (int, error) {
+	m, err := t.store.getMVCC()
+	if err != nil {
+		return 0, err
+	}
+
+	horizon := m.horizon()
+
+	var dead []rowVersion
+	var deadRanges []heapRange
+
+	// the offsets of the heap file that versions which are kept refer to
+	kept := make(map[int64]bool)
+
+	err = t.scanVersions(func(slot int64, rowBytes []byte) error {
+		if isFree(rowBytes) {
+			return nil
+		}
+
+		xmax := rowXmax(rowBytes)
+		if xmax == 0 || xmax >= horizon {
+			for _, r := range t.heapRanges(rowBytes) {
+				kept[r.offset] = true
+			}
+
+			return nil
+		}
+
+		values, err := t.decodeRow(rowBytes)
+		if err != nil {
+			return err
+		}
+
+		dead = append(dead, rowVersion{slot: slot, values: valuesOf(values)})
+		deadRanges = append(deadRanges, t.heapRanges(rowBytes)...)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// a string that was kept by an update is referred to by more than one version, and is only freed once
+	var freed []heapRange
+	for _, r := range deadRanges {
+		if !kept[r.offset] {
+			kept[r.offset] = true
+			freed = append(freed, r)
+		}
+	}
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return 0, t.failed
+	}
+
+	t.vacuumHorizon = horizon
+
+	if len(dead) == 0 {
+		return 0, nil
+	}
+
+	batch := t.store.newWALBatch()
+
+	for _, version := range dead {
+		batch.writeAt(t.file, []byte{rowFree}, t.slotOffset(version.slot))
+	}
+
+	t.unindexVersions(batch, dead)
+
+	freeHeap, heapSize := t.withFreedHeap(freed)
+	if heapSize < t.heapByteCount {
+		batch.truncate(t.heapFile, heapSize)
+	}
+
+	// every transaction that wrote to the table has ended, so the slots are freed on their own
+	err = t.commit(context.Background(), batch)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, version := range dead {
+		t.freeSlots = append(t.freeSlots, version.slot)
+	}
+
+	t.freeHeap = freeHeap
+	t.heapByteCount = heapSize
+
+	t.deadCount -= int64(len(dead))
+
+	return len(dead), nil
+}
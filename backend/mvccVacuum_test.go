@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"context"
+	"testing"
+)
+
+func heapSize(ot OperableTable) int64 {
+	t := ot.(*table)
+
+	t.mrw.RLock()
+	defer t.mrw.RUnlock()
+
+	return t.heapByteCount
+}
+
+func vacuum(t *testing.T, ot OperableTable) {
+	t.Helper()
+
+	_, err := ot.Vacuum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdatesKeepStringsInHeap(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t, t.TempDir())
+
+	ot, err := s.CreateTable(ctx, "kv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ot.Cleanup()
+
+	insertTestRow(t, ctx, ot, 0)
+	size := heapSize(ot)
+
+	// the new versions refer to the string of the first one, as it is never changed
+	for i := 1; i <= 200; i++ {
+		_, err = ot.UpdateRows(ctx, []Value{{Type: PrimitiveInt, Val: int64(i), FieldName: "id"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vacuum(t, ot)
+
+	if got := heapSize(ot); got != size {
+		t.Fatalf("the heap is %d bytes after the updates, want %d", got, size)
+	}
+
+	if got, want := dumpRows(t, ctx, ot), "1 rows: 200=a string that is long enough to fill pages 0"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestVacuumFreesHeap(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := openTestStore(t, dir)
+
+	ot, err := s.CreateTable(ctx, "kv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3000; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	size := heapSize(ot)
+
+	// the strings of the last rows are at the end of the heap, which is truncated
+	_, err = ot.DeleteRows(ctx, idFilter(OperatorGreaterThanOrEqual, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vacuum(t, ot)
+
+	if got := heapSize(ot); got*20 > size {
+		t.Fatalf("the heap is %d bytes once all but 100 of 3000 rows were vacuumed, it was %d", got, size)
+	}
+
+	for i := 100; i < 3000; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	if got := heapSize(ot); got != size {
+		t.Fatalf("the heap is %d bytes once the rows were inserted again, want %d", got, size)
+	}
+
+	// while the strings of the other rows are reused wherever they are
+	_, err = ot.DeleteRows(ctx, idFilter(OperatorLessThan, 1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vacuum(t, ot)
+
+	if got := heapSize(ot); got != size {
+		t.Fatalf("the heap is %d bytes once the first rows were vacuumed, want %d", got, size)
+	}
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the free ranges are found again when the table is opened
+	s = openTestStore(t, dir)
+
+	ot, err = s.OpenTable(ctx, "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ot.Cleanup()
+
+	for i := 0; i < 1500; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	if got := heapSize(ot); got != size {
+		t.Fatalf("the heap is %d bytes once the rows were inserted after it was opened, want %d", got, size)
+	}
+
+	// updates that change the strings write them to the heap, which stays bounded as it is vacuumed
+	for i := 0; i < 5; i++ {
+		_, err = ot.UpdateRows(ctx, []Value{{Type: PrimitiveString, Val: "a string that replaces every other one", FieldName: "s"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vacuum(t, ot)
+
+		_, err = ot.UpdateRows(ctx, []Value{{Type: PrimitiveString, Val: "and one that replaces it once again", FieldName: "s"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vacuum(t, ot)
+	}
+
+	if got := heapSize(ot); got > 2*size {
+		t.Fatalf("the heap grew to %d bytes by the updates, it was %d", got, size)
+	}
+
+	if got := dumpRows(t, ctx, ot); got[:10] != "3000 rows:" {
+		t.Fatalf("got %.50s", got)
+	}
+}
@@ -6,11 +6,23 @@ type OperableTable interface {
 	Cleanup() error
 	GetName() string
 	GetFields() []Field
+	RowCount() int64
 	FieldWithName(fieldName string) (Field, error)
 	HasField(fieldName string) bool
 	HasFieldWithType(fieldName string, fieldType Primitive) bool
 	InsertRow(ctx context.Context, vals []Value) (int, error)
-	GetRows(ctx context.Context, fields []string, filters []Filter) ([]Row, error)
+	GetRows(ctx context.Context, fields []string, filters []Filter, limit int) ([]Row, error)
+	ScanRows(ctx context.Context, fields []string, filters []Filter, limit int) (RowIterator, error)
 	DeleteRows(ctx context.Context, filters []Filter) (int, error)
 	UpdateRows(ctx context.Context, values []Value, filters []Filter) (int, error)
+	GetIndexes() []Index
+	CreateIndex(ctx context.Context, index Index) error
+	DropIndex(ctx context.Context, name string) error
+	Vacuum(ctx context.Context) (int, error)
+	Drop(ctx context.Context) error
+	Truncate(ctx context.Context) error
+	Rename(ctx context.Context, newName string) error
+	AddField(ctx context.Context, field Field, def interface{}) error
+	DropField(ctx context.Context, name string) error
+	AlterField(ctx context.Context, name string, field Field) error
 }
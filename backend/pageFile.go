@@ -0,0 +1,266 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// pageFile is a file that is read and written through the buffer pool of its store. Its size includes the writes that
+// are in the pages of the pool but not in the file yet. pages are the pages of the file that are in the pool, by their
+// number, which like size are guarded by the mutex of the pool.
+type pageFile struct {
+	pool   *bufferPool
+	file   *os.File
+	size   int64
+	pages  map[int64]*frame
+	closed bool
+}
+
+// newPageFile returns the file as a pageFile of the pool, which the file must not be used apart from after.
+func newPageFile(pool *bufferPool, file *os.File) (*pageFile, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not open file stats: %w", err)
+	}
+
+	return &pageFile{pool: pool, file: file, size: stat.Size(), pages: map[int64]*frame{}}, nil
+}
+
+// openPageFile opens the file at the path for reading and writing as a pageFile of the store.
+func (s *Store) openPageFile(path string) (*pageFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newPageFile(s.pool, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// createPageFile creates a file at the path as a pageFile of the store. It is an error if the file already exists.
+func (s *Store) createPageFile(path string) (*pageFile, error) {
+	file, err := s.createFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := newPageFile(s.pool, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ReadAt reads from the pages of the file in the same way as os.File.ReadAt.
+func (f *pageFile) ReadAt(b []byte, off int64) (int, error) {
+	f.pool.mu.Lock()
+	defer f.pool.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	n := len(b)
+	if int64(n) > f.size-off {
+		n = int(f.size - off)
+	}
+
+	for read := 0; read < n; {
+		pos := off + int64(read)
+
+		page, err := f.pool.page(f, pos/pageSize)
+		if err != nil {
+			return read, err
+		}
+
+		read += copy(b[read:n], page.data[pos%pageSize:])
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// WriteAt writes to the pages of the file in the same way as os.File.WriteAt, marking them dirty.
+func (f *pageFile) WriteAt(b []byte, off int64) (int, error) {
+	f.pool.mu.Lock()
+	defer f.pool.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	for written := 0; written < len(b); {
+		pos := off + int64(written)
+
+		page, err := f.pool.page(f, pos/pageSize)
+		if err != nil {
+			return written, err
+		}
+
+		written += copy(page.data[pos%pageSize:], b[written:])
+		page.dirty = true
+
+		if end := off + int64(written); end > f.size {
+			f.size = end
+		}
+	}
+
+	return len(b), nil
+}
+
+// Truncate changes the size of the file, and of its pages in the pool, in the same way as os.File.Truncate.
+func (f *pageFile) Truncate(size int64) error {
+	f.pool.mu.Lock()
+	defer f.pool.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	// a page that is being written back must not be written after the file is truncated
+	for busy := f.busyFrame(); busy != nil; busy = f.busyFrame() {
+		f.pool.wait(busy)
+	}
+
+	err := f.file.Truncate(size)
+	if err != nil {
+		return err
+	}
+
+	for page, frame := range f.pages {
+		start := page * pageSize
+
+		if start >= size {
+			f.pool.drop(frame)
+		} else if start+pageSize > size {
+			data := frame.data[size-start:]
+			for i := range data {
+				data[i] = 0
+			}
+		}
+	}
+
+	f.size = size
+
+	return nil
+}
+
+// busyFrame returns a frame of the file that is being read or written, or nil if there is none. The pool must be
+// locked.
+func (f *pageFile) busyFrame() *frame {
+	for _, frame := range f.pages {
+		if frame.io != nil {
+			return frame
+		}
+	}
+
+	return nil
+}
+
+// Stat returns the stats of the file, with the size that it has in the pool.
+func (f *pageFile) Stat() (os.FileInfo, error) {
+	stat, err := f.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	f.pool.mu.Lock()
+	defer f.pool.mu.Unlock()
+
+	return pageFileInfo{FileInfo: stat, size: f.size}, nil
+}
+
+func (f *pageFile) Name() string {
+	return f.file.Name()
+}
+
+// Close writes the dirty pages of the file to it, removes its pages from the pool and closes it.
+func (f *pageFile) Close() error {
+	f.pool.mu.Lock()
+	defer f.pool.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	f.closed = true
+
+	var err error
+
+	// the pool is unlocked while a page is written, so the pages are looked at again after each one
+	for len(f.pages) != 0 {
+		for _, frame := range f.pages {
+			if frame.io != nil {
+				f.pool.wait(frame)
+			} else if frame.dirty && err == nil {
+				err = f.pool.writeBack(frame)
+			} else {
+				f.pool.drop(frame)
+			}
+
+			break
+		}
+	}
+
+	cErr := f.file.Close()
+	if err == nil {
+		err = cErr
+	}
+
+	return err
+}
+
+// pageData returns the part of the data of the frame that is not past the end of the file, which is what is written
+// to the file. The pool must be locked.
+func (f *pageFile) pageData(frame *frame) []byte {
+	n := f.size - frame.page*pageSize
+	if n > pageSize {
+		n = pageSize
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	return frame.data[:n]
+}
+
+// writePage writes the data of the page, see pageData, to the file. The frame of the page must be busy, so that its
+// data is not changed while it is written.
+func (f *pageFile) writePage(page int64, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := f.file.WriteAt(data, page*pageSize)
+	if err != nil {
+		return fmt.Errorf("could not write page %d of %s: %w", page, f.Name(), err)
+	}
+
+	return nil
+}
+
+// pageFileInfo is the stats of a pageFile, whose size can be larger than that of the file itself.
+type pageFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (i pageFileInfo) Size() int64 {
+	return i.size
+}
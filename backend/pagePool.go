@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The buffer pool keeps the pages of the files of tables in memory, so the tables that are used the most are read
+// without reading their files. Every read and write of a pageFile goes through the pool a page at a time. The writes
+// that a walBatch applies only change the pages in the pool, which are marked dirty and written to the file once they
+// are evicted or the log is checkpointed. That is safe because the writes are in the log, which is synced before they
+// are applied, so a crash before they reach the file only loses writes that recovery repeats.
+//
+// Every Store has a pool of its own, which holds a limited number of pages, see OpenStore. Once it is full, the page
+// to evict is picked with the clock algorithm: the pages are in a ring that a hand goes around, and a page that was
+// used since the hand last passed it is skipped once, so the pages that are used often stay in the pool.
+
+// pageSize is the number of bytes of a page.
+const pageSize = 8192
+
+// defaultBufferPoolSize is the number of bytes of pages the buffer pool holds unless OpenStore is given another size.
+const defaultBufferPoolSize = 32 * 1024 * 1024
+
+// frame is a page of a file that is in the buffer pool, or an empty frame if file is nil. referenced is set whenever
+// the page is used and cleared by the clock hand, while dirty is set when the page has writes that are not in the
+// file yet. io is only set while the page is being read from or written to its file, and is closed once it has been.
+type frame struct {
+	file       *pageFile
+	page       int64
+	data       []byte
+	dirty      bool
+	referenced bool
+	io         chan struct{}
+}
+
+// bufferPool is the pages of files that are in memory, with at most limit frames. Its mutex guards the frames, along
+// with the pages and sizes of the pageFiles, and is never locked before anything else is. It is not held while a page
+// is read from or written to its file, so statements that use pages in the pool never wait for the disk. A frame that
+// is being read or written has io set instead, and is left alone until it is done: its data is neither used nor
+// changed, and it is not evicted.
+type bufferPool struct {
+	mu     sync.Mutex
+	limit  int
+	frames []*frame
+	hand   int
+}
+
+// newBufferPool returns an empty pool that holds as many pages as fit in size bytes.
+func newBufferPool(size int64) *bufferPool {
+	return &bufferPool{limit: int(size / pageSize)}
+}
+
+// wait waits until the frame is no longer being read or written. The pool must be locked, and is unlocked while
+// waiting, so the frame can hold another page once it returns.
+func (p *bufferPool) wait(f *frame) {
+	done := f.io
+
+	p.mu.Unlock()
+	<-done
+	p.mu.Lock()
+}
+
+// page returns the frame of the page of the file, reading the page into the pool if it is not in it yet. The part of
+// the page that is past the end of the file is zero. The pool must be locked, and is unlocked while the page is read.
+func (p *bufferPool) page(file *pageFile, page int64) (*frame, error) {
+	for {
+		if f, ok := file.pages[page]; ok {
+			if f.io != nil {
+				p.wait(f)
+				continue
+			}
+
+			f.referenced = true
+			return f, nil
+		}
+
+		f, err := p.allocate()
+		if err != nil {
+			return nil, err
+		}
+
+		// another statement can have read the page while a frame was being allocated
+		if _, ok := file.pages[page]; ok {
+			continue
+		}
+
+		done := make(chan struct{})
+		f.file, f.page, f.dirty, f.referenced, f.io = file, page, false, true, done
+		file.pages[page] = f
+
+		p.mu.Unlock()
+
+		n, err := file.file.ReadAt(f.data, page*pageSize)
+		for i := n; i < pageSize; i++ {
+			f.data[i] = 0
+		}
+
+		p.mu.Lock()
+
+		f.io = nil
+		close(done)
+
+		if err != nil && err != io.EOF {
+			p.drop(f)
+			return nil, fmt.Errorf("could not read page %d of %s: %w", page, file.Name(), err)
+		}
+
+		return f, nil
+	}
+}
+
+// allocate returns an empty frame, evicting the page of a frame with the clock hand if the pool is full. The pool must
+// be locked, and is unlocked while a dirty page is written to its file before it is evicted.
+func (p *bufferPool) allocate() (*frame, error) {
+	for {
+		if len(p.frames) < p.limit {
+			f := &frame{data: make([]byte, pageSize)}
+			p.frames = append(p.frames, f)
+
+			return f, nil
+		}
+
+		// the hand goes around twice at most, since it clears referenced the first time, unless every frame is busy
+		var busy *frame
+
+		for i := 0; i < 2*len(p.frames); i++ {
+			f := p.frames[p.hand]
+			p.hand = (p.hand + 1) % len(p.frames)
+
+			if f.io != nil {
+				busy = f
+				continue
+			}
+
+			if f.file == nil {
+				return f, nil
+			}
+
+			if f.referenced {
+				f.referenced = false
+				continue
+			}
+
+			if f.dirty {
+				// the page can be used again while it is written, so the hand has to come back to it
+				err := p.writeBack(f)
+				if err != nil {
+					return nil, err
+				}
+
+				busy = nil
+				break
+			}
+
+			p.drop(f)
+
+			return f, nil
+		}
+
+		if busy != nil {
+			p.wait(busy)
+		}
+	}
+}
+
+// writeBack writes the dirty page of the frame to its file, after which it is clean. The pool must be locked, and is
+// unlocked while the page is written. The frame must not be busy.
+func (p *bufferPool) writeBack(f *frame) error {
+	file := f.file
+	data := file.pageData(f)
+
+	done := make(chan struct{})
+	f.io = done
+
+	p.mu.Unlock()
+
+	err := file.writePage(f.page, data)
+
+	p.mu.Lock()
+
+	f.io = nil
+	close(done)
+
+	if err != nil {
+		return err
+	}
+
+	f.dirty = false
+
+	return nil
+}
+
+// drop empties the frame without writing its page to its file. The pool must be locked and the frame must not be
+// busy.
+func (p *bufferPool) drop(f *frame) {
+	delete(f.file.pages, f.page)
+	f.file, f.dirty, f.referenced = nil, false, false
+}
+
+// flush writes every dirty page of the pool to its file. The pages stay in the pool.
+func (p *bufferPool) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := 0; i < len(p.frames); {
+		f := p.frames[i]
+
+		if f.io != nil {
+			p.wait(f)
+			continue
+		}
+
+		if f.file != nil && f.dirty {
+			err := p.writeBack(f)
+			if err != nil {
+				return err
+			}
+
+			// the page can have been written to again while it was written back
+			continue
+		}
+
+		i++
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A Store is a data directory that is open. The write-ahead log, the xids and the buffer pool of a directory are
+// shared by every database in it, so a transaction can use the tables of more than one of them, while nothing is
+// shared between the stores of different directories. Every table, transaction and statement belongs to the store
+// it was created, opened or begun with.
+//
+// A directory can only be open once in a process, since the tables of two stores of the same directory would each
+// cache the state of the same files, and their logs would undo each other's writes.
+
+// databaseDirPath is the directory that database files are stored in unless OpenStore is given another one.
+const databaseDirPath = "database"
+
+// Store is an open data directory, see OpenStore.
+//
+// catalogMu is held while a catalog is read or changed, which for a change is until the batch that changes it is
+// committed. It is always locked after the locks of a table, never before. catalogs are the catalogs that are open,
+// by the name of their database.
+type Store struct {
+	dir  string
+	key  string
+	pool *bufferPool
+
+	walMu sync.Mutex
+	wal   *wal
+
+	mvccMu sync.Mutex
+	mvcc   *mvcc
+
+	catalogMu sync.Mutex
+	catalogs  map[string]*catalog
+}
+
+// openStores are the stores that are open, by the absolute path of their directory.
+var (
+	storesMu   sync.Mutex
+	openStores = map[string]*Store{}
+)
+
+// OpenStore opens the data directory, creating it if it does not exist yet, and recovers its write-ahead log, so that
+// every statement that was committed before the database last stopped is applied to the tables. An empty dir is the
+// database directory of the working directory.
+//
+// poolSize is the number of bytes of pages that the buffer pool of the store holds, which must be enough for at least
+// one page, or 0 for the default of 32MB. It is an error if the directory is already open. Close must be called once
+// the store is no longer used.
+func OpenStore(dir string, poolSize int64) (*Store, error) {
+	if dir == "" {
+		dir = databaseDirPath
+	}
+
+	if poolSize == 0 {
+		poolSize = defaultBufferPoolSize
+	}
+
+	if poolSize < pageSize {
+		return nil, fmt.Errorf("buffer pool size must be at least %d bytes, the size of a page", pageSize)
+	}
+
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not find data directory: %w", err)
+	}
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if _, ok := openStores[key]; ok {
+		return nil, fmt.Errorf("data directory %s is already open", dir)
+	}
+
+	s := &Store{
+		dir:      filepath.Clean(dir),
+		key:      key,
+		pool:     newBufferPool(poolSize),
+		catalogs: map[string]*catalog{},
+	}
+
+	err = s.recover()
+	if err != nil {
+		return nil, err
+	}
+
+	openStores[key] = s
+
+	return s, nil
+}
+
+// Dir returns the data directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Close checkpoints the store and closes its xid file, after which the directory can be opened again. Every table of
+// the store must be closed and every transaction ended first.
+func (s *Store) Close() error {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if openStores[s.key] != s {
+		return errors.New("store is already closed")
+	}
+
+	delete(openStores, s.key)
+
+	err := s.Checkpoint()
+
+	s.mvccMu.Lock()
+	defer s.mvccMu.Unlock()
+
+	if s.mvcc != nil {
+		cErr := s.mvcc.file.Close()
+		if err == nil {
+			err = cErr
+		}
+
+		s.mvcc = nil
+	}
+
+	return err
+}
+
+// path returns the path of the file with the name in the data directory.
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// ensureDir creates the data directory if it does not exist yet.
+func (s *Store) ensureDir() error {
+	if _, err := os.Stat(s.dir); errors.Is(err, os.ErrNotExist) {
+		err := os.MkdirAll(s.dir, os.ModePerm)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateTempFile creates a new temporary file in the data directory, using pattern in the same way as os.CreateTemp.
+// The caller is responsible for closing and removing the file.
+func (s *Store) CreateTempFile(pattern string) (*os.File, error) {
+	err := s.ensureDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return os.CreateTemp(s.dir, pattern)
+}
+
+// createFile creates a file at the given path. It will throw an error if the file already exists
+func (s *Store) createFile(path string) (*os.File, error) {
+	// check if database directory exists
+	err := s.ensureDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// check if file exists
+	exists := true
+
+	_, err = os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			exists = false
+		} else {
+			return nil, err
+		}
+	}
+
+	// create file if not exists
+	if exists {
+		return nil, errFileAlreadyExists
+	} else {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return file, nil
+	}
+}
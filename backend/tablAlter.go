@@ -0,0 +1,745 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Drop deletes the table and its indexes. It waits for the statements and transactions that are writing to the table
+// to end, while the statements that are reading it fail once it is dropped, as does every statement that uses it
+// after. The files of the table are removed all together or not at all.
+func (t *table) Drop(ctx context.Context) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("tables can not be dropped inside a transaction")
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	c, unlockCatalog, err := t.store.lockCatalog(t.database)
+	if err != nil {
+		return err
+	}
+	defer unlockCatalog()
+
+	batch := t.store.newWALBatch()
+	batch.remove(t.store.getTableFilePath(t.database, t.Name))
+	batch.remove(t.store.getHeapFilePath(t.database, t.Name))
+
+	for _, index := range t.indexes {
+		batch.remove(t.store.getIndexFilePath(t.database, t.Name, index.Name))
+	}
+
+	update, err := c.update(batch, c.replace(t.Name, nil))
+	if err != nil {
+		return err
+	}
+
+	err = batch.commitFiles()
+	if err != nil {
+		return err
+	}
+
+	update()
+
+	t.failed = fmt.Errorf("%w: %s", ErrTableNotExist, t.Name)
+
+	return t.closeFiles()
+}
+
+// DropUnsupportedTable deletes the table with the name in the database of the context and its indexes without
+// opening it, which is how a table whose format version is not supported anymore is dropped, see
+// ErrUnsupportedTableFormat. Any other table must be opened and dropped with Drop.
+func (s *Store) DropUnsupportedTable(ctx context.Context, name string) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("tables can not be dropped inside a transaction")
+	}
+
+	database := DatabaseFromContext(ctx)
+
+	c, unlock, err := s.lockCatalog(database)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, exists := c.table(name)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrTableNotExist, name)
+	}
+
+	path := s.getTableFilePath(database, name)
+
+	supported, err := isSupportedTableFile(path)
+	if err != nil {
+		return err
+	}
+
+	if supported {
+		return fmt.Errorf("table %s is of a supported format, it has to be opened to be dropped", name)
+	}
+
+	batch := s.newWALBatch()
+	batch.remove(path)
+	batch.remove(s.getHeapFilePath(database, name))
+
+	for _, index := range info.Indexes {
+		batch.remove(s.getIndexFilePath(database, name, index.Name))
+	}
+
+	update, err := c.update(batch, c.replace(name, nil))
+	if err != nil {
+		return err
+	}
+
+	err = batch.commitFiles()
+	if err != nil {
+		return err
+	}
+
+	update()
+
+	return nil
+}
+
+// isSupportedTableFile returns whether the table file at the path is of the format version that is supported. A file
+// that does not exist is not.
+func isSupportedTableFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	t, err := readTableHeader(file, stat.Size())
+	if errors.Is(err, ErrUnsupportedTableFormat) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return t.Version == tableFormatVersion, nil
+}
+
+// Truncate deletes every row of the table at once, emptying its files and indexes. Unlike DeleteRows, no versions
+// are left behind for the snapshots that can still see them, so statements that are reading the table see no more of
+// its rows once it is truncated.
+func (t *table) Truncate(ctx context.Context) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("tables can not be truncated inside a transaction")
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	batch := t.store.newWALBatch()
+	batch.truncate(t.file, t.headerByteCount)
+	batch.truncate(t.heapFile, 0)
+
+	for _, index := range t.indexes {
+		err = index.clear(batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = t.commit(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	t.fileByteCount = t.headerByteCount
+	t.slotCount = 0
+	t.rowCount = 0
+	t.deadCount = 0
+	t.freeSlots = nil
+	t.freeHeap = nil
+	t.heapByteCount = 0
+
+	return nil
+}
+
+// Rename renames the table and its indexes. Like a dropped table, the table can not be used after it is renamed, and
+// has to be opened again with its new name.
+//
+// The name of the table is part of the header of its table file and of its index files, so each of them is copied to
+// a new file with the new header. The new files then replace the old ones all together or not at all.
+func (t *table) Rename(ctx context.Context, newName string) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("tables can not be renamed inside a transaction")
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	c, unlockCatalog, err := t.store.lockCatalog(t.database)
+	if err != nil {
+		return err
+	}
+	defer unlockCatalog()
+
+	if _, exists := c.table(newName); exists {
+		return fmt.Errorf(`table with name "%s" already exists`, newName)
+	}
+
+	if _, ok := systemTableNamed(newName); ok {
+		return fmt.Errorf(`table with name "%s" already exists`, newName)
+	}
+
+	err = validateName("table", newName)
+	if err != nil {
+		return err
+	}
+
+	var temps []*os.File
+	defer func() {
+		for _, temp := range temps {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	renamed := *t
+	renamed.Name = newName
+
+	header, err := renamed.encodeHeader()
+	if err != nil {
+		return err
+	}
+
+	temp, err := t.store.copyWithHeader(t.file, t.headerByteCount, t.fileByteCount-t.headerByteCount, header)
+	if err != nil {
+		return fmt.Errorf("could not copy table file: %w", err)
+	}
+
+	temps = append(temps, temp)
+
+	batch := t.store.newWALBatch()
+	batch.rename(temp.Name(), t.store.getTableFilePath(t.database, newName))
+	batch.remove(t.store.getTableFilePath(t.database, t.Name))
+	batch.rename(t.store.getHeapFilePath(t.database, t.Name), t.store.getHeapFilePath(t.database, newName))
+
+	for _, index := range t.indexes {
+		renamedIndex := *index
+		renamedIndex.Table = newName
+
+		header, err := renamedIndex.encodeHeader()
+		if err != nil {
+			return err
+		}
+
+		temp, err := t.store.copyWithHeader(index.file, index.headerByteCount, index.fileByteCount-index.headerByteCount, header)
+		if err != nil {
+			return fmt.Errorf("could not copy index file: %w", err)
+		}
+
+		temps = append(temps, temp)
+
+		batch.rename(temp.Name(), t.store.getIndexFilePath(t.database, newName, index.Name))
+		batch.remove(t.store.getIndexFilePath(t.database, t.Name, index.Name))
+	}
+
+	info := t.info()
+	info.Name = newName
+
+	update, err := c.update(batch, c.replace(t.Name, &info))
+	if err != nil {
+		return err
+	}
+
+	err = batch.commitFiles()
+	if err != nil {
+		return err
+	}
+
+	update()
+
+	// the temporary files were renamed, so they are only closed
+	for _, temp := range temps {
+		temp.Close()
+	}
+
+	temps = nil
+
+	t.failed = fmt.Errorf("%w: %s, it was renamed to %s", ErrTableNotExist, t.Name, newName)
+
+	return t.closeFiles()
+}
+
+// AddField adds the field after the fields of the table, with def as its value in every row, or NULL if def is nil.
+// def is also the default of the field, so it is the value of the rows inserted after that are not given one. Like a
+// renamed table, the table can not be used after its fields are changed, and has to be opened again. See alter.
+func (t *table) AddField(ctx context.Context, field Field, def interface{}) error {
+	if !field.Type.IsValid() {
+		return fmt.Errorf("%s is not a valid data type", field.Type)
+	}
+
+	if t.HasField(field.Name) {
+		return fmt.Errorf(`field with name "%s" already exists on table "%s"`, field.Name, t.Name)
+	}
+
+	def, err := convertValue(def, field)
+	if err != nil {
+		return err
+	}
+
+	field.Default = def
+	fields := append(t.GetFields(), field)
+
+	sources := make([]int, len(fields))
+	for i := range t.Fields {
+		sources[i] = i
+	}
+	sources[len(t.Fields)] = -1
+
+	return t.alter(ctx, fields, sources, def)
+}
+
+// DropField deletes the field with the name from the table, along with the indexes of the field. See AddField.
+func (t *table) DropField(ctx context.Context, name string) error {
+	if !t.HasField(name) {
+		return fieldNotExistErr(name, t.Name)
+	}
+
+	if len(t.Fields) == 1 {
+		return fmt.Errorf(`field "%s" is the only field of table "%s" and can not be dropped`, name, t.Name)
+	}
+
+	var fields []Field
+	var sources []int
+
+	for i, field := range t.Fields {
+		if field.Name != name {
+			fields = append(fields, field)
+			sources = append(sources, i)
+		}
+	}
+
+	return t.alter(ctx, fields, sources, nil)
+}
+
+// AlterField replaces the field with the name with the given field, which renames it if the name of the field is
+// different, and converts the value of the field in every row if its type is, see convertValue. The field keeps its
+// default, converted as well, unless the given field has one. See AddField.
+func (t *table) AlterField(ctx context.Context, name string, field Field) error {
+	if !field.Type.IsValid() {
+		return fmt.Errorf("%s is not a valid data type", field.Type)
+	}
+
+	if !t.HasField(name) {
+		return fieldNotExistErr(name, t.Name)
+	}
+
+	if field.Name != name && t.HasField(field.Name) {
+		return fmt.Errorf(`field with name "%s" already exists on table "%s"`, field.Name, t.Name)
+	}
+
+	fields := t.GetFields()
+	sources := make([]int, len(fields))
+
+	for i := range fields {
+		if fields[i].Name == name {
+			if field.Default == nil {
+				def, err := convertValue(fields[i].Default, field)
+				if err != nil {
+					return fmt.Errorf("the default of field %s can not be kept: %w", name, err)
+				}
+
+				field.Default = def
+			}
+
+			fields[i] = field
+		}
+
+		sources[i] = i
+	}
+
+	return t.alter(ctx, fields, sources, nil)
+}
+
+// alterBatchRows is the number of rows that alter encodes before writing them to the new files.
+const alterBatchRows = 1024
+
+// alter changes the fields of the table to the given fields. sources are the indexes of the field of the table that
+// each of the fields takes its values from, whose values are converted to the type of the field, or -1 for the field
+// to be def in every row. The indexes of a field that is not a source are dropped.
+//
+// Every row version, and every index, is rewritten into new files with the new fields. The rows are copied while the
+// table is locked for writing, but not with mrw, so the statements that are reading the table can keep reading it
+// until the new files replace the old ones, all together or not at all. The versions keep their xmin and xmax, so the
+// snapshots that could see them before still do, but the dead versions that no snapshot can see are vacuumed first,
+// and free slots are not copied.
+func (t *table) alter(ctx context.Context, fields []Field, sources []int, def interface{}) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("tables can not be altered inside a transaction")
+	}
+
+	err := checkRowSize(fields)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = t.vacuum()
+	if err != nil {
+		return err
+	}
+
+	var temps []*os.File
+	defer func() {
+		for _, temp := range temps {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	createTemp := func() (*os.File, error) {
+		temp, err := t.store.CreateTempFile("alter-*.tmp")
+		if err != nil {
+			return nil, fmt.Errorf("could not create file for altered table: %w", err)
+		}
+
+		temps = append(temps, temp)
+
+		return temp, nil
+	}
+
+	altered := &table{Name: t.Name, Fields: fields, Version: tableFormatVersion, rowByteCount: calculateRowSize(fields)}
+
+	altered.file, err = createTemp()
+	if err != nil {
+		return err
+	}
+
+	altered.heapFile, err = createTemp()
+	if err != nil {
+		return err
+	}
+
+	header, err := altered.encodeHeader()
+	if err != nil {
+		return err
+	}
+
+	altered.headerByteCount = int64(len(header))
+
+	batch := t.store.newWALBatch()
+	batch.writeAt(altered.file, header, 0)
+
+	// the entries of every index that is kept, with the entries of the versions that are not deleted as live
+	type rebuild struct {
+		index   *tableIndex
+		source  int
+		entries []indexEntry
+		live    []indexEntry
+	}
+
+	var rebuilds []*rebuild
+	var dropped []*tableIndex
+
+	for _, index := range t.indexes {
+		kept := false
+
+		for i, source := range sources {
+			if source == index.fieldIndex {
+				rebuiltIndex := &tableIndex{Index: index.Index, Table: t.Name, Version: indexFormatVersion, fieldIndex: i}
+				rebuiltIndex.FieldName = fields[i].Name
+
+				rebuilds = append(rebuilds, &rebuild{index: rebuiltIndex, source: source})
+				kept = true
+			}
+		}
+
+		if !kept {
+			dropped = append(dropped, index)
+		}
+	}
+
+	err = t.scanVersions(func(slot int64, rowBytes []byte) error {
+		if isFree(rowBytes) {
+			return nil
+		}
+
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		decoded, err := t.decodeRow(rowBytes)
+		if err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(fields))
+		for i, source := range sources {
+			if source < 0 {
+				values[i] = def
+				continue
+			}
+
+			values[i], err = convertValue(decoded[source].Val, fields[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		b, err := altered.encodeRow(batch, rowXmin(rowBytes), values, nil)
+		if err != nil {
+			return err
+		}
+
+		// the version keeps its xmax as well as its xmin
+		copy(b, rowBytes[:rowHeaderSize])
+
+		newSlot := altered.slotCount
+		altered.writeRow(batch, newSlot, b)
+		altered.slotCount++
+
+		for _, r := range rebuilds {
+			if key, ok := indexKey(values[r.index.fieldIndex]); ok {
+				r.entries = append(r.entries, indexEntry{key: key, row: newSlot})
+
+				// every other transaction that wrote to the table has ended, so a version that is not deleted is the latest
+				if rowXmax(rowBytes) == 0 {
+					r.live = append(r.live, indexEntry{key: key, row: newSlot})
+				}
+			}
+		}
+
+		if altered.slotCount%alterBatchRows == 0 {
+			return batch.applyUnlogged()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = batch.applyUnlogged()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rebuilds {
+		if r.index.Unique {
+			err = checkUniqueEntries(r.index.Index, fields[r.index.fieldIndex], r.live)
+			if err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(r.entries, func(i, j int) bool { return r.entries[i].compare(r.entries[j]) < 0 })
+
+		r.index.file, err = createTemp()
+		if err != nil {
+			return err
+		}
+
+		err = r.index.writeIndexFile(batch, r.entries)
+		if err == nil {
+			err = batch.applyUnlogged()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, temp := range temps {
+		err = temp.Sync()
+		if err != nil {
+			return fmt.Errorf("could not sync file of altered table: %w", err)
+		}
+	}
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	batch.rename(altered.file.Name(), t.store.getTableFilePath(t.database, t.Name))
+	batch.rename(altered.heapFile.Name(), t.store.getHeapFilePath(t.database, t.Name))
+
+	for _, r := range rebuilds {
+		batch.rename(r.index.file.Name(), t.store.getIndexFilePath(t.database, t.Name, r.index.Name))
+	}
+
+	for _, index := range dropped {
+		batch.remove(t.store.getIndexFilePath(t.database, t.Name, index.Name))
+	}
+
+	c, unlockCatalog, err := t.store.lockCatalog(t.database)
+	if err != nil {
+		return err
+	}
+	defer unlockCatalog()
+
+	info := TableInfo{Name: t.Name, Fields: fields}
+	for _, r := range rebuilds {
+		info.Indexes = append(info.Indexes, r.index.Index)
+	}
+
+	update, err := c.update(batch, c.replace(t.Name, &info))
+	if err != nil {
+		return err
+	}
+
+	err = batch.commitFiles()
+	if err != nil {
+		return err
+	}
+
+	update()
+
+	// the temporary files were renamed, so they are only closed
+	for _, temp := range temps {
+		temp.Close()
+	}
+
+	temps = nil
+
+	t.failed = fmt.Errorf("%w: %s, it has to be opened again", ErrTableAltered, t.Name)
+
+	return t.closeFiles()
+}
+
+// convertValue converts the value to the type of the field. NULL stays NULL, ints and floats are converted to each
+// other as long as a float is a whole number, and every value can be converted to a string and parsed back from one.
+// It is an error if the value can not be converted, or is too long for the field.
+func convertValue(val interface{}, field Field) (interface{}, error) {
+	var converted interface{}
+
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var err error
+
+		switch field.Type {
+		case PrimitiveString:
+			converted = v
+		case PrimitiveInt:
+			converted, err = strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		case PrimitiveFloat:
+			converted, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		case PrimitiveBool:
+			converted, err = strconv.ParseBool(strings.TrimSpace(v))
+		}
+
+		if err != nil {
+			converted = nil
+		}
+	case int64:
+		switch field.Type {
+		case PrimitiveInt:
+			converted = v
+		case PrimitiveFloat:
+			converted = float64(v)
+		case PrimitiveString:
+			converted = strconv.FormatInt(v, 10)
+		}
+	case float64:
+		switch field.Type {
+		case PrimitiveFloat:
+			converted = v
+		case PrimitiveInt:
+			if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+				converted = int64(v)
+			}
+		case PrimitiveString:
+			converted = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	case bool:
+		switch field.Type {
+		case PrimitiveBool:
+			converted = v
+		case PrimitiveString:
+			converted = strconv.FormatBool(v)
+		}
+	}
+
+	if converted == nil {
+		return nil, fmt.Errorf("value %v of field %s can not be converted to %s", val, field.Name, field.Type)
+	}
+
+	return converted, field.validateValue(converted)
+}
+
+// copyWithHeader copies the size bytes that come after the header of the file, which is headerByteCount bytes, to a
+// new temporary file of the store after the given header instead, and syncs it. The caller is responsible for closing
+// and removing the file.
+func (s *Store) copyWithHeader(file io.ReaderAt, headerByteCount int64, size int64, header []byte) (*os.File, error) {
+	temp, err := s.CreateTempFile("copy-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = temp.Write(header)
+	if err == nil {
+		_, err = io.Copy(temp, io.NewSectionReader(file, headerByteCount, size))
+	}
+
+	if err == nil {
+		err = temp.Sync()
+	}
+
+	if err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return nil, err
+	}
+
+	return temp, nil
+}
@@ -0,0 +1,264 @@
+package backend
+
+import "context"
+
+// RowIterator is a stream of rows that are read as they are needed. Next must be called before the first row can be
+// read with Row. Once Next returns false, Err should be checked to tell an exhausted iterator apart from a failed one.
+// Close must always be called.
+type RowIterator interface {
+	Next() bool
+	Row() Row
+	Err() error
+	Close() error
+}
+
+// scanChunkSize is the number of slots that a slotReader reads at a time.
+const scanChunkSize = 256
+
+// slotReader reads the rows of a table a chunk of scanChunkSize slots at a time. The table is only locked for reading
+// while a chunk is read, so writers never wait for longer than that. It reads the slots of slots, which must be sorted,
+// or if all is true every slot of the table, including those added while it reads. Slots that no longer exist, because
+// the versions in them were rolled back, are skipped. The rows are copied out of the pages of the table into chunk,
+// since the pages can change once the table is unlocked.
+type slotReader struct {
+	t     *table
+	slots []int64
+	all   bool
+	next  int64
+	pages *pageReader
+	chunk []byte
+	read  []int64
+}
+
+func newSlotReader(t *table, slots []int64, all bool) *slotReader {
+	return &slotReader{t: t, slots: slots, all: all, pages: newPageReader(t), chunk: make([]byte, scanChunkSize*t.rowByteCount)}
+}
+
+// readChunk reads the next chunk of slots into read and chunk. It returns false once every slot has been read.
+func (r *slotReader) readChunk() (bool, error) {
+	t := r.t
+	r.read = r.read[:0]
+
+	t.mrw.RLock()
+	defer t.mrw.RUnlock()
+
+	if t.failed != nil {
+		return false, t.failed
+	}
+
+	r.pages.reset()
+
+	for len(r.read) < scanChunkSize {
+		var slot int64
+
+		if r.all {
+			if r.next >= t.slotCount {
+				break
+			}
+
+			slot = r.next
+			r.next++
+		} else {
+			if len(r.slots) == 0 {
+				break
+			}
+
+			slot = r.slots[0]
+			r.slots = r.slots[1:]
+
+			if slot >= t.slotCount {
+				continue
+			}
+		}
+
+		rowBytes, err := r.pages.row(slot)
+		if err != nil {
+			return false, err
+		}
+
+		copy(r.rowBytes(len(r.read)), rowBytes)
+		r.read = append(r.read, slot)
+	}
+
+	return len(r.read) != 0, nil
+}
+
+// rowBytes returns the encoded row at position i of the chunk.
+func (r *slotReader) rowBytes(i int) []byte {
+	return r.chunk[int64(i)*r.t.rowByteCount : int64(i+1)*r.t.rowByteCount]
+}
+
+// scanVersions is like scanSlots, except that the rows are read with a slotReader, and fn is called without the table
+// being locked. The table must not be locked with mrw.
+func (t *table) scanVersions(fn func(slot int64, rowBytes []byte) error) error {
+	reader := newSlotReader(t, nil, true)
+
+	for {
+		more, err := reader.readChunk()
+		if err != nil || !more {
+			return err
+		}
+
+		for i, slot := range reader.read {
+			err = fn(slot, reader.rowBytes(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rowCursor is a RowIterator over the row versions of a table that are visible to a snapshot and satisfy the
+// filters, which stops once limit rows have been returned unless limit is negative. The rows only have the values
+// of the fields that are selected, or every field if selected is nil. release is called once it is closed.
+//
+// The rows are read a chunk at a time, and the scan stops at the next chunk once the context is cancelled.
+type rowCursor struct {
+	ctx      context.Context
+	t        *table
+	snap     *snapshot
+	release  func()
+	filters  []Filter
+	offsets  map[string]cellOffset
+	selected []bool
+	limit    int
+	returned int
+	reader   *slotReader
+	position int
+	row      Row
+	err      error
+	closed   bool
+}
+
+// newRowCursor returns a cursor over the rows of the table visible to the snapshot that satisfy the filters, which are
+// validated first. The indexes of the table are used to find the rows if the filters are selective enough.
+func (t *table) newRowCursor(ctx context.Context, snap *snapshot, filters []Filter, limit int) (*rowCursor, error) {
+	for _, filter := range filters {
+		err := t.validateFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.mrw.RLock()
+	slots, indexed := t.indexedSlots(filters)
+	t.mrw.RUnlock()
+
+	return &rowCursor{
+		ctx:     ctx,
+		t:       t,
+		snap:    snap,
+		release: func() {},
+		filters: filters,
+		offsets: t.fieldOffsets(),
+		limit:   limit,
+		reader:  newSlotReader(t, slots, !indexed),
+	}, nil
+}
+
+func (c *rowCursor) Next() bool {
+	for c.err == nil && !c.closed && (c.limit < 0 || c.returned < c.limit) {
+		if c.position == len(c.reader.read) {
+			if c.err = c.ctx.Err(); c.err != nil {
+				return false
+			}
+
+			more, err := c.reader.readChunk()
+			if err != nil || !more {
+				c.err = err
+				return false
+			}
+
+			c.position = 0
+		}
+
+		slot := c.reader.read[c.position]
+		rowBytes := c.reader.rowBytes(c.position)
+		c.position++
+
+		if isFree(rowBytes) || !c.snap.visible(rowXmin(rowBytes), rowXmax(rowBytes)) {
+			continue
+		}
+
+		satisfies, err := c.t.rowSatisfiesAll(rowBytes, c.offsets, c.filters)
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		if !satisfies {
+			continue
+		}
+
+		values, err := c.t.decodeRow(rowBytes)
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		if c.selected != nil {
+			selectedValues := make([]Value, 0, len(values))
+			for i, val := range values {
+				if c.selected[i] {
+					selectedValues = append(selectedValues, val)
+				}
+			}
+
+			values = selectedValues
+		}
+
+		c.row = Row{Values: values, index: slot, xmax: rowXmax(rowBytes)}
+		c.returned++
+
+		return true
+	}
+
+	return false
+}
+
+func (c *rowCursor) Row() Row {
+	return c.row
+}
+
+func (c *rowCursor) Err() error {
+	return c.err
+}
+
+func (c *rowCursor) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.release()
+	}
+
+	return nil
+}
+
+// ScanRows returns an iterator over the selected fields of the rows of the table that match the filter, which reads
+// the rows as they are needed instead of all at once. If fields is a zero length slice, all fields are selected. If
+// the filter is nil, all rows are returned. At most limit rows are returned, in the order they are stored, unless
+// limit is NoLimit.
+//
+// The iterator reads from the snapshot of the statement of the context, which it holds until it is closed.
+// Cancelling the context stops the iterator, which then returns the error of the context.
+func (t *table) ScanRows(ctx context.Context, fields []string, filters []Filter, limit int) (RowIterator, error) {
+	selected, err := t.selectedFields(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, release, err := t.store.statementSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := t.newRowCursor(ctx, snap, filters, limit)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	cursor.selected = selected
+	cursor.release = release
+
+	return cursor, nil
+}
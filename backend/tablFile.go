@@ -2,84 +2,163 @@ package backend
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 )
 
-// CreateTable creates a table and returns the table corresponding table struct.
-func CreateTable(ctx context.Context, name string, fields []Field) (OperableTable, error) {
-	path := getTableFilePath(name)
+// CreateTable creates a table of the store in the database of the context and returns the table corresponding table
+// struct.
+func (s *Store) CreateTable(ctx context.Context, name string, fields []Field) (OperableTable, error) {
+	if transactionFromContext(ctx) != nil {
+		return nil, errors.New("tables can not be created inside a transaction")
+	}
+
+	if _, ok := systemTableNamed(name); ok {
+		return nil, fmt.Errorf(`table with name "%s" already exists`, name)
+	}
 
-	file, err := createFile(path)
+	err := validateName("table", name)
 	if err != nil {
-		if errors.Is(err, errFileAlreadyExists) {
-			return nil, fmt.Errorf(`table with name "%s" already exists`, name)
-		} else {
-			return nil, fmt.Errorf("could not create table db file: %w", err)
+		return nil, err
+	}
+
+	err = checkRowSize(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	database := DatabaseFromContext(ctx)
+
+	c, unlock, err := s.lockCatalog(database)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if _, exists := c.table(name); exists {
+		return nil, fmt.Errorf(`table with name "%s" already exists`, name)
+	}
+
+	path := s.getTableFilePath(database, name)
+
+	// files of the table that are not in the catalog were left behind by a CREATE TABLE that never finished
+	for _, leftover := range []string{path, s.getHeapFilePath(database, name)} {
+		err = os.Remove(leftover)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not remove %s: %w", leftover, err)
 		}
 	}
 
+	file, err := s.createPageFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create table db file: %w", err)
+	}
+
+	heapFile, err := s.createPageFile(s.getHeapFilePath(database, name))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not create table heap file: %w", err)
+	}
+
 	var lock sync.RWMutex
 
 	table := table{
 		mrw:          &lock,
+		lock:         &tableLock{},
 		rowCount:     0,
 		file:         file,
+		heapFile:     heapFile,
+		store:        s,
+		database:     database,
 		Name:         name,
 		Fields:       fields,
+		Version:      tableFormatVersion,
 		rowByteCount: calculateRowSize(fields),
 	}
 
 	fmt.Println("creating table: ")
 	fmt.Println(table)
 
+	batch := s.newWALBatch()
+
 	// write the table struct to the file to act as a table and schema
-	err = table.writeTableHeader()
+	err = table.writeTableHeader(batch)
+	if err != nil {
+		file.Close()
+		heapFile.Close()
+		return nil, fmt.Errorf("could not write table header: %w", err)
+	}
+
+	info := table.info()
+
+	update, err := c.update(batch, c.replace(name, &info))
+	if err == nil {
+		err = batch.commit()
+	}
+
 	if err != nil {
+		file.Close()
+		heapFile.Close()
 		return nil, fmt.Errorf("could not write table header: %w", err)
 	}
 
+	update()
+
 	return &table, nil
 }
 
-func getTableFilePath(name string) string {
-	return fmt.Sprintf("./database/%s-db", name)
+// tableFormatVersion is the version of the layout of table files that this package reads and writes. It is stored in
+// the header of every table file and must be incremented whenever the layout changes.
+//
+// Version 1 added a null bitmap to the start of every row. Version 2 moved long strings into the heap file. Version 3
+// added a row header, so deleted rows are marked instead of removed, and no longer reserves space after the header.
+// Version 4 added the xmin and xmax of the version of the row to the row header. Version 5 stored the rows in pages
+// with a slot directory, see pageHeaderSize.
+const tableFormatVersion = 5
+
+func (s *Store) getTableFilePath(database string, name string) string {
+	return s.databasePath(database, fmt.Sprintf("%s-db", name))
 }
 
-// writeTableHeader writes the header to initialize a table file. It will flush a table if there is already data.
-func (t *table) writeTableHeader() error {
-	t.mrw.Lock()
-	defer t.mrw.Unlock()
+// getHeapFilePath returns the path of the file that the strings of a table that do not fit in their cell are stored
+// in. Strings are only ever appended to it, the strings of rows that are deleted or updated are not reclaimed.
+func (s *Store) getHeapFilePath(database string, name string) string {
+	return s.databasePath(database, fmt.Sprintf("%s-heap", name))
+}
 
-	data, err := json.Marshal(*t)
+// writeTableHeader adds the write of the header that initializes the table file to the batch.
+func (t *table) writeTableHeader(batch *walBatch) error {
+	header, err := t.encodeHeader()
 	if err != nil {
-		return fmt.Errorf("could not encode table metadata: %w", err)
+		return err
 	}
 
-	headerByteCount := len(data) + 8
+	batch.writeAt(t.file, header, 0)
 
-	// begin header with an unsigned i64 that is the number of bytes of the table size, including the number itself
-	header := i64ToB(int64(headerByteCount))
-	header = append(header, data...)
+	t.headerByteCount = int64(len(header))
+	t.fileByteCount = int64(len(header))
 
-	// the header also contains an uint64 slice of capacity 200 that acts as a buffer to minimize array shift operations
-	// when a row is deleted, the row's index is added to this array and on insertion, rows should be inserted into
-	// the next value in this array. If the buffer is exceeded, then a whole table shift operation must be performed
-	freeRows := make([]byte, 200*64)
-	header = append(header, freeRows...)
+	return nil
+}
 
-	_, err = t.file.Write(header)
+// encodeHeader returns the header of the table file, which is the metadata of the table as JSON, padded to a whole
+// number of pages.
+func (t *table) encodeHeader() ([]byte, error) {
+	data, err := json.Marshal(*t)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("could not encode table metadata: %w", err)
 	}
 
-	t.headerByteCount = int64(headerByteCount)
-	t.fileByteCount = int64(headerByteCount)
+	// begin header with an unsigned i64 that is the number of bytes of the table size, including the number itself
+	header := i64ToB(int64(len(data) + 8))
+	header = append(header, data...)
 
-	return nil
+	return append(header, make([]byte, pageAlign(int64(len(header)))-int64(len(header)))...), nil
 }
 
 /*
@@ -87,18 +166,37 @@ Below functions are used to read a table file and perform operators on an alread
 existing table
 */
 
-// OpenTable returns a table struct that tableReader and tableWriters can attach to. If table with given name does not
-// exist, an error will be returned. An opened table needs to be cleaned up later through the Cleanable interface
-func OpenTable(ctx context.Context, name string) (OperableTable, error) {
-	path := getTableFilePath(name)
+// OpenTable returns a table struct of the store in the database of the context that tableReader and tableWriters can attach to. If
+// table with given name does not exist, an error will be returned. An opened table needs to be cleaned up later through the Cleanable interface. The
+// write-ahead log is recovered before the table is read, if it has not been already.
+func (s *Store) OpenTable(ctx context.Context, name string) (OperableTable, error) {
+	database := DatabaseFromContext(ctx)
 
-	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	c, unlock, err := s.lockCatalog(database)
+	if err != nil {
+		return nil, err
+	}
+
+	info, exists := c.table(name)
+	unlock()
+
+	if system, ok := systemTableNamed(name); ok {
+		system.store = s
+		system.database = database
+		return system, nil
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotExist, name)
+	}
+
+	path := s.getTableFilePath(database, name)
+
+	f, err := s.openPageFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			f.Close()
-			return nil, fmt.Errorf("table with name %s does not exist", name)
+			return nil, fmt.Errorf("%w: %s", ErrTableNotExist, name)
 		} else {
-			f.Close()
 			return nil, fmt.Errorf("could not open table file: %w", err)
 		}
 	}
@@ -109,25 +207,103 @@ func OpenTable(ctx context.Context, name string) (OperableTable, error) {
 		return nil, fmt.Errorf("could not read table file: %w", err)
 	}
 
+	heapFile, err := s.openPageFile(s.getHeapFilePath(database, name))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not open table heap file: %w", err)
+	}
+
+	stat, err := heapFile.Stat()
+	if err != nil {
+		f.Close()
+		heapFile.Close()
+		return nil, fmt.Errorf("could not open heap file stats: %w", err)
+	}
+
+	table.heapFile = heapFile
+	table.heapByteCount = stat.Size()
+	table.store = s
+	table.database = database
+
+	err = table.countRows()
+	if err != nil {
+		f.Close()
+		heapFile.Close()
+		return nil, fmt.Errorf("could not read table file: %w", err)
+	}
+
+	indexes, err := openIndexes(table, info.Indexes)
+	if err != nil {
+		f.Close()
+		heapFile.Close()
+		return nil, err
+	}
+
+	table.indexes = indexes
+
 	var lock sync.RWMutex
 	table.mrw = &lock
+	table.lock = &tableLock{}
 
 	return table, nil
 }
 
 // readTableFile reads a tableFile's header to create a table struct that can then be used for operations.
-func readTableFile(file *os.File) (*table, error) {
-	headerSizeBytes := make([]byte, 8)
+func readTableFile(file dataFile) (*table, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not open file stats: %w", err)
+	}
 
-	_, err := file.Read(headerSizeBytes)
+	table, err := readTableHeader(file, stat.Size())
 	if err != nil {
 		return nil, err
 	}
 
+	if table.Version != tableFormatVersion {
+		return nil, fmt.Errorf("%w: table %s is stored in format version %d, but only version %d is supported, the table has to be dropped and created again", ErrUnsupportedTableFormat, table.Name, table.Version, tableFormatVersion)
+	}
+
+	err = checkRowSize(table.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	table.file = file
+	table.rowByteCount = calculateRowSize(table.Fields)
+
+	// the header is padded to the first page of rows
+	table.headerByteCount = pageAlign(table.headerByteCount)
+
+	table.fileByteCount = stat.Size()
+
+	table.slotCount, err = table.countSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// readTableHeader reads the header of a table file of size bytes, which is of any format version whose header starts
+// with its size. The headers of older versions can not always be read, which returns ErrUnsupportedTableFormat.
+func readTableHeader(file io.ReaderAt, size int64) (*table, error) {
+	headerSizeBytes := make([]byte, 8)
+
+	_, err := file.ReadAt(headerSizeBytes, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the size of the header: %w", err)
+	}
+
+	// the first versions stored the size in another encoding
 	headerSize := bToI64(headerSizeBytes)
+	if headerSize < 8 || headerSize > size {
+		return nil, fmt.Errorf("%w: the header is of a format version that is not supported anymore, the table has to be dropped and created again", ErrUnsupportedTableFormat)
+	}
+
 	header := make([]byte, headerSize-8)
 
-	_, err = file.Read(header)
+	_, err = file.ReadAt(header, 8)
 	if err != nil {
 		return nil, err
 	}
@@ -135,28 +311,111 @@ func readTableFile(file *os.File) (*table, error) {
 	var table table
 	err = json.Unmarshal(header, &table)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: could not read the header: %s", ErrUnsupportedTableFormat, err)
 	}
 
 	table.headerByteCount = headerSize
-	table.file = file
-	table.rowByteCount = calculateRowSize(table.Fields)
 
-	stat, err := file.Stat()
+	return &table, nil
+}
+
+// countRows counts the row versions that are not deleted and the ones that are, and collects the free slots and the
+// ranges of the heap file that no version refers to so they can be reused. heapByteCount must be set.
+func (t *table) countRows() error {
+	t.rowCount = 0
+	t.deadCount = 0
+	t.freeSlots = nil
+
+	var used []heapRange
+
+	err := t.scanSlots(func(slot int64, rowBytes []byte) error {
+		if isFree(rowBytes) {
+			t.freeSlots = append(t.freeSlots, slot)
+			return nil
+		}
+
+		if rowXmax(rowBytes) != 0 {
+			t.deadCount++
+		} else {
+			t.rowCount++
+		}
+
+		used = append(used, t.heapRanges(rowBytes)...)
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not open file stats: %w", err)
+		return err
 	}
 
-	table.fileByteCount = stat.Size()
-	table.rowCount = (table.fileByteCount - table.headerByteCount) / table.rowByteCount
+	t.freeHeap = unusedHeapRanges(used, t.heapByteCount)
 
-	return &table, nil
+	return nil
+}
+
+// reload reads the state of the table and its indexes from their files again, after the files were changed by
+// rolling back a transaction. The table must be locked for writing.
+func (t *table) reload() error {
+	stat, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("could not open file stats: %w", err)
+	}
+
+	t.fileByteCount = stat.Size()
+
+	t.slotCount, err = t.countSlots()
+	if err != nil {
+		return err
+	}
+
+	stat, err = t.heapFile.Stat()
+	if err != nil {
+		return fmt.Errorf("could not open heap file stats: %w", err)
+	}
+
+	t.heapByteCount = stat.Size()
+
+	err = t.countRows()
+	if err != nil {
+		return err
+	}
+
+	for _, index := range t.indexes {
+		err = index.reload()
+		if err != nil {
+			return fmt.Errorf("could not read index file %s: %w", index.file.Name(), err)
+		}
+	}
+
+	t.failed = nil
+
+	return nil
+}
+
+// scanSlots calls fn with the encoded row of every slot of the table, in order, including free slots. The rowBytes
+// slice is only valid until fn returns. The table must be locked for reading, or for writing with lockForWrite.
+func (t *table) scanSlots(fn func(slot int64, rowBytes []byte) error) error {
+	reader := newPageReader(t)
+
+	for slot := int64(0); slot < t.slotCount; slot++ {
+		rowBytes, err := reader.row(slot)
+		if err != nil {
+			return err
+		}
+
+		err = fn(slot, rowBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // calculateRowSize calculates the numbers of bytes each row of the table takes. This should be called on table
 // initialization and stored into the table struct.
 func calculateRowSize(fields []Field) int64 {
-	var sum int64
+	sum := rowHeaderSize + nullBitmapSize(len(fields))
 
 	for _, field := range fields {
 		sum += field.Type.Size()
@@ -164,3 +423,167 @@ func calculateRowSize(fields []Field) int64 {
 
 	return sum
 }
+
+// Every row starts with a header of rowHeaderSize bytes. The first byte is a set of flags about the slot, followed by
+// the xmin and then the xmax of the version of the row as uint64s, see mvcc. The xmax of a version that has not been
+// deleted is 0.
+const (
+	rowHeaderSize = 17
+	rowXmaxOffset = 9
+)
+
+// rowFree is the flag of a slot whose row version was reclaimed by vacuum. Slots are never moved, which lets indexes
+// refer to rows by their slot, and a free slot is reused by the next row version that is written.
+const rowFree byte = 1 << 0
+
+// isFree returns whether the encoded row is of a free slot.
+func isFree(rowBytes []byte) bool {
+	return rowBytes[0]&rowFree != 0
+}
+
+// rowXmin returns the xid of the transaction that created the encoded row version.
+func rowXmin(rowBytes []byte) uint64 {
+	return binary.BigEndian.Uint64(rowBytes[1:])
+}
+
+// rowXmax returns the xid of the transaction that deleted the encoded row version, or 0 if it has not been deleted.
+func rowXmax(rowBytes []byte) uint64 {
+	return binary.BigEndian.Uint64(rowBytes[rowXmaxOffset:])
+}
+
+// nullBitmapSize returns the number of bytes of the null bitmap that comes after the row header. It has a bit for
+// each field, in order, which is set if the field's value is NULL. The cell of a NULL value is left as zero bytes.
+func nullBitmapSize(fieldCount int) int64 {
+	return int64((fieldCount + 7) / 8)
+}
+
+// isNull returns whether the null bitmap of the encoded row marks the field at the index as NULL.
+func isNull(rowBytes []byte, index int) bool {
+	return rowBytes[rowHeaderSize+index/8]&(1<<(index%8)) != 0
+}
+
+// encodeRow encodes the values, which are in the order of the table's fields, into a row version created by the
+// transaction of the xid. A nil value is NULL. Strings that do not fit in their cell are written to the heap file, so
+// the table must be locked for writing. prev is the encoded version that the new one replaces, or nil, whose strings
+// in the heap file are referred to again by the new version when they are not changed.
+func (t *table) encodeRow(batch *walBatch, xid uint64, values []interface{}, prev []byte) ([]byte, error) {
+	for i, field := range t.Fields {
+		err := field.validateValue(values[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := make([]byte, t.rowByteCount)
+	binary.BigEndian.PutUint64(b[1:], xid)
+
+	cursor := rowHeaderSize + nullBitmapSize(len(t.Fields))
+
+	for i, field := range t.Fields {
+		cell := b[cursor : cursor+field.Type.Size()]
+		cursor += field.Type.Size()
+
+		switch val := values[i].(type) {
+		case nil:
+			b[rowHeaderSize+i/8] |= 1 << (i % 8)
+		case string:
+			var prevCell []byte
+			if prev != nil && !isNull(prev, i) {
+				prevCell = prev[cursor-field.Type.Size() : cursor]
+			}
+
+			err := t.encodeStringCell(batch, cell, val, prevCell)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			copy(cell, anyToB(val))
+		}
+	}
+
+	return b, nil
+}
+
+// decodeRow decodes the values of every field of an encoded row. The table must be locked for reading.
+func (t *table) decodeRow(rowBytes []byte) ([]Value, error) {
+	values := make([]Value, len(t.Fields))
+	cursor := rowHeaderSize + nullBitmapSize(len(t.Fields))
+
+	for i, field := range t.Fields {
+		values[i] = Value{Type: field.Type, FieldName: field.Name}
+
+		if !isNull(rowBytes, i) {
+			b, err := t.cellBytes(rowBytes[cursor:cursor+field.Type.Size()], field.Type)
+			if err != nil {
+				return nil, err
+			}
+
+			values[i].Val = bToAny(b, field.Type)
+		}
+
+		cursor += field.Type.Size()
+	}
+
+	return values, nil
+}
+
+// A string cell is stringCellSize bytes. The first 4 bytes are the length of the string in bytes. Strings of up to
+// inlineStringSize bytes are stored in the rest of the cell, while longer strings are stored in the heap file and the
+// next 8 bytes of the cell are their offset in it. This keeps the rows of a table a fixed size, while strings only
+// take up as much space as their content.
+const (
+	stringCellSize   = 16
+	inlineStringSize = stringCellSize - 4
+)
+
+// encodeStringCell encodes the string into the cell, writing it to the heap file if it is too long to be inlined. If
+// prev is the cell of the same string in an older version, the new cell refers to the same bytes of the heap file
+// instead. The string must be at most math.MaxUint32 bytes.
+func (t *table) encodeStringCell(batch *walBatch, cell []byte, s string, prev []byte) error {
+	binary.BigEndian.PutUint32(cell, uint32(len(s)))
+
+	if len(s) <= inlineStringSize {
+		copy(cell[4:], s)
+		return nil
+	}
+
+	if prev != nil && binary.BigEndian.Uint32(prev) == uint32(len(s)) {
+		b, err := t.cellBytes(prev, PrimitiveString)
+		if err != nil {
+			return err
+		}
+
+		if string(b) == s {
+			copy(cell, prev)
+			return nil
+		}
+	}
+
+	offset := t.allocateHeap(int64(len(s)))
+	batch.writeAt(t.heapFile, sToB(s), offset)
+
+	binary.BigEndian.PutUint64(cell[4:], uint64(offset))
+
+	return nil
+}
+
+// cellBytes returns the encoded value of a cell, which for strings is read from the heap file if it is not inlined.
+func (t *table) cellBytes(cell []byte, as Primitive) ([]byte, error) {
+	if as != PrimitiveString {
+		return cell, nil
+	}
+
+	length := binary.BigEndian.Uint32(cell)
+	if length <= inlineStringSize {
+		return cell[4 : 4+length], nil
+	}
+
+	b := make([]byte, length)
+
+	_, err := t.heapFile.ReadAt(b, int64(binary.BigEndian.Uint64(cell[4:])))
+	if err != nil {
+		return nil, fmt.Errorf("could not read from heap file: %w", err)
+	}
+
+	return b, nil
+}
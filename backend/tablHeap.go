@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// A heapRange is a range of bytes of the heap file, which holds a string that does not fit in its cell. Versions of
+// a row that keep a string refer to the same range, which is freed by vacuum once no version refers to it anymore.
+type heapRange struct {
+	offset int64
+	length int64
+}
+
+// heapRanges returns the ranges of the heap file that the string cells of the encoded row refer to.
+func (t *table) heapRanges(rowBytes []byte) []heapRange {
+	var ranges []heapRange
+
+	cursor := rowHeaderSize + nullBitmapSize(len(t.Fields))
+
+	for i, field := range t.Fields {
+		cell := rowBytes[cursor : cursor+field.Type.Size()]
+		cursor += field.Type.Size()
+
+		if field.Type != PrimitiveString || isNull(rowBytes, i) {
+			continue
+		}
+
+		length := binary.BigEndian.Uint32(cell)
+		if length > inlineStringSize {
+			ranges = append(ranges, heapRange{offset: int64(binary.BigEndian.Uint64(cell[4:])), length: int64(length)})
+		}
+	}
+
+	return ranges
+}
+
+// allocateHeap returns the offset in the heap file that a string of length bytes is written to. The first free range
+// that is long enough is reused, and the string is appended to the file if there is none. The table must be locked
+// for writing.
+func (t *table) allocateHeap(length int64) int64 {
+	for i, free := range t.freeHeap {
+		if free.length < length {
+			continue
+		}
+
+		if free.length == length {
+			t.freeHeap = append(t.freeHeap[:i], t.freeHeap[i+1:]...)
+		} else {
+			t.freeHeap[i] = heapRange{offset: free.offset + length, length: free.length - length}
+		}
+
+		return free.offset
+	}
+
+	offset := t.heapByteCount
+	t.heapByteCount += length
+
+	return offset
+}
+
+// unusedHeapRanges returns the ranges of the first size bytes of the heap file that none of the used ranges cover.
+// The used ranges are sorted, and may overlap.
+func unusedHeapRanges(used []heapRange, size int64) []heapRange {
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].offset < used[j].offset
+	})
+
+	var free []heapRange
+	end := int64(0)
+
+	for _, r := range used {
+		if r.offset > end {
+			free = append(free, heapRange{offset: end, length: r.offset - end})
+		}
+
+		if r.offset+r.length > end {
+			end = r.offset + r.length
+		}
+	}
+
+	if size > end {
+		free = append(free, heapRange{offset: end, length: size - end})
+	}
+
+	return free
+}
+
+// withFreedHeap returns the free ranges of the heap file once the freed ranges are added to them, merged where they
+// are next to each other, and the size that the heap file can be truncated to, which is less than heapByteCount if
+// its end is free. The table is not changed, so the ranges are only taken once the change is committed.
+func (t *table) withFreedHeap(freed []heapRange) ([]heapRange, int64) {
+	ranges := append(append([]heapRange(nil), t.freeHeap...), freed...)
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].offset < ranges[j].offset
+	})
+
+	var free []heapRange
+
+	for _, r := range ranges {
+		if n := len(free); n > 0 && free[n-1].offset+free[n-1].length == r.offset {
+			free[n-1].length += r.length
+			continue
+		}
+
+		free = append(free, r)
+	}
+
+	size := t.heapByteCount
+	if n := len(free); n > 0 && free[n-1].offset+free[n-1].length == size {
+		size = free[n-1].offset
+		free = free[:n-1]
+	}
+
+	return free, size
+}
@@ -0,0 +1,465 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CreateIndex creates an index of the table and fills it with every row version the table already has, including
+// the deleted versions that vacuum has not reclaimed yet. It is an error to create a unique index of a field that
+// already has the same value in more than one row that is not deleted.
+func (t *table) CreateIndex(ctx context.Context, index Index) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("indexes can not be created inside a transaction")
+	}
+
+	err := validateName("index", index.Name)
+	if err != nil {
+		return err
+	}
+
+	field, err := t.FieldWithName(index.FieldName)
+	if err != nil {
+		return err
+	}
+
+	fieldIndex := 0
+	for i, f := range t.Fields {
+		if f.Name == field.Name {
+			fieldIndex = i
+		}
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	for _, existing := range t.indexes {
+		if existing.Name == index.Name {
+			return fmt.Errorf(`index with name "%s" already exists`, index.Name)
+		}
+	}
+
+	var entries, live []indexEntry
+
+	err = t.scanSlots(func(slot int64, rowBytes []byte) error {
+		if isFree(rowBytes) {
+			return nil
+		}
+
+		values, err := t.decodeRow(rowBytes)
+		if err != nil {
+			return err
+		}
+
+		if key, ok := indexKey(values[fieldIndex].Val); ok {
+			entries = append(entries, indexEntry{key: key, row: slot})
+
+			// every other transaction that wrote to the table has ended, so a version that is not deleted is the latest
+			if rowXmax(rowBytes) == 0 {
+				live = append(live, indexEntry{key: key, row: slot})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].compare(entries[j]) < 0 })
+
+	if index.Unique {
+		err = checkUniqueEntries(index, field, live)
+		if err != nil {
+			return err
+		}
+	}
+
+	c, unlock, err := t.store.lockCatalog(t.database)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	batch := t.store.newWALBatch()
+
+	i, err := createIndexFile(t, batch, index, fieldIndex, entries)
+	if err != nil {
+		return err
+	}
+
+	info := t.info()
+	info.Indexes = append(info.Indexes, index)
+
+	update, err := c.update(batch, c.replace(t.Name, &info))
+	if err == nil {
+		err = batch.commit()
+	}
+
+	if err != nil {
+		i.file.Close()
+		os.Remove(i.file.Name())
+		return err
+	}
+
+	update()
+
+	t.indexes = append(t.indexes, i)
+
+	return nil
+}
+
+// checkUniqueEntries returns an error if any two of the entries of the rows that are not deleted, which are sorted in
+// place, have the same key, so the unique index of the field can not be built out of them.
+func checkUniqueEntries(index Index, field Field, live []indexEntry) error {
+	sort.Slice(live, func(i, j int) bool { return live[i].compare(live[j]) < 0 })
+
+	for i := 1; i < len(live); i++ {
+		if bytes.Equal(live[i-1].key, live[i].key) {
+			return fmt.Errorf(`could not create unique index "%s", field "%s" has the value %v more than once`, index.Name, field.Name, bToAny(live[i].key, field.Type))
+		}
+	}
+
+	return nil
+}
+
+// DropIndex deletes the index of the table with the name.
+func (t *table) DropIndex(ctx context.Context, name string) error {
+	if transactionFromContext(ctx) != nil {
+		return errors.New("indexes can not be dropped inside a transaction")
+	}
+
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return t.failed
+	}
+
+	for i, index := range t.indexes {
+		if index.Name != name {
+			continue
+		}
+
+		c, unlock, err := t.store.lockCatalog(t.database)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		batch := t.store.newWALBatch()
+		batch.remove(t.store.getIndexFilePath(t.database, t.Name, name))
+
+		info := t.info()
+		info.Indexes = append(info.Indexes[:i:i], info.Indexes[i+1:]...)
+
+		update, err := c.update(batch, c.replace(t.Name, &info))
+		if err != nil {
+			return err
+		}
+
+		err = batch.commitFiles()
+		if err != nil {
+			return err
+		}
+
+		update()
+
+		t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+
+		err = index.file.Close()
+		if err != nil {
+			return fmt.Errorf("could not close index file: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf(`index with name "%s" does not exist on table "%s"`, name, t.Name)
+}
+
+// GetIndexes returns every index of the table.
+func (t *table) GetIndexes() []Index {
+	t.mrw.RLock()
+	defer t.mrw.RUnlock()
+
+	returner := make([]Index, len(t.indexes))
+	for i, index := range t.indexes {
+		returner[i] = index.Index
+	}
+
+	return returner
+}
+
+// rowVersion is a version of a row that is written to a slot.
+type rowVersion struct {
+	slot   int64
+	values []interface{}
+}
+
+// key returns the key of the values in the index. It returns false if the value is NULL.
+func (i *tableIndex) key(values []interface{}) ([]byte, bool) {
+	return indexKey(values[i.fieldIndex])
+}
+
+// checkUnique returns an error if writing the versions would give two rows that are not deleted the same key in a
+// unique index. The versions in the deleted slots are about to be deleted, so their keys are free to be used again. It
+// must be called before any of the versions are written. The table must be locked for writing.
+//
+// Uniqueness is checked against the latest versions rather than the ones visible to the snapshot of the statement,
+// since every version that is not deleted belongs to a transaction that either committed or is the one writing.
+func (t *table) checkUnique(versions []rowVersion, deleted map[int64]bool) error {
+	for _, index := range t.indexes {
+		if !index.Unique {
+			continue
+		}
+
+		added := make(map[string]bool, len(versions))
+		for _, version := range versions {
+			key, ok := index.key(version.values)
+			if !ok {
+				continue
+			}
+
+			duplicate := added[string(key)]
+			for _, slot := range index.tree.rowsWithKey(key) {
+				if duplicate {
+					break
+				}
+
+				if deleted[slot] {
+					continue
+				}
+
+				live, err := t.isLive(slot)
+				if err != nil {
+					return err
+				}
+
+				duplicate = live
+			}
+
+			if duplicate {
+				return fmt.Errorf(`%w: value %v of field "%s" already exists in unique index "%s"`, ErrUniqueViolation, version.values[index.fieldIndex], index.FieldName, index.Name)
+			}
+
+			added[string(key)] = true
+		}
+	}
+
+	return nil
+}
+
+// isLive returns whether the slot holds a version that is not deleted. The table must be locked.
+func (t *table) isLive(slot int64) (bool, error) {
+	header := make([]byte, rowHeaderSize)
+
+	_, err := t.file.ReadAt(header, t.slotOffset(slot))
+	if err != nil {
+		return false, fmt.Errorf("could not read row %d: %w", slot, err)
+	}
+
+	return !isFree(header) && rowXmax(header) == 0, nil
+}
+
+// indexVersions adds entries for the versions to every index. The table must be locked for writing.
+func (t *table) indexVersions(batch *walBatch, versions []rowVersion) {
+	t.writeIndexEntries(batch, indexRecordInsert, versions)
+}
+
+// unindexVersions removes the entries of the versions from every index. The table must be locked for writing.
+func (t *table) unindexVersions(batch *walBatch, versions []rowVersion) {
+	t.writeIndexEntries(batch, indexRecordDelete, versions)
+}
+
+func (t *table) writeIndexEntries(batch *walBatch, op byte, versions []rowVersion) {
+	for _, index := range t.indexes {
+		var entries []indexEntry
+
+		for _, version := range versions {
+			if key, ok := index.key(version.values); ok {
+				entries = append(entries, indexEntry{key: key, row: version.slot})
+			}
+		}
+
+		index.write(batch, op, entries)
+	}
+}
+
+// indexScanFraction limits index lookups to those that find at most 1/indexScanFraction of the rows of a table. Rows
+// found through an index are read one at a time, so if it finds more than that, scanning the table is faster.
+const indexScanFraction = 4
+
+// indexedSlots uses the indexes of the table to find the slots of the rows that can satisfy the filters, sorted. Any
+// row that satisfies the filters is in one of the slots, but the rows still need to be checked against the filters.
+// It returns false if there is no index for the filters or the filters are not selective enough for one to be
+// worthwhile. The table must be locked for reading.
+//
+// Every comparison that has to be true for the filters to be true is a candidate, which is every filter and the
+// children of AND filters. Each index can either look up the keys of an equality comparison or read a range of keys
+// out of all of the range comparisons of its field, and the lookup that finds the fewest rows is used.
+func (t *table) indexedSlots(filters []Filter) ([]int64, bool) {
+	if len(t.indexes) == 0 {
+		return nil, false
+	}
+
+	var comparisons []Filter
+	for _, filter := range filters {
+		comparisons = appendConjuncts(comparisons, filter)
+	}
+
+	limit := int(t.rowCount / indexScanFraction)
+
+	var best []int64
+	found := false
+
+	consider := func(slots []int64, ok bool) {
+		if ok && (!found || len(slots) < len(best)) {
+			best, found = slots, true
+			limit = len(slots)
+		}
+	}
+
+	for _, index := range t.indexes {
+		var lower, upper *keyBound
+
+		for _, filter := range comparisons {
+			if filter.FieldName != index.FieldName || filter.Operator == OperatorIsNull || filter.Operator == OperatorIsNotNull {
+				continue
+			}
+
+			if filter.RangeComparison {
+				if filter.Operator == OperatorEqual {
+					consider(index.lookup(filter.Vals, limit))
+				}
+
+				continue
+			}
+
+			// comparing with NULL is never true
+			if filter.Val == nil {
+				consider(nil, true)
+				continue
+			}
+
+			key, _ := indexKey(filter.Val)
+
+			switch filter.Operator {
+			case OperatorEqual:
+				consider(index.lookup([]interface{}{filter.Val}, limit))
+			case OperatorLessThan, OperatorLessThanOrEqual:
+				bound := &keyBound{key: key, inclusive: filter.Operator == OperatorLessThanOrEqual}
+				if upper == nil || bound.tighterThan(upper, 1) {
+					upper = bound
+				}
+			case OperatorGreaterThan, OperatorGreaterThanOrEqual:
+				bound := &keyBound{key: key, inclusive: filter.Operator == OperatorGreaterThanOrEqual}
+				if lower == nil || bound.tighterThan(lower, -1) {
+					lower = bound
+				}
+			}
+		}
+
+		if lower != nil || upper != nil {
+			consider(index.rangeSlots(lower, upper, limit))
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+
+	sort.Slice(best, func(i, j int) bool { return best[i] < best[j] })
+
+	// a row is found more than once if its key is looked up more than once
+	unique := best[:0]
+	for i, slot := range best {
+		if i == 0 || slot != best[i-1] {
+			unique = append(unique, slot)
+		}
+	}
+
+	return unique, true
+}
+
+// appendConjuncts appends the filter to comparisons, or if it is an AND filter, each of its children.
+func appendConjuncts(comparisons []Filter, filter Filter) []Filter {
+	if filter.Logic == LogicalAnd {
+		for _, child := range filter.Filters {
+			comparisons = appendConjuncts(comparisons, child)
+		}
+
+		return comparisons
+	}
+
+	if filter.IsCompound() {
+		return comparisons
+	}
+
+	return append(comparisons, filter)
+}
+
+// tighterThan returns whether the bound leaves fewer keys in range than the other bound, for an upper bound if
+// direction is 1 or a lower bound if it is -1.
+func (k *keyBound) tighterThan(other *keyBound, direction int) bool {
+	c := bytes.Compare(k.key, other.key) * direction
+	if c != 0 {
+		return c < 0
+	}
+
+	return !k.inclusive && other.inclusive
+}
+
+// lookup returns the rows with any of the values. It returns false if there are more than limit of them.
+func (i *tableIndex) lookup(vals []interface{}, limit int) ([]int64, bool) {
+	var slots []int64
+
+	for _, val := range vals {
+		key, ok := indexKey(val)
+		if !ok {
+			continue
+		}
+
+		slots = append(slots, i.tree.rowsWithKey(key)...)
+		if len(slots) > limit {
+			return nil, false
+		}
+	}
+
+	return slots, true
+}
+
+// rangeSlots returns the rows with a key within the bounds. It returns false if there are more than limit of them.
+func (i *tableIndex) rangeSlots(lower *keyBound, upper *keyBound, limit int) ([]int64, bool) {
+	var slots []int64
+	ok := true
+
+	i.tree.scan(lower, upper, func(entry indexEntry) bool {
+		slots = append(slots, entry.row)
+		ok = len(slots) <= limit
+
+		return ok
+	})
+
+	return slots, ok
+}
@@ -1,27 +1,49 @@
 package backend
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"os"
 	"sync"
 )
 
+// table is a table that is open. Row versions are stored in slots, which are never moved so a version can be referred
+// to by its slot. slotCount is the number of slots in the table file, rowCount the number of them that hold a version
+// which is not deleted and deadCount the number that hold one which is, while the slots that are free are in
+// freeSlots and the ranges of the heap file that no version refers to are in freeHeap. vacuumHorizon is the horizon
+// the table was last vacuumed at.
+//
+// mrw guards the state of the table and its files, while lock is held across whole statements and transactions that
+// write to the table, see lockForWrite. Statements that read the table only lock mrw while they read a chunk of rows,
+// see slotReader, so they never wait for writers for longer than it takes to apply a single statement.
 type table struct {
 	mrw             *sync.RWMutex
-	file            *os.File
+	lock            *tableLock
+	file            dataFile
+	heapFile        dataFile
+	heapByteCount   int64
 	fileByteCount   int64
 	headerByteCount int64
 	rowByteCount    int64
 	rowCount        int64
+	deadCount       int64
+	slotCount       int64
+	freeSlots       []int64
+	freeHeap        []heapRange
+	vacuumHorizon   uint64
+	indexes         []*tableIndex
+	failed          error
+	closed          bool
+	store           *Store
+	database        string
 	Name            string
 	Fields          []Field
+	Version         int
 }
 
 // InsertRow adds a new row to the table with the given Values. It will attempt to parse the Values into the
-// correct primitive type, if it is unable to do so, an error will be returned. It returns the number of rows written
+// correct primitive type, if it is unable to do so, an error will be returned. Fields without a value are their
+// default. It returns the number of rows written
 func (t *table) InsertRow(ctx context.Context, values []Value) (int, error) {
 	fields := t.Fields
 
@@ -34,41 +56,114 @@ func (t *table) InsertRow(ctx context.Context, values []Value) (int, error) {
 		valsMap[val.FieldName] = val
 	}
 
-	// a buffer is not needed as the total size is fixed
-	b := make([]byte, 0, t.rowByteCount)
-
-	for _, field := range fields {
-		var bytes []byte
-
-		val, exists := valsMap[field.Name]
-		if exists {
-			bytes = val.Bytes()
-		} else {
-			bytes = make([]byte, field.Type.Size())
+	cells := make([]interface{}, len(fields))
+	for i, field := range fields {
+		val, ok := valsMap[field.Name]
+		if !ok {
+			cells[i] = field.Default
+			continue
 		}
 
-		b = append(b, bytes...)
+		cells[i] = val.Val
 	}
 
-	// reslice b to be full length since each row must be of the same size
-	b = b[:cap(b)]
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	snap, done, err := t.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
 
 	t.mrw.Lock()
 	defer t.mrw.Unlock()
 
-	file := t.file
-	n, err := file.WriteAt(b, t.fileByteCount)
+	if t.failed != nil {
+		return 0, t.failed
+	}
+
+	added := []rowVersion{{slot: t.nextSlots(1)[0], values: cells}}
+
+	err = t.checkUnique(added, nil)
 	if err != nil {
-		return 0, fmt.Errorf("could not write to file: %w", err)
+		return 0, err
+	}
+
+	batch := t.store.newWALBatch()
+
+	b, err := t.encodeRow(batch, snap.xid, cells, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	t.writeRow(batch, added[0].slot, b)
+	t.indexVersions(batch, added)
+
+	err = t.commit(ctx, batch)
+	if err != nil {
+		return 0, err
 	}
 
 	// increment cache Values
-	t.fileByteCount += int64(n)
+	t.useSlots(1)
 	t.rowCount++
 
 	return 1, nil
 }
 
+// nextSlots returns the slots that the next n row versions that are written go in, which are the free slots before
+// the new slots at the end of the file.
+func (t *table) nextSlots(n int) []int64 {
+	slots := make([]int64, n)
+	free := len(t.freeSlots)
+
+	for i := range slots {
+		if i < free {
+			slots[i] = t.freeSlots[free-1-i]
+		} else {
+			slots[i] = t.slotCount + int64(i-free)
+		}
+	}
+
+	return slots
+}
+
+// useSlots marks the slots returned by nextSlots(n) as used, once the row versions written to them are committed.
+func (t *table) useSlots(n int) {
+	free := len(t.freeSlots)
+	if n <= free {
+		t.freeSlots = t.freeSlots[:free-n]
+		return
+	}
+
+	t.freeSlots = t.freeSlots[:0]
+	t.slotCount += int64(n - free)
+	t.fileByteCount = t.filePages(t.slotCount)
+}
+
+// commit commits the batch of writes to the table, or if the context has a transaction, applies it as part of the
+// transaction. If it fails, the table is left in a state that no longer matches its files, so it can not be used
+// until it is opened again, which recovers the files from the write-ahead log.
+func (t *table) commit(ctx context.Context, batch *walBatch) error {
+	var err error
+	if tx := transactionFromContext(ctx); tx != nil {
+		err = tx.apply(batch)
+	} else {
+		err = batch.commit()
+	}
+
+	if err != nil {
+		t.failed = fmt.Errorf("table %s could not write a change and must be opened again: %w", t.Name, err)
+		return t.failed
+	}
+
+	return nil
+}
+
 // Operator are the supported comparison operators in a WHERE clause of SELECT statement
 type Operator string
 
@@ -79,11 +174,32 @@ const (
 	OperatorLessThanOrEqual    Operator = "<="
 	OperatorGreaterThan        Operator = ">"
 	OperatorGreaterThanOrEqual Operator = ">="
+	OperatorIsNull             Operator = "is null"
+	OperatorIsNotNull          Operator = "is not null"
 )
 
 func (o Operator) IsValid() bool {
 	switch o {
-	case OperatorEqual, OperatorNotEqual, OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual:
+	case OperatorEqual, OperatorNotEqual, OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual,
+		OperatorIsNull, OperatorIsNotNull:
+		return true
+	}
+
+	return false
+}
+
+// LogicalOperator combines the results of multiple Filters into a single result.
+type LogicalOperator string
+
+const (
+	LogicalAnd LogicalOperator = "and"
+	LogicalOr  LogicalOperator = "or"
+	LogicalNot LogicalOperator = "not"
+)
+
+func (l LogicalOperator) IsValid() bool {
+	switch l {
+	case LogicalAnd, LogicalOr, LogicalNot:
 		return true
 	}
 
@@ -93,331 +209,421 @@ func (o Operator) IsValid() bool {
 // Filter is used in WHERE clause and the filtering for joins. You can either specify a single Val
 // and use any operator to compare them. Or specify a slice of Vals and use the operators Equal or
 // NotEqual to compare. If RangeComparison is true, then Value will be ignored in favors of Vals.
+// OperatorIsNull and OperatorIsNotNull ignore both.
+//
+// Filters follow SQL's three-valued logic: comparing NULL, with any operator other than OperatorIsNull and
+// OperatorIsNotNull, is unknown, and only rows for which the whole filter is true match.
+//
+// A Filter with a Logic is a compound filter, all other fields are ignored and the result is instead the Logic
+// applied to the results of Filters. LogicalNot requires exactly one child filter, LogicalAnd and LogicalOr require
+// at least one.
 type Filter struct {
 	Value
 	Vals            []interface{}
 	RangeComparison bool
 	FieldName       string
 	Operator        Operator
+	Logic           LogicalOperator
+	Filters         []Filter
+}
+
+// IsCompound returns whether the Filter combines child Filters instead of comparing a field.
+func (f *Filter) IsCompound() bool {
+	return f.Logic != ""
 }
 
-// Row is a Value slice alongside with the file system index of the row.
+// Row is a Value slice alongside with the file system index of the row, which is the slot of its version, and the
+// xmax of the version.
 type Row struct {
 	Values []Value
 	index  int64
+	xmax   uint64
 }
 
-// rowsThatMatch returns an array of rows that match the specified filter. This should be used as the implementation
-// of the WHERE clause for any statements that support one. If the filter is nil, all rows will be selected.
-func (t *table) rowsThatMatch(ctx context.Context, filters []Filter) ([]Row, error) {
-	// validate filters
-	fieldFilters := map[string][]Filter{}
+// NoLimit can be passed as the limit of GetRows to return every row that matches.
+const NoLimit = -1
 
-	for _, filter := range filters {
-		field, err := t.FieldWithName(filter.FieldName)
-		if err != nil {
-			return nil, err
-		}
+// rowsThatMatch returns an array of rows that match the specified filter, out of the row versions visible to the
+// snapshot. This should be used as the implementation of the WHERE clause for any statements that support one. If
+// the filter is nil, all rows will be selected. The scan stops as soon as limit rows have matched, a negative limit
+// will scan the entire table. The table must not be locked with mrw.
+func (t *table) rowsThatMatch(ctx context.Context, snap *snapshot, filters []Filter, limit int) ([]Row, error) {
+	cursor, err := t.newRowCursor(ctx, snap, filters, limit)
+	if err != nil {
+		return nil, err
+	}
 
-		if field.Type != filter.Type {
-			return nil, fmt.Errorf("%s.%s is of type %s", t.Name, field.Name, field.Type)
-		}
+	return collectRows(cursor)
+}
 
-		// only supported operator for range comparisons are equal and not equal
-		if filter.RangeComparison {
-			if !(filter.Operator == OperatorEqual || filter.Operator == OperatorNotEqual) {
-				return nil, fmt.Errorf("only OperatorEqual and OperatorNotEqual are supported in range comparison")
-			}
-		}
+// collectRows reads every remaining row of the iterator and closes it.
+func collectRows(it RowIterator) ([]Row, error) {
+	var rows []Row
 
-		fieldFilters[filter.FieldName] = append(fieldFilters[filter.FieldName], filter)
+	for it.Next() {
+		rows = append(rows, it.Row())
 	}
 
-	// begin file operations
-	t.mrw.RLock()
-	defer t.mrw.RUnlock()
+	err := it.Err()
+	if err != nil {
+		it.Close()
+		return nil, err
+	}
 
-	_, err := t.file.Seek(t.headerByteCount, 0)
+	err = it.Close()
 	if err != nil {
-		return nil, fmt.Errorf("could not skip table header: %w", err)
+		return nil, err
 	}
 
-	reader := bufio.NewReader(t.file)
+	return rows, nil
+}
 
-	returner := make([]Row, 0, t.rowCount)
+// errTableClosed is the error of statements that use a table after it was closed.
+var errTableClosed = errors.New("table is closed")
 
-	var i int64 = 0
-	for ; i < t.rowCount; i++ {
-		row := make([]Value, 0, len(t.Fields))
-		// default value True
-		var shouldAddRow bool = true
+// validateFilter checks that a filter, and all of its children if it is compound, can be evaluated against the
+// table.
+func (t *table) validateFilter(filter Filter) error {
+	if filter.IsCompound() {
+		if !filter.Logic.IsValid() {
+			return fmt.Errorf("%s is not a valid logical operator", filter.Logic)
+		}
 
-		var cursor int64 = 0
-		rowBytes := make([]byte, t.rowByteCount)
-		_, err := io.ReadFull(reader, rowBytes)
-		if err != nil {
-			return nil, fmt.Errorf("could not read row %d: %w", i, err)
+		if filter.Logic == LogicalNot && len(filter.Filters) != 1 {
+			return fmt.Errorf("NOT requires exactly one operand")
 		}
 
-		for _, field := range t.Fields {
-			if shouldAddRow {
-				// read cell
-				cellBytes := rowBytes[cursor : cursor+field.Type.Size()]
-
-				// test filters if needed
-				for _, filter := range fieldFilters[field.Name] {
-					// default value False
-					var satisfiesFilter bool = false
-
-					if filter.RangeComparison { // perform range comparison
-						// if OperatorEqual, only one needs to equal
-						// if OperatorNotEqual, all needs to be not equal
-
-						for _, val := range filter.Vals {
-							if compareValues(cellBytes, filter.Operator, anyToB(val), field.Type) {
-								// by finding just one equal value, either operator can be determined
-								satisfiesFilter = filter.Operator == OperatorEqual
-								break
-							}
-						}
-					} else {
-						satisfiesFilter = compareValues(cellBytes, filter.Operator, anyToB(filter.Val), field.Type)
-					}
-
-					if !satisfiesFilter {
-						shouldAddRow = false
-						break
-					}
-				}
+		if len(filter.Filters) == 0 {
+			return fmt.Errorf("%s requires at least one operand", filter.Logic)
+		}
 
-				row = append(row, Value{
-					Type:      field.Type,
-					Val:       bToAny(cellBytes, field.Type),
-					FieldName: field.Name,
-				})
+		for _, child := range filter.Filters {
+			err := t.validateFilter(child)
+			if err != nil {
+				return err
 			}
-
-			cursor += field.Type.Size()
 		}
 
-		if shouldAddRow {
-			returner = append(returner, Row{
-				Values: row,
-				index:  i,
-			})
+		return nil
+	}
+
+	field, err := t.FieldWithName(filter.FieldName)
+	if err != nil {
+		return err
+	}
+
+	if field.Type != filter.Type {
+		return fmt.Errorf("%s.%s is of type %s", t.Name, field.Name, field.Type)
+	}
+
+	// only supported operator for range comparisons are equal and not equal
+	if filter.RangeComparison {
+		if !(filter.Operator == OperatorEqual || filter.Operator == OperatorNotEqual) {
+			return fmt.Errorf("only OperatorEqual and OperatorNotEqual are supported in range comparison")
 		}
+	} else if !filter.Operator.IsValid() {
+		return fmt.Errorf("%s is not a valid operator", filter.Operator)
 	}
 
-	return returner, nil
+	return nil
 }
 
-// GetRows returns the selected fields from a table that matches the filter. If fields is a zero length slice, all
-// fields will be returned. If the filter is nil, all rows will be returned.
-func (t *table) GetRows(ctx context.Context, fields []string, filters []Filter) ([]Row, error) {
-	shouldSelectField := make([]bool, len(t.Fields))
-	fieldsToSelectCount := 0
+// cellOffset is the position of a field within an encoded row. index is the position of the field, which is also
+// its bit in the null bitmap, and offset is the position of its cell in bytes.
+type cellOffset struct {
+	index  int
+	offset int64
+}
+
+// fieldOffsets returns the position of each field within a row, keyed by field name.
+func (t *table) fieldOffsets() map[string]cellOffset {
+	offsets := make(map[string]cellOffset, len(t.Fields))
 
-	// if there is a filter for fields, validate field inputs
-	if len(fields) != 0 {
-		tFieldNames := make([]string, len(t.Fields))
-		for i, field := range t.Fields {
-			tFieldNames[i] = field.Name
+	cursor := rowHeaderSize + nullBitmapSize(len(t.Fields))
+	for i, field := range t.Fields {
+		offsets[field.Name] = cellOffset{index: i, offset: cursor}
+		cursor += field.Type.Size()
+	}
+
+	return offsets
+}
+
+// rowSatisfiesAll returns whether the encoded row satisfies every filter. The filters must have already been
+// validated.
+func (t *table) rowSatisfiesAll(rowBytes []byte, offsets map[string]cellOffset, filters []Filter) (bool, error) {
+	for _, filter := range filters {
+		result, err := t.evaluateRow(rowBytes, offsets, filter)
+		if err != nil || result != ternaryTrue {
+			return false, err
 		}
+	}
+
+	return true, nil
+}
 
-		for i, field := range tFieldNames {
-			if contains(fields, field) {
-				shouldSelectField[i] = true
-				fieldsToSelectCount++
+// evaluateRow evaluates a single, possibly compound, filter against an encoded row. Compound filters short circuit
+// as soon as their result is known.
+func (t *table) evaluateRow(rowBytes []byte, offsets map[string]cellOffset, filter Filter) (ternary, error) {
+	switch filter.Logic {
+	case LogicalAnd, LogicalOr:
+		result := ternaryOf(filter.Logic == LogicalAnd)
+
+		for _, child := range filter.Filters {
+			childResult, err := t.evaluateRow(rowBytes, offsets, child)
+			if err != nil {
+				return ternaryFalse, err
+			}
+
+			if filter.Logic == LogicalAnd {
+				result = result.and(childResult)
 			} else {
-				shouldSelectField[i] = false
+				result = result.or(childResult)
+			}
+
+			// the result can not change once AND is false or OR is true
+			if result == ternaryOf(filter.Logic == LogicalOr) {
+				break
 			}
 		}
 
-		if fieldsToSelectCount != len(fields) {
-			e := exclusive(fields, tFieldNames)[0]
+		return result, nil
+	case LogicalNot:
+		result, err := t.evaluateRow(rowBytes, offsets, filter.Filters[0])
 
-			return nil, fieldNotExistErr(e, t.GetName())
-		}
-	} else {
-		for i := 0; i < len(t.Fields); i++ {
-			shouldSelectField[i] = true
-		}
+		return result.not(), err
+	}
 
-		fieldsToSelectCount = len(t.Fields)
+	cell := offsets[filter.FieldName]
+	if isNull(rowBytes, cell.index) {
+		return compareNull(filter), nil
 	}
 
-	rows, err := t.rowsThatMatch(ctx, filters)
+	b, err := t.cellBytes(rowBytes[cell.offset:cell.offset+filter.Type.Size()], filter.Type)
 	if err != nil {
-		return nil, err
+		return ternaryFalse, err
+	}
+
+	return cellSatisfies(b, filter), nil
+}
+
+// cellSatisfies evaluates a comparison filter against the encoded value of the field it filters, which is not NULL.
+func cellSatisfies(cellBytes []byte, filter Filter) ternary {
+	switch filter.Operator {
+	case OperatorIsNull:
+		return ternaryFalse
+	case OperatorIsNotNull:
+		return ternaryTrue
 	}
 
-	returner := make([]Row, len(rows))
-	for i, row := range rows {
-		filteredValues := make([]Value, 0, fieldsToSelectCount)
+	if filter.RangeComparison { // perform range comparison
+		// if OperatorEqual, only one needs to equal
+		// if OperatorNotEqual, all needs to be not equal
+		result := ternaryOf(filter.Operator == OperatorNotEqual)
 
-		for j, val := range row.Values {
-			if shouldSelectField[j] {
-				filteredValues = append(filteredValues, val)
+		for _, val := range filter.Vals {
+			if val == nil { // the cell might equal the NULL value, so a miss is unknown
+				result = ternaryUnknown
+				continue
+			}
+
+			if compareValues(cellBytes, OperatorEqual, anyToB(val), filter.Type) {
+				// by finding just one equal value, either operator can be determined
+				return ternaryOf(filter.Operator == OperatorEqual)
 			}
 		}
 
-		returner[i] = Row{Values: filteredValues, index: row.index}
+		return result
 	}
 
-	return returner, nil
-}
-
-// DeleteRows deletes all rows that match the filter. If the filter is nil, all rows will be deleted. It returns the
-// number of rows deleted.
-func (t *table) DeleteRows(ctx context.Context, filters []Filter) (int, error) {
-	if len(filters) == 0 { // delete all rows
-		t.mrw.Lock()
-		defer t.mrw.Unlock()
+	if filter.Val == nil {
+		return ternaryUnknown
+	}
 
-		file := t.file
+	return ternaryOf(compareValues(cellBytes, filter.Operator, anyToB(filter.Val), filter.Type))
+}
 
-		_, err := file.Seek(0, 0)
-		if err != nil {
-			return 0, fmt.Errorf("could not seek before truncate: %w", err)
+// ValuesSatisfy returns whether the values satisfy every filter, with each comparison applied to the value with the
+// same field name. It is used to filter rows after they have been read from a table, such as the results of an
+// aggregation. It is an error for a filter to reference a field without a value or of a different type.
+func ValuesSatisfy(values []Value, filters []Filter) (bool, error) {
+	for _, filter := range filters {
+		result, err := evaluateValues(values, filter)
+		if err != nil || result != ternaryTrue {
+			return false, err
 		}
+	}
 
-		err = file.Truncate(t.headerByteCount)
-		if err != nil {
-			return 0, fmt.Errorf("could not truncate: %w", err)
-		}
+	return true, nil
+}
 
-		affected := t.rowCount
+func evaluateValues(values []Value, filter Filter) (ternary, error) {
+	switch filter.Logic {
+	case LogicalAnd, LogicalOr:
+		result := ternaryOf(filter.Logic == LogicalAnd)
 
-		t.rowCount = 0
-		t.fileByteCount = t.headerByteCount
+		for _, child := range filter.Filters {
+			childResult, err := evaluateValues(values, child)
+			if err != nil {
+				return ternaryFalse, err
+			}
 
-		return int(affected), nil
-	}
+			if filter.Logic == LogicalAnd {
+				result = result.and(childResult)
+			} else {
+				result = result.or(childResult)
+			}
+		}
 
-	rows, err := t.rowsThatMatch(ctx, filters)
-	if err != nil {
-		return 0, err
-	}
+		return result, nil
+	case LogicalNot:
+		if len(filter.Filters) != 1 {
+			return ternaryFalse, fmt.Errorf("NOT requires exactly one operand")
+		}
 
-	if len(rows) == 0 {
-		return 0, nil
-	}
+		result, err := evaluateValues(values, filter.Filters[0])
 
-	type chunk struct {
-		start int64
-		end   int64
-		shift int64
+		return result.not(), err
 	}
 
-	// each chunk represents a collection of rows that all need to be shifted by the same amount
-	chunks := make([]chunk, 0, len(rows))
+	for _, val := range values {
+		if val.FieldName != filter.FieldName {
+			continue
+		}
 
-	var start *int64
-	for i := 0; i < len(rows); i++ {
-		row := rows[i]
+		if val.Type != filter.Type {
+			return ternaryFalse, fmt.Errorf("%s is of type %s", val.FieldName, val.Type)
+		}
 
-		if start == nil {
-			temp := row.index + 1
-			start = &temp
+		if val.IsNull() {
+			return compareNull(filter), nil
 		}
 
-		var end int64
+		return cellSatisfies(val.Bytes(), filter), nil
+	}
 
-		if i == len(rows)-1 { // if last row
-			end = t.rowCount - 1
-		} else {
-			// check for consecutive rows
-			next := rows[i+1]
-			if next.index == row.index+1 {
-				start = nil
-				continue
-			} else {
-				end = next.index - 1
-			}
-		}
+	return ternaryFalse, fmt.Errorf("there is no value for field \"%s\"", filter.FieldName)
+}
 
-		chunks = append(chunks, chunk{
-			start: *start,
-			end:   end,
-			shift: int64(i + 1),
-		})
+// GetRows returns the selected fields from a table that matches the filter. If fields is a zero length slice, all
+// fields will be returned. If the filter is nil, all rows will be returned. At most limit rows are returned, in the
+// order they are stored, unless limit is NoLimit. Use ScanRows to read the rows without holding all of them in memory.
+func (t *table) GetRows(ctx context.Context, fields []string, filters []Filter, limit int) ([]Row, error) {
+	it, err := t.ScanRows(ctx, fields, filters, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	// each chunk is shifted together, so need to ensure not too much data
-	// is loaded into mem at once
-	maxChunkSize := int64(1024 * 1024 * 5) // 5MB
+	return collectRows(it)
+}
 
-	splitChunks := make([]chunk, 0, len(chunks))
-	for _, current := range chunks {
-		rowCount := current.end - current.start + 1
-		chunkSize := rowCount * t.rowByteCount
+// selectedFields returns whether each field of the table is one of fields, or nil if fields is a zero length slice.
+func (t *table) selectedFields(fields []string) ([]bool, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
 
-		if chunkSize > maxChunkSize && rowCount > 2 { // if split needed
-			split := (current.end + current.start) / 2
+	shouldSelectField := make([]bool, len(t.Fields))
+	fieldsToSelectCount := 0
 
-			splitChunks = append(splitChunks, chunk{
-				start: current.start,
-				end:   split,
-				shift: current.shift,
-			})
+	tFieldNames := make([]string, len(t.Fields))
+	for i, field := range t.Fields {
+		tFieldNames[i] = field.Name
+	}
 
-			splitChunks = append(splitChunks, chunk{
-				start: split + 1,
-				end:   current.end,
-				shift: current.shift,
-			})
-		} else {
-			splitChunks = append(splitChunks, current)
+	for i, field := range tFieldNames {
+		if contains(fields, field) {
+			shouldSelectField[i] = true
+			fieldsToSelectCount++
 		}
 	}
 
-	t.mrw.Lock()
-	defer t.mrw.Unlock()
+	if fieldsToSelectCount != len(fields) {
+		e := exclusive(fields, tFieldNames)[0]
 
-	file := t.file
+		return nil, fieldNotExistErr(e, t.GetName())
+	}
 
-	for _, chunk := range splitChunks {
-		rowCount := chunk.end - chunk.start + 1
-		chunkSize := rowCount * t.rowByteCount
+	return shouldSelectField, nil
+}
 
-		// number of bytes before the first byte of the chunk
-		offset := (chunk.start * t.rowByteCount) + t.headerByteCount
+// DeleteRows deletes all rows that match the filter. If the filter is nil, all rows will be deleted. It returns the
+// number of rows deleted. The versions of the rows are only marked as deleted, so statements that began before can
+// still read them, and their slots are reclaimed by vacuum.
+func (t *table) DeleteRows(ctx context.Context, filters []Filter) (int, error) {
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
 
-		chunkBytes := make([]byte, chunkSize)
-		_, err := file.ReadAt(chunkBytes, offset)
-		if err != nil {
-			return 0, err
-		}
+	snap, done, err := t.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
 
-		_, err = file.WriteAt(chunkBytes, offset-(chunk.shift*t.rowByteCount))
-		if err != nil {
-			return 0, err
-		}
+	rows, err := t.rowsThatMatch(ctx, snap, filters, NoLimit)
+	if err != nil {
+		return 0, err
 	}
 
-	_, err = file.Seek(0, 0)
+	err = t.checkConflicts(rows)
 	if err != nil {
-		return 0, fmt.Errorf("could not seek before truncate: %w", err)
+		return 0, err
 	}
 
-	bytesToTruncate := int64(len(rows)) * t.rowByteCount
+	if len(rows) == 0 {
+		return 0, nil
+	}
 
-	err = file.Truncate(t.fileByteCount - bytesToTruncate)
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return 0, t.failed
+	}
+
+	batch := t.store.newWALBatch()
+
+	xmax := appendUint64(nil, snap.xid)
+	for _, row := range rows {
+		batch.writeAt(t.file, xmax, t.slotOffset(row.index)+rowXmaxOffset)
+	}
+
+	err = t.commit(ctx, batch)
 	if err != nil {
-		return 0, fmt.Errorf("could not truncate: %w", err)
+		return 0, err
 	}
 
 	t.rowCount -= int64(len(rows))
-	t.fileByteCount -= bytesToTruncate
+	t.deadCount += int64(len(rows))
 
 	return len(rows), nil
 }
 
 // UpdateRows updates all rows that match the filter to have the provided values. If the filter is nil, all rows will
 // be updated. It returns the number of rows that had a value changed. Meaning, if a row matches the filter but did
-// not require an update, it will not count towards the return value.
+// not require an update, it will not count towards the return value. The version of each row that is changed is
+// deleted and a new version is written to another slot.
 func (t *table) UpdateRows(ctx context.Context, values []Value, filters []Filter) (int, error) {
-	oldRows, err := t.rowsThatMatch(ctx, filters)
+	unlock, err := t.lockForWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	snap, done, err := t.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	oldRows, err := t.rowsThatMatch(ctx, snap, filters, NoLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.checkConflicts(oldRows)
 	if err != nil {
 		return 0, err
 	}
@@ -427,49 +633,88 @@ func (t *table) UpdateRows(ctx context.Context, values []Value, filters []Filter
 		valsMap[val.FieldName] = val
 	}
 
-	type rowBytes struct {
-		index int64
-		bytes []byte
+	t.mrw.Lock()
+	defer t.mrw.Unlock()
+
+	if t.failed != nil {
+		return 0, t.failed
 	}
 
-	newRows := make([]rowBytes, 0, len(oldRows))
+	var changed []Row
+	var added []rowVersion
+
 	for _, oldRow := range oldRows {
 		requiresUpdate := false
-		newRow := make([]byte, 0, t.rowByteCount)
+		cells := valuesOf(oldRow.Values)
 
 		for j, field := range t.Fields {
-			oldVal := oldRow.Values[j]
-
 			if newVal, exists := valsMap[field.Name]; exists {
-				if newVal.Val != oldVal.Val {
+				if newVal.Val != cells[j] {
 					requiresUpdate = true
 				}
 
-				newRow = append(newRow, newVal.Bytes()...)
-			} else {
-				newRow = append(newRow, oldVal.Bytes()...)
+				cells[j] = newVal.Val
 			}
 		}
 
 		if requiresUpdate {
-			newRows = append(newRows, rowBytes{index: oldRow.index, bytes: newRow})
+			changed = append(changed, oldRow)
+			added = append(added, rowVersion{values: cells})
 		}
 	}
 
-	t.mrw.Lock()
-	defer t.mrw.Unlock()
+	deleted := make(map[int64]bool, len(changed))
+	for i, slot := range t.nextSlots(len(changed)) {
+		added[i].slot = slot
+		deleted[changed[i].index] = true
+	}
 
-	file := t.file
-	for _, row := range newRows {
-		offset := (row.index * t.rowByteCount) + t.headerByteCount
+	err = t.checkUnique(added, deleted)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := t.store.newWALBatch()
+
+	reader := newPageReader(t)
+
+	xmax := appendUint64(nil, snap.xid)
+	for i, version := range added {
+		prev, err := reader.row(changed[i].index)
+		if err != nil {
+			return 0, err
+		}
 
-		_, err := file.WriteAt(row.bytes, offset)
+		b, err := t.encodeRow(batch, snap.xid, version.values, prev)
 		if err != nil {
-			return 0, fmt.Errorf("could not update row %d: %w", row.index, err)
+			return 0, err
 		}
+
+		batch.writeAt(t.file, xmax, t.slotOffset(changed[i].index)+rowXmaxOffset)
+		t.writeRow(batch, version.slot, b)
+	}
+
+	t.indexVersions(batch, added)
+
+	err = t.commit(ctx, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	t.useSlots(len(added))
+	t.deadCount += int64(len(added))
+
+	return len(added), nil
+}
+
+// valuesOf returns the Go values of the values, in the same order.
+func valuesOf(values []Value) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, val := range values {
+		cells[i] = val.Val
 	}
 
-	return len(newRows), nil
+	return cells
 }
 
 func fieldNotExistErr(fieldName string, tableName string) error {
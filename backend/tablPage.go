@@ -0,0 +1,198 @@
+package backend
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The rows of a table file are stored in pages of pageSize bytes after the header, which is padded to a whole number
+// of pages so that the pages of the rows are also the pages of the buffer pool. A page starts with a header of
+// pageHeaderSize bytes, which is the kind of the page, a byte of flags that are not used yet, and then as uint16s the
+// number of slots in the page, the end of the slot directory and the start of the rows. The slot directory follows
+// the header, with the offset in the page and the length of the row of each slot as uint16s, while the rows are
+// stored from the end of the page towards its start, leaving the free space of the page between the two.
+//
+// Every row of a table is the same size, so every page holds the same number of slots and a slot never moves, which
+// lets indexes refer to rows by their slot. New slots are added to the last page until it is full, and then to a new
+// page after it.
+const (
+	pageHeaderSize = 8
+	slotEntrySize  = 4
+)
+
+// pageKindRows is the kind of the pages that hold the rows of a table. A page of any other kind is corrupt.
+const pageKindRows byte = 1
+
+// pageSlotCount returns the number of slots of each page of a table whose rows are rowByteCount bytes, which is 0 if
+// a row does not fit in a page.
+func pageSlotCount(rowByteCount int64) int64 {
+	return (pageSize - pageHeaderSize) / (slotEntrySize + rowByteCount)
+}
+
+// checkRowSize returns an error if a row of the fields does not fit in a page.
+func checkRowSize(fields []Field) error {
+	rowByteCount := calculateRowSize(fields)
+	if pageSlotCount(rowByteCount) == 0 {
+		return fmt.Errorf("a row of %d fields would be %d bytes, which does not fit in a page of %d bytes", len(fields), rowByteCount, pageSize)
+	}
+
+	return nil
+}
+
+// pageAlign returns the size rounded up to a whole number of pages.
+func pageAlign(size int64) int64 {
+	return (size + pageSize - 1) / pageSize * pageSize
+}
+
+// pageOf returns the page of the table that the slot is in, and the position of the slot in the page.
+func (t *table) pageOf(slot int64) (int64, int64) {
+	n := pageSlotCount(t.rowByteCount)
+
+	return slot / n, slot % n
+}
+
+// pageOffset returns the position in the table file of the page.
+func (t *table) pageOffset(page int64) int64 {
+	return t.headerByteCount + page*pageSize
+}
+
+// rowStart returns the offset in a page of the row of the slot at the position in the page.
+func (t *table) rowStart(position int64) int64 {
+	return pageSize - (position+1)*t.rowByteCount
+}
+
+// slotOffset returns the position in the table file of the row in the slot.
+func (t *table) slotOffset(slot int64) int64 {
+	page, position := t.pageOf(slot)
+
+	return t.pageOffset(page) + t.rowStart(position)
+}
+
+// filePages returns the position in the table file of the end of the pages that hold slotCount slots.
+func (t *table) filePages(slotCount int64) int64 {
+	n := pageSlotCount(t.rowByteCount)
+
+	return t.pageOffset((slotCount + n - 1) / n)
+}
+
+// writeRow adds the write of the encoded row to the slot to the batch. A slot that is not in the table yet is added
+// to the page with it in the slot directory, so new slots must be written in order.
+func (t *table) writeRow(batch *walBatch, slot int64, rowBytes []byte) {
+	if slot < t.slotCount {
+		batch.writeAt(t.file, rowBytes, t.slotOffset(slot))
+		return
+	}
+
+	page, position := t.pageOf(slot)
+
+	header := make([]byte, pageHeaderSize)
+	header[0] = pageKindRows
+	binary.BigEndian.PutUint16(header[2:], uint16(position+1))
+	binary.BigEndian.PutUint16(header[4:], uint16(pageHeaderSize+(position+1)*slotEntrySize))
+	binary.BigEndian.PutUint16(header[6:], uint16(t.rowStart(position)))
+
+	entry := make([]byte, slotEntrySize)
+	binary.BigEndian.PutUint16(entry, uint16(t.rowStart(position)))
+	binary.BigEndian.PutUint16(entry[2:], uint16(t.rowByteCount))
+
+	entryOffset := pageHeaderSize + position*slotEntrySize
+
+	// the first slot of a page writes the whole page, so that the page is never only partially in the file
+	if position == 0 {
+		b := make([]byte, pageSize)
+		copy(b, header)
+		copy(b[entryOffset:], entry)
+		copy(b[t.rowStart(position):], rowBytes)
+
+		batch.writeAt(t.file, b, t.pageOffset(page))
+		return
+	}
+
+	batch.writeAt(t.file, header, t.pageOffset(page))
+	batch.writeAt(t.file, entry, t.pageOffset(page)+entryOffset)
+	batch.writeAt(t.file, rowBytes, t.slotOffset(slot))
+}
+
+// readPage reads the page of the table into b, which must be pageSize bytes, and returns the number of slots in it.
+func (t *table) readPage(page int64, b []byte) (int64, error) {
+	_, err := t.file.ReadAt(b, t.pageOffset(page))
+	if err != nil {
+		return 0, fmt.Errorf("could not read page %d: %w", page, err)
+	}
+
+	if b[0] != pageKindRows {
+		return 0, fmt.Errorf("page %d of table %s is corrupt", page, t.Name)
+	}
+
+	return int64(binary.BigEndian.Uint16(b[2:])), nil
+}
+
+// countSlots returns the number of slots in the table file, which is every slot of the pages before the last one and
+// the slots of the last one.
+func (t *table) countSlots() (int64, error) {
+	size := t.fileByteCount - t.headerByteCount
+	if size%pageSize != 0 {
+		return 0, fmt.Errorf("table file of %s ends with a partial page", t.Name)
+	}
+
+	pages := size / pageSize
+	if pages == 0 {
+		return 0, nil
+	}
+
+	count, err := t.readPage(pages-1, make([]byte, pageSize))
+	if err != nil {
+		return 0, err
+	}
+
+	return (pages-1)*pageSlotCount(t.rowByteCount) + count, nil
+}
+
+// pageReader reads the rows of slots out of the pages of a table, keeping the page it read last so that the rows
+// of the same page are read out of it.
+type pageReader struct {
+	t      *table
+	data   []byte
+	page   int64
+	filled int64
+}
+
+func newPageReader(t *table) *pageReader {
+	return &pageReader{t: t, data: make([]byte, pageSize), page: -1}
+}
+
+// row returns the encoded row of the slot, which is found through the slot directory of its page. The row is only
+// valid until the reader reads another page.
+func (r *pageReader) row(slot int64) ([]byte, error) {
+	t := r.t
+	page, position := t.pageOf(slot)
+
+	if page != r.page {
+		filled, err := t.readPage(page, r.data)
+		if err != nil {
+			r.page = -1
+			return nil, err
+		}
+
+		r.page, r.filled = page, filled
+	}
+
+	if position >= r.filled {
+		return nil, fmt.Errorf("row %d is not in page %d of table %s", slot, page, t.Name)
+	}
+
+	entry := r.data[pageHeaderSize+position*slotEntrySize:]
+	start := int64(binary.BigEndian.Uint16(entry))
+	length := int64(binary.BigEndian.Uint16(entry[2:]))
+
+	if length != t.rowByteCount || start < pageHeaderSize || start+length > pageSize {
+		return nil, fmt.Errorf("page %d of table %s is corrupt", page, t.Name)
+	}
+
+	return r.data[start : start+length], nil
+}
+
+// reset makes the reader read every page again, since the table can have changed since it last read one.
+func (r *pageReader) reset() {
+	r.page = -1
+}
@@ -3,10 +3,8 @@ package backend
 import (
 	"encoding/binary"
 	"errors"
-	"log"
+	"fmt"
 	"math"
-	"os"
-	"strings"
 )
 
 // exclusive returns the elements that are in s1 but not in s2
@@ -34,41 +32,53 @@ func contains[T comparable](slice []T, element T) bool {
 
 var errFileAlreadyExists error = errors.New("file already exists")
 
-// createFile creates a file at the given path. It will throw an error if the file already exists
-func createFile(path string) (*os.File, error) {
-	// check if database directory exists
-	dirPath := "database"
-	if _, err := os.Stat(dirPath); errors.Is(err, os.ErrNotExist) {
-		err := os.Mkdir(dirPath, os.ModePerm)
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-	}
+// ErrTableNotExist is returned when a table that does not exist is opened.
+var ErrTableNotExist = errors.New("table does not exist")
 
-	// check if file exists
-	exists := true
+// ErrDatabaseNotExist is returned when a database that does not exist is used.
+var ErrDatabaseNotExist = errors.New("database does not exist")
 
-	_, err := os.Stat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			exists = false
-		} else {
-			return nil, err
-		}
+// ErrTableAltered is returned by a table whose fields were changed, which has to be opened again. A statement that
+// gets it has not changed anything yet.
+var ErrTableAltered = errors.New("table was altered")
+
+// ErrSerializationFailure is returned when a transaction with snapshot isolation changes a row that was changed by a
+// transaction it does not see. The transaction can be retried.
+var ErrSerializationFailure = errors.New("could not serialize access")
+
+// ErrUnsupportedTableFormat is returned when a table is opened whose file was written in a format version that is not
+// supported anymore. Tables are not migrated between versions, so it has to be dropped and created again, see
+// tableFormatVersion.
+var ErrUnsupportedTableFormat = errors.New("unsupported table format")
+
+// ErrUniqueViolation is returned when a row would have the same value as another row in a field with a unique index.
+var ErrUniqueViolation = errors.New("duplicate value")
+
+// maxNameLength is the maximum number of characters of the name of a database, table or index.
+const maxNameLength = 63
+
+// validateName returns an error if the name can not be given to a database, table or index, which is the kind. The
+// names are part of the paths of their files, so they can only have letters, digits and underscores and can not start
+// with a digit, which keeps them from being paths of their own such as ../x.
+func validateName(kind string, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s name can not be empty", kind)
 	}
 
-	// create file if not exists
-	if exists {
-		return nil, errFileAlreadyExists
-	} else {
-		file, err := os.Create(path)
-		if err != nil {
-			return nil, err
-		}
+	if len(name) > maxNameLength {
+		return fmt.Errorf(`%s name "%s" is longer than %d characters`, kind, name, maxNameLength)
+	}
+
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+		digit := r >= '0' && r <= '9'
 
-		return file, nil
+		if !letter && (!digit || i == 0) {
+			return fmt.Errorf(`invalid %s name "%s", names can only have letters, digits and underscores, and can not start with a digit`, kind, name)
+		}
 	}
+
+	return nil
 }
 
 func anyToB(val interface{}) []byte {
@@ -101,54 +111,79 @@ func bToAny(val []byte, as Primitive) interface{} {
 	return nil
 }
 
-// sToB converts a string to a byte slice of size 1024
+// sToB converts a string to a byte slice of its UTF-8 encoding. Unlike the other encodings it is not of a fixed
+// size, see encodeStringCell for how strings are stored in a row.
 func sToB(val string) []byte {
-	returner := make([]byte, 0, 1024)
-
-	returner = append(returner, []byte(val)...)
-
-	return returner[:1024]
+	return []byte(val)
 }
 
-// bToS converts a byte slice of size 1024 to a string
+// bToS converts a byte slice of a UTF-8 encoding to a string
 func bToS(val []byte) string {
-	var builder strings.Builder
-
-	for _, b := range val {
-		if b == 0 {
-			break
-		}
-
-		builder.WriteByte(b)
-	}
-
-	return builder.String()
+	return string(val)
 }
 
-// i64ToB converts an int64 to a byte slice of size 8
+// The fixed size encodings below are order preserving: comparing two encoded values of the same type byte by byte
+// gives the same order as comparing the values themselves. Multibyte values are big endian for this reason.
+
+// i64ToB converts an int64 to a byte slice of size 8. The sign bit is flipped so negative numbers order before
+// positive ones.
 func i64ToB(val int64) []byte {
 	b := make([]byte, 8)
-	binary.LittleEndian.PutUint64(b, uint64(val))
+	binary.BigEndian.PutUint64(b, uint64(val)^signBit)
 
 	return b
 }
 
 // bToI64 converts a byte slice of size 8 to an int64
 func bToI64(val []byte) int64 {
-	return int64(binary.LittleEndian.Uint64(val))
+	return int64(binary.BigEndian.Uint64(val) ^ signBit)
 }
 
-// f64ToB converts a float64 to a byte slice of size 8
+// f64ToB converts a float64 to a byte slice of size 8. Positive numbers have their sign bit set and negative numbers
+// have all of their bits flipped, so larger magnitude negative numbers order first.
 func f64ToB(val float64) []byte {
+	bits := math.Float64bits(val)
+
+	if bits&signBit != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit
+	}
+
 	b := make([]byte, 8)
-	binary.LittleEndian.PutUint64(b, math.Float64bits(val))
+	binary.BigEndian.PutUint64(b, bits)
 
 	return b
 }
 
 // bToF64 converts a byte slice of size 8 to a float64
 func bToF64(val []byte) float64 {
-	return math.Float64frombits(binary.LittleEndian.Uint64(val))
+	bits := binary.BigEndian.Uint64(val)
+
+	if bits&signBit != 0 {
+		bits &^= signBit
+	} else {
+		bits = ^bits
+	}
+
+	return math.Float64frombits(bits)
+}
+
+const signBit uint64 = 1 << 63
+
+// appendUint16 appends the big endian encoding of an uint16 to the byte slice.
+func appendUint16(b []byte, val uint16) []byte {
+	return append(b, byte(val>>8), byte(val))
+}
+
+// appendUint32 appends the big endian encoding of an uint32 to the byte slice.
+func appendUint32(b []byte, val uint32) []byte {
+	return append(b, byte(val>>24), byte(val>>16), byte(val>>8), byte(val))
+}
+
+// appendUint64 appends the big endian encoding of an uint64 to the byte slice.
+func appendUint64(b []byte, val uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(val>>32)), uint32(val))
 }
 
 // boolToB converts a bool to a byte slice of size 1
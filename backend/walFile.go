@@ -0,0 +1,711 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// The write-ahead log makes every statement atomic. A statement does not write to the files of a table directly,
+// it collects its writes in a walBatch instead. Committing the batch appends every write to the log followed by a
+// commit record and syncs the log, and only then are the writes applied to the files. If the process crashes before
+// the log is synced, none of the writes have been applied. If it crashes after, the writes are applied again when the
+// log is recovered, which is safe because each write is of the exact bytes at an exact offset.
+//
+// The statements of a Transaction are not committed until the transaction is, so they are logged differently, see
+// Transaction. Each of their writes also logs the bytes it overwrote and the size of the file before it, which lets
+// them be undone. Recovery repeats every write in the log in order, committed or not, undoing the writes of a
+// transaction where it was rolled back, and then undoes the writes of every transaction that never ended.
+//
+// The writes to the files of tables are applied to their pages in the buffer pool, which are only written to the files
+// later, see bufferPool. Once the log is larger than walCheckpointSize, it is checkpointed: the dirty pages of the pool
+// are written to their files, every file that was written to is synced and the log is emptied, since none of its
+// records are needed anymore, except for those of transactions that are still open.
+//
+// A walBatch can also rename and remove files, which makes changes to several files, such as dropping a table with its
+// indexes, atomic. Renames and removes are repeated by recovery like writes, and doing them again does nothing. The
+// log is checkpointed before and after a batch that renames or removes files, so it never has writes to a path that
+// was renamed or removed, and a path that is used again later is never renamed or removed again by recovery.
+
+// walCheckpointSize is the size in bytes that the log can reach before it is checkpointed.
+var walCheckpointSize int64 = 4 * 1024 * 1024
+
+func (s *Store) getWALFilePath() string {
+	return s.path("wal")
+}
+
+// The type of a record in the log.
+const (
+	walRecordWrite    byte = 1
+	walRecordTruncate byte = 2
+	walRecordCommit   byte = 3
+	walRecordRollback byte = 4
+	walRecordRename   byte = 5
+	walRecordRemove   byte = 6
+)
+
+// hasPath returns whether records of the type are of a file.
+func hasPath(op byte) bool {
+	return op == walRecordWrite || op == walRecordTruncate || op == walRecordRename || op == walRecordRemove
+}
+
+// walRecord is a single change to a file, the commit of every change with the same tx before it, or the rollback of
+// the changes of a transaction. data is the bytes written at offset for a write, or the new path of the file for a
+// rename, while size is the new size of the file for a truncate. For a rollback, kept is the number of changes of the
+// transaction that are not undone.
+//
+// The changes of a transaction also have the size of the file before the change as prevSize and the bytes the change
+// overwrote as before, which starts at offset for a write and at size for a truncate. prevSize is -1 for the changes
+// of a walBatch, which can not be undone.
+type walRecord struct {
+	op       byte
+	tx       uint64
+	path     string
+	offset   int64
+	size     int64
+	data     []byte
+	prevSize int64
+	before   []byte
+	kept     int64
+	file     dataFile
+}
+
+// dataFile is a file that the records of the log are applied to, which is either an *os.File or a pageFile.
+type dataFile interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Truncate(size int64) error
+	Stat() (os.FileInfo, error)
+	Name() string
+}
+
+// wal is the write-ahead log of a store, whose files have their dirty pages in pool. active is held for reading while a
+// batch is being committed, so a checkpoint, which holds it for writing, never empties the log while there are writes
+// in it that have not been applied yet. transactions are the transactions that have written to the log and not ended, and retained is the size
+// of their records which were kept in the log by the last checkpoint.
+type wal struct {
+	pool         *bufferPool
+	mu           sync.Mutex
+	active       sync.RWMutex
+	file         *os.File
+	size         int64
+	retained     int64
+	lastTx       uint64
+	dirty        map[string]bool
+	transactions map[uint64]*Transaction
+}
+
+// getWAL returns the log of the store, opening it and recovering it if it is not open yet.
+func (s *Store) getWAL() (*wal, error) {
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+
+	if s.wal != nil {
+		return s.wal, nil
+	}
+
+	err := s.ensureDir()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(s.getWALFilePath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open write-ahead log: %w", err)
+	}
+
+	w := &wal{pool: s.pool, file: file, dirty: map[string]bool{}, transactions: map[uint64]*Transaction{}}
+
+	err = w.recover()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not recover write-ahead log: %w", err)
+	}
+
+	s.wal = w
+
+	return w, nil
+}
+
+// recover replays the write-ahead log of the store if it is not open yet, so that every statement that was committed
+// before the database last stopped is applied to the tables.
+func (s *Store) recover() error {
+	_, err := s.getWAL()
+
+	return err
+}
+
+// Checkpoint syncs every file written to since the last checkpoint and closes the write-ahead log and the catalogs,
+// which are opened again once they are needed. It is called by Close, once every transaction has ended.
+func (s *Store) Checkpoint() error {
+	err := s.closeCatalogs()
+	if err != nil {
+		return fmt.Errorf("could not close catalogs: %w", err)
+	}
+
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+
+	s.wal.mu.Lock()
+	open := len(s.wal.transactions)
+	s.wal.mu.Unlock()
+
+	if open != 0 {
+		return fmt.Errorf("could not close write-ahead log, %d transactions have not ended", open)
+	}
+
+	err = s.wal.checkpoint()
+	if err != nil {
+		return err
+	}
+
+	err = s.wal.file.Close()
+	s.wal = nil
+
+	return err
+}
+
+// checkpointWAL checkpoints the log of the store. It must be called before files are removed, since the log must
+// never have writes to a file that was removed, which would recreate it if they were replayed.
+func (s *Store) checkpointWAL() error {
+	w, err := s.getWAL()
+	if err != nil {
+		return err
+	}
+
+	return w.checkpoint()
+}
+
+// recover repeats the writes of the log, undoing those of transactions that were rolled back or never ended, and
+// checkpoints it. The writes of a walBatch are only applied once its commit record is read.
+func (w *wal) recover() error {
+	reader := bufio.NewReader(w.file)
+	pending := map[uint64][]walRecord{}
+	undoable := map[uint64][]walRecord{}
+	files := map[string]*os.File{}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for {
+		record, n, err := readWALRecord(reader)
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errCorruptWALRecord) {
+			// the end of the log, or a record that was only partially written before the crash
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.size += n
+
+		if record.tx > w.lastTx {
+			w.lastTx = record.tx
+		}
+
+		if record.op == walRecordWrite || record.op == walRecordTruncate {
+			file, opened := files[record.path]
+			if !opened {
+				file, err = os.OpenFile(record.path, os.O_RDWR|os.O_CREATE, 0644)
+				if err != nil {
+					return fmt.Errorf("could not open %s: %w", record.path, err)
+				}
+
+				files[record.path] = file
+			}
+
+			record.file = file
+		}
+
+		switch record.op {
+		case walRecordWrite, walRecordTruncate, walRecordRename, walRecordRemove:
+			if record.prevSize < 0 {
+				pending[record.tx] = append(pending[record.tx], record)
+				continue
+			}
+
+			err = record.apply()
+			if err != nil {
+				return err
+			}
+
+			undoable[record.tx] = append(undoable[record.tx], record)
+		case walRecordRollback:
+			records := undoable[record.tx]
+			if record.kept > int64(len(records)) {
+				return errCorruptWALRecord
+			}
+
+			err = undoRecords(records[record.kept:])
+			if err != nil {
+				return err
+			}
+
+			undoable[record.tx] = records[:record.kept]
+		case walRecordCommit:
+			for _, r := range pending[record.tx] {
+				err = r.apply()
+				if err != nil {
+					return err
+				}
+			}
+
+			delete(pending, record.tx)
+			delete(undoable, record.tx)
+		}
+	}
+
+	// transactions only hold the tables they wrote to, so the ones that never ended wrote to different files
+	for _, records := range undoable {
+		err := undoRecords(records)
+		if err != nil {
+			return err
+		}
+	}
+
+	for path := range files {
+		w.dirty[path] = true
+	}
+
+	return w.checkpoint()
+}
+
+// checkpoint writes the dirty pages of the buffer pool to their files and syncs every file that has been written to
+// since the last checkpoint, and then empties the log of everything but the records of transactions that have not
+// ended.
+func (w *wal) checkpoint() error {
+	w.active.Lock()
+	defer w.active.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.pool.flush()
+	if err != nil {
+		return err
+	}
+
+	for path := range w.dirty {
+		err := syncFile(path)
+		if err != nil {
+			return fmt.Errorf("could not sync %s: %w", path, err)
+		}
+
+		delete(w.dirty, path)
+	}
+
+	var retained []byte
+	for _, tx := range w.transactions {
+		retained = append(retained, tx.logged...)
+	}
+
+	err = w.file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("could not empty write-ahead log: %w", err)
+	}
+
+	_, err = w.file.WriteAt(retained, 0)
+	if err != nil {
+		return fmt.Errorf("could not write to write-ahead log: %w", err)
+	}
+
+	err = w.file.Sync()
+	if err != nil {
+		return fmt.Errorf("could not sync write-ahead log: %w", err)
+	}
+
+	w.size = int64(len(retained))
+	w.retained = w.size
+
+	return nil
+}
+
+// syncFile flushes the file at the path to the storage device. A file that no longer exists has nothing to flush.
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+	defer file.Close()
+
+	return file.Sync()
+}
+
+// walBatch is the writes of a single statement, which are applied all together or not at all once it is committed to
+// the log of its store.
+type walBatch struct {
+	store   *Store
+	records []walRecord
+}
+
+func (s *Store) newWALBatch() *walBatch {
+	return &walBatch{store: s}
+}
+
+// writeAt adds a write of the bytes at the offset of the file to the batch, in the same way as file.WriteAt.
+func (b *walBatch) writeAt(file dataFile, data []byte, offset int64) {
+	b.records = append(b.records, walRecord{op: walRecordWrite, path: file.Name(), offset: offset, data: data, prevSize: -1, file: file})
+}
+
+// truncate adds a change of the size of the file to the batch, in the same way as file.Truncate.
+func (b *walBatch) truncate(file dataFile, size int64) {
+	b.records = append(b.records, walRecord{op: walRecordTruncate, path: file.Name(), size: size, prevSize: -1, file: file})
+}
+
+// rename adds a rename of the file at the path to the batch, in the same way as os.Rename. The log must be
+// checkpointed before and after the batch is committed, see checkpointWAL.
+func (b *walBatch) rename(path string, newPath string) {
+	b.records = append(b.records, walRecord{op: walRecordRename, path: path, data: []byte(newPath), prevSize: -1})
+}
+
+// remove adds a removal of the file at the path to the batch, in the same way as os.Remove. The log must be
+// checkpointed before and after the batch is committed, see checkpointWAL.
+func (b *walBatch) remove(path string) {
+	b.records = append(b.records, walRecord{op: walRecordRemove, path: path, prevSize: -1})
+}
+
+// commit writes the batch to the log, followed by a commit record, and then applies it to the files.
+func (b *walBatch) commit() error {
+	if len(b.records) == 0 {
+		return nil
+	}
+
+	w, err := b.store.getWAL()
+	if err != nil {
+		return err
+	}
+
+	w.active.RLock()
+
+	err = w.append(b.records)
+	if err == nil {
+		for _, record := range b.records {
+			err = record.apply()
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	w.active.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	b.records = nil
+
+	return w.checkpointIfFull()
+}
+
+// commitFiles commits a batch that renames or removes files, checkpointing the log before and after it.
+func (b *walBatch) commitFiles() error {
+	err := b.store.checkpointWAL()
+	if err != nil {
+		return err
+	}
+
+	err = b.commit()
+	if err != nil {
+		return err
+	}
+
+	return b.store.checkpointWAL()
+}
+
+// applyUnlogged applies the batch to the files without writing it to the log. It is only for files that are not part
+// of the database yet, such as temporary files that a later batch renames into place, since a crash can leave its
+// writes partially applied.
+func (b *walBatch) applyUnlogged() error {
+	for _, record := range b.records {
+		err := record.apply()
+		if err != nil {
+			return err
+		}
+	}
+
+	b.records = nil
+
+	return nil
+}
+
+// checkpointIfFull checkpoints the log if it has grown by more than walCheckpointSize since the last checkpoint.
+func (w *wal) checkpointIfFull() error {
+	w.mu.Lock()
+	full := w.size-w.retained > walCheckpointSize
+	w.mu.Unlock()
+
+	if full {
+		return w.checkpoint()
+	}
+
+	return nil
+}
+
+// append writes the records to the log as a single transaction and syncs it.
+func (w *wal) append(records []walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastTx++
+
+	var b []byte
+	for _, record := range records {
+		record.tx = w.lastTx
+		b = appendWALRecord(b, record)
+
+		w.dirty[record.path] = true
+	}
+
+	b = appendWALRecord(b, walRecord{op: walRecordCommit, tx: w.lastTx})
+
+	return w.write(b)
+}
+
+// appendTransaction writes the records of the transaction to the log and syncs it. Until the transaction ends, which
+// its last records must do, they are also kept with it so that checkpoints do not discard them.
+func (w *wal) appendTransaction(tx *Transaction, records []walRecord, ends bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if tx.id == 0 {
+		w.lastTx++
+		tx.id = w.lastTx
+		w.transactions[tx.id] = tx
+	}
+
+	var b []byte
+	for _, record := range records {
+		record.tx = tx.id
+		b = appendWALRecord(b, record)
+
+		if record.path != "" {
+			w.dirty[record.path] = true
+		}
+	}
+
+	err := w.write(b)
+	if err != nil {
+		return err
+	}
+
+	if ends {
+		delete(w.transactions, tx.id)
+		tx.logged = nil
+	} else {
+		tx.logged = append(tx.logged, b...)
+	}
+
+	return nil
+}
+
+// write appends the encoded records to the log and syncs it. The log must be locked.
+func (w *wal) write(b []byte) error {
+	_, err := w.file.WriteAt(b, w.size)
+	if err != nil {
+		return fmt.Errorf("could not write to write-ahead log: %w", err)
+	}
+
+	err = w.file.Sync()
+	if err != nil {
+		return fmt.Errorf("could not sync write-ahead log: %w", err)
+	}
+
+	w.size += int64(len(b))
+
+	return nil
+}
+
+// apply makes the change of the record to its file.
+func (r walRecord) apply() error {
+	switch r.op {
+	case walRecordWrite:
+		_, err := r.file.WriteAt(r.data, r.offset)
+		if err != nil {
+			return fmt.Errorf("could not write to %s: %w", r.path, err)
+		}
+	case walRecordTruncate:
+		err := r.file.Truncate(r.size)
+		if err != nil {
+			return fmt.Errorf("could not truncate %s: %w", r.path, err)
+		}
+	case walRecordRename:
+		// the file was already renamed if the record is repeated by recovery
+		err := os.Rename(r.path, string(r.data))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rename %s: %w", r.path, err)
+		}
+	case walRecordRemove:
+		err := os.Remove(r.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove %s: %w", r.path, err)
+		}
+	}
+
+	return nil
+}
+
+// undo restores the bytes that the change of the record overwrote and the size its file had before it. Undoing the
+// changes of a transaction in the reverse order they were made restores the files to how they were before it.
+func (r walRecord) undo() error {
+	start := r.offset
+	if r.op == walRecordTruncate {
+		start = r.size
+	}
+
+	if len(r.before) != 0 {
+		_, err := r.file.WriteAt(r.before, start)
+		if err != nil {
+			return fmt.Errorf("could not write to %s: %w", r.path, err)
+		}
+	}
+
+	err := r.file.Truncate(r.prevSize)
+	if err != nil {
+		return fmt.Errorf("could not truncate %s: %w", r.path, err)
+	}
+
+	return nil
+}
+
+// undoRecords undoes the changes of the records in reverse order.
+func undoRecords(records []walRecord) error {
+	for i := len(records) - 1; i >= 0; i-- {
+		err := records[i].undo()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// errCorruptWALRecord is returned when a record of the log does not match its checksum.
+var errCorruptWALRecord = errors.New("corrupt write-ahead log record")
+
+// A record is the number of bytes of its payload as an uint32, the CRC-32 checksum of the payload as an uint32 and
+// then the payload. The payload is the byte of its type and the tx as an uint64, followed for writes and truncates
+// by the number of bytes of the path as an uint16, the path and then prevSize as an int64, as do renames and removes.
+// A write then has its offset as an int64, the number of bytes of before as an uint32 and before, and the rest of the
+// payload is the data. A truncate has the new size of the file as an int64 and the rest of the payload is before. The
+// rest of the payload of a rename is the new path. A rollback has kept as an int64.
+func appendWALRecord(b []byte, record walRecord) []byte {
+	payload := []byte{record.op}
+	payload = appendUint64(payload, record.tx)
+
+	if hasPath(record.op) {
+		payload = appendUint16(payload, uint16(len(record.path)))
+		payload = append(payload, record.path...)
+		payload = appendUint64(payload, uint64(record.prevSize))
+	}
+
+	switch record.op {
+	case walRecordWrite:
+		payload = appendUint64(payload, uint64(record.offset))
+		payload = appendUint32(payload, uint32(len(record.before)))
+		payload = append(payload, record.before...)
+		payload = append(payload, record.data...)
+	case walRecordTruncate:
+		payload = appendUint64(payload, uint64(record.size))
+		payload = append(payload, record.before...)
+	case walRecordRename:
+		payload = append(payload, record.data...)
+	case walRecordRollback:
+		payload = appendUint64(payload, uint64(record.kept))
+	}
+
+	b = appendUint32(b, uint32(len(payload)))
+	b = appendUint32(b, crc32.ChecksumIEEE(payload))
+
+	return append(b, payload...)
+}
+
+// readWALRecord reads the next record of the log and returns it with the number of bytes it took up.
+func readWALRecord(reader io.Reader) (walRecord, int64, error) {
+	head := make([]byte, 8)
+
+	_, err := io.ReadFull(reader, head)
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(head))
+
+	_, err = io.ReadFull(reader, payload)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(head[4:]) || len(payload) < 9 {
+		return walRecord{}, 0, errCorruptWALRecord
+	}
+
+	record := walRecord{op: payload[0], tx: binary.BigEndian.Uint64(payload[1:9])}
+	rest := payload[9:]
+
+	if hasPath(record.op) {
+		if len(rest) < 2 || len(rest) < 2+int(binary.BigEndian.Uint16(rest))+8 {
+			return walRecord{}, 0, errCorruptWALRecord
+		}
+
+		pathLength := int(binary.BigEndian.Uint16(rest))
+		record.path = string(rest[2 : 2+pathLength])
+		record.prevSize = int64(binary.BigEndian.Uint64(rest[2+pathLength:]))
+		rest = rest[2+pathLength+8:]
+	}
+
+	switch record.op {
+	case walRecordWrite:
+		if len(rest) < 12 || len(rest) < 12+int(binary.BigEndian.Uint32(rest[8:])) {
+			return walRecord{}, 0, errCorruptWALRecord
+		}
+
+		record.offset = int64(binary.BigEndian.Uint64(rest))
+		beforeLength := int(binary.BigEndian.Uint32(rest[8:]))
+		record.before = rest[12 : 12+beforeLength]
+		record.data = rest[12+beforeLength:]
+	case walRecordTruncate:
+		if len(rest) < 8 {
+			return walRecord{}, 0, errCorruptWALRecord
+		}
+
+		record.size = int64(binary.BigEndian.Uint64(rest))
+		record.before = rest[8:]
+	case walRecordRename:
+		record.data = rest
+	case walRecordRemove:
+	case walRecordRollback:
+		if len(rest) < 8 {
+			return walRecord{}, 0, errCorruptWALRecord
+		}
+
+		record.kept = int64(binary.BigEndian.Uint64(rest))
+	case walRecordCommit:
+	default:
+		return walRecord{}, 0, errCorruptWALRecord
+	}
+
+	return record, int64(len(head) + len(payload)), nil
+}
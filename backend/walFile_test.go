@@ -0,0 +1,291 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openTestStore opens a store of the directory, which is closed once the test is done unless it crashed.
+func openTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+
+	s, err := OpenStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		storesMu.Lock()
+		open := openStores[s.key] == s
+		storesMu.Unlock()
+
+		if open {
+			_ = s.Close()
+		}
+	})
+
+	return s
+}
+
+// crash stops the store as if the process had crashed: the pages of its buffer pool are lost without being written
+// to their files, and its files are closed without being synced or checkpointed. The tables must not be used after,
+// while the directory can be opened again.
+func crash(s *Store, tables ...OperableTable) {
+	s.pool.mu.Lock()
+	for _, f := range s.pool.frames {
+		if f.file != nil && f.io == nil {
+			s.pool.drop(f)
+		}
+	}
+	s.pool.mu.Unlock()
+
+	for _, ot := range tables {
+		t := ot.(*table)
+
+		t.mrw.Lock()
+		t.failed = errTableClosed
+		_ = t.closeFiles()
+		t.mrw.Unlock()
+	}
+
+	s.walMu.Lock()
+	if s.wal != nil {
+		_ = s.wal.file.Close()
+		s.wal = nil
+	}
+	s.walMu.Unlock()
+
+	s.mvccMu.Lock()
+	if s.mvcc != nil {
+		_ = s.mvcc.file.Close()
+		s.mvcc = nil
+	}
+	s.mvccMu.Unlock()
+
+	s.catalogMu.Lock()
+	for _, c := range s.catalogs {
+		_ = c.file.Close()
+	}
+	s.catalogMu.Unlock()
+
+	storesMu.Lock()
+	delete(openStores, s.key)
+	storesMu.Unlock()
+}
+
+var testFields = []Field{{Name: "id", Type: PrimitiveInt}, {Name: "s", Type: PrimitiveString}}
+
+func insertTestRow(t *testing.T, ctx context.Context, ot OperableTable, id int) {
+	t.Helper()
+
+	_, err := ot.InsertRow(ctx, []Value{
+		{Type: PrimitiveInt, Val: int64(id), FieldName: "id"},
+		{Type: PrimitiveString, Val: fmt.Sprintf("a string that is long enough to fill pages %d", id), FieldName: "s"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func idFilter(operator Operator, id int) []Filter {
+	return []Filter{{Value: Value{Type: PrimitiveInt, Val: int64(id)}, FieldName: "id", Operator: operator}}
+}
+
+// dumpRows returns the rows of the table that the statements of the context see, sorted, as a string.
+func dumpRows(t *testing.T, ctx context.Context, ot OperableTable) string {
+	t.Helper()
+
+	rows, err := ot.GetRows(ctx, nil, nil, NoLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dumped := make([]string, len(rows))
+	for i, row := range rows {
+		dumped[i] = fmt.Sprint(row.Values[0].Val, "=", row.Values[1].Val)
+	}
+
+	sort.Strings(dumped)
+
+	return fmt.Sprintf("%d rows: %s", len(rows), strings.Join(dumped, ", "))
+}
+
+func TestRecoverCommittedStatements(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := openTestStore(t, dir)
+
+	ot, err := s.CreateTable(ctx, "kv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ot.CreateIndex(ctx, Index{Name: "kv_id", FieldName: "id", Unique: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 600; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	_, err = ot.UpdateRows(ctx, []Value{{Type: PrimitiveString, Val: "updated", FieldName: "s"}}, idFilter(OperatorLessThan, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ot.DeleteRows(ctx, idFilter(OperatorGreaterThanOrEqual, 500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := dumpRows(t, ctx, ot)
+
+	// none of the pages of the statements were written to the files of the table
+	crash(s, ot)
+
+	s = openTestStore(t, dir)
+
+	ot, err = s.OpenTable(ctx, "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ot.Cleanup()
+
+	if got := dumpRows(t, ctx, ot); got != want {
+		t.Fatalf("recovered %.50s, want %.50s", got, want)
+	}
+
+	// the index was recovered along with the table
+	rows, err := ot.GetRows(ctx, nil, idFilter(OperatorEqual, 42), NoLimit)
+	if err != nil || len(rows) != 1 || rows[0].Values[1].Val != "updated" {
+		t.Fatalf("found %v, %v by the index", rows, err)
+	}
+
+	_, err = ot.InsertRow(ctx, []Value{{Type: PrimitiveInt, Val: int64(42), FieldName: "id"}})
+	if err == nil {
+		t.Fatal("inserted a row with a duplicate value into the unique index")
+	}
+}
+
+func TestRecoverUndoesOpenTransactions(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := openTestStore(t, dir)
+
+	ot, err := s.CreateTable(ctx, "kv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 200; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	want := dumpRows(t, ctx, ot)
+
+	tx, err := s.BeginTransaction(IsolationReadCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txCtx := ContextWithTransaction(ctx, tx)
+
+	for i := 1000; i < 1300; i++ {
+		insertTestRow(t, txCtx, ot, i)
+	}
+
+	// a checkpoint writes the changes of the transaction to the files, which recovery has to undo
+	err = s.checkpointWAL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ot.DeleteRows(txCtx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := dumpRows(t, txCtx, ot); got != "0 rows: " {
+		t.Fatalf("the transaction sees %.50s", got)
+	}
+
+	crash(s, ot)
+
+	s = openTestStore(t, dir)
+
+	ot, err = s.OpenTable(ctx, "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ot.Cleanup()
+
+	if got := dumpRows(t, ctx, ot); got != want {
+		t.Fatalf("recovered %.50s, want %.50s", got, want)
+	}
+
+	if ot.RowCount() != 200 {
+		t.Fatalf("the table counts %d rows, want 200", ot.RowCount())
+	}
+}
+
+func TestRecoverIgnoresPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := openTestStore(t, dir)
+
+	ot, err := s.CreateTable(ctx, "kv", testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		insertTestRow(t, ctx, ot, i)
+	}
+
+	want := dumpRows(t, ctx, ot)
+	walPath := s.getWALFilePath()
+
+	crash(s, ot)
+
+	// the crash happened while a record was being appended to the log
+	file, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = file.Write([]byte{walRecordWrite, 0, 0, 0, 7, 'p', 'a'})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestStore(t, dir)
+
+	ot, err = s.OpenTable(ctx, "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ot.Cleanup()
+
+	if got := dumpRows(t, ctx, ot); got != want {
+		t.Fatalf("recovered %.50s, want %.50s", got, want)
+	}
+
+	insertTestRow(t, ctx, ot, 50)
+
+	if ot.RowCount() != 51 {
+		t.Fatalf("the table counts %d rows, want 51", ot.RowCount())
+	}
+}
@@ -0,0 +1,488 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Transaction groups statements so that they are committed or rolled back together. A statement runs in a
+// transaction when its context is from ContextWithTransaction.
+//
+// The writes of a statement in a transaction are applied to the files right away, but they are logged together with
+// the bytes they overwrote, and the commit record of the transaction is only logged once it is committed. Rolling
+// back undoes the writes in reverse and reads the state of every table it wrote to again from its files. A
+// transaction holds every table it writes to until it ends, see lockForWrite, so no other statement can write to the
+// rows it changed before it is committed or rolled back. Its changes are invisible to every other statement until it
+// is committed, see mvcc.
+//
+// Creating and dropping tables and indexes can not be done inside a transaction.
+type Transaction struct {
+	Isolation IsolationLevel
+
+	mu         sync.Mutex
+	store      *Store
+	id         uint64
+	xid        uint64
+	snapshot   *snapshot
+	records    []walRecord
+	logged     []byte
+	savepoints []savepoint
+	tables     []*table
+	done       bool
+}
+
+// IsolationLevel is what a transaction sees of the changes of the transactions that commit while it is running.
+type IsolationLevel int
+
+const (
+	// IsolationReadCommitted gives each statement of the transaction a snapshot of every transaction that had
+	// committed when the statement began.
+	IsolationReadCommitted IsolationLevel = iota
+	// IsolationSnapshot gives every statement of the transaction the snapshot taken when it began. Changing a row
+	// that another transaction changed and committed since is an error.
+	IsolationSnapshot
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case IsolationReadCommitted:
+		return "read committed"
+	case IsolationSnapshot:
+		return "snapshot"
+	}
+
+	return fmt.Sprintf("IsolationLevel(%d)", int(l))
+}
+
+// savepoint is a point in a transaction that it can be rolled back to. records is the number of writes the
+// transaction had made when it was created.
+type savepoint struct {
+	name    string
+	records int
+}
+
+// errTransactionDone is returned when a transaction is used after it was committed or rolled back.
+var errTransactionDone = errors.New("transaction has already been committed or rolled back")
+
+// BeginTransaction starts a new transaction with the isolation level, which can use every table of the store. It must
+// be ended by either Commit or Rollback.
+func (s *Store) BeginTransaction(isolation IsolationLevel) (*Transaction, error) {
+	m, err := s.getMVCC()
+	if err != nil {
+		return nil, err
+	}
+
+	xid, err := m.begin()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{Isolation: isolation, store: s, xid: xid}
+
+	if isolation == IsolationSnapshot {
+		tx.snapshot = m.takeSnapshot(xid)
+	}
+
+	return tx, nil
+}
+
+type transactionKey struct{}
+
+// ContextWithTransaction returns a copy of the context that runs the statements it is passed to in the transaction.
+func ContextWithTransaction(ctx context.Context, tx *Transaction) context.Context {
+	return context.WithValue(ctx, transactionKey{}, tx)
+}
+
+// transactionFromContext returns the transaction of the context, or nil if the statements of the context are each
+// committed on their own.
+func transactionFromContext(ctx context.Context) *Transaction {
+	tx, _ := ctx.Value(transactionKey{}).(*Transaction)
+
+	return tx
+}
+
+// checkTransaction returns an error if the transaction, which can be nil, is of another store, whose tables are the
+// only ones it can use.
+func (s *Store) checkTransaction(tx *Transaction) error {
+	if tx != nil && tx.store != s {
+		return fmt.Errorf("the transaction is of the data directory %s, it can not use the tables of %s", tx.store.dir, s.dir)
+	}
+
+	return nil
+}
+
+// Commit makes every change of the transaction durable and ends it.
+func (tx *Transaction) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	// a transaction that never wrote anything has nothing to log
+	if tx.id != 0 {
+		w, err := tx.store.getWAL()
+		if err != nil {
+			return err
+		}
+
+		err = w.appendTransaction(tx, []walRecord{{op: walRecordCommit}}, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx.end()
+
+	return nil
+}
+
+// Rollback undoes every change of the transaction and ends it.
+func (tx *Transaction) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	err := tx.rollback(0, true)
+
+	// the tables are released even if undoing the changes failed, they are marked as failed in that case
+	tx.end()
+
+	return err
+}
+
+// Savepoint creates a savepoint with the name, which the transaction can be rolled back to without ending it. A
+// savepoint with the same name as an earlier one hides it until it is released.
+func (tx *Transaction) Savepoint(name string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	tx.savepoints = append(tx.savepoints, savepoint{name: name, records: len(tx.records)})
+
+	return nil
+}
+
+// RollbackTo undoes every change of the transaction since the savepoint with the name was created. The savepoint is
+// kept, while every savepoint created after it is released.
+func (tx *Transaction) RollbackTo(name string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	i, err := tx.savepointWithName(name)
+	if err != nil {
+		return err
+	}
+
+	err = tx.rollback(tx.savepoints[i].records, false)
+	if err != nil {
+		return err
+	}
+
+	tx.savepoints = tx.savepoints[:i+1]
+
+	return nil
+}
+
+// ReleaseSavepoint removes the savepoint with the name, and every savepoint created after it, keeping the changes
+// made since.
+func (tx *Transaction) ReleaseSavepoint(name string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	i, err := tx.savepointWithName(name)
+	if err != nil {
+		return err
+	}
+
+	tx.savepoints = tx.savepoints[:i]
+
+	return nil
+}
+
+// savepointWithName returns the position of the latest savepoint with the name.
+func (tx *Transaction) savepointWithName(name string) (int, error) {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf(`savepoint with name "%s" does not exist`, name)
+}
+
+// rollback undoes every write of the transaction after the first kept, logging the rollback first so that it is
+// repeated if the database crashes before the writes are undone. If ends is true, the transaction is also ended in
+// the log. Every table the transaction wrote to is read again from its files.
+func (tx *Transaction) rollback(kept int, ends bool) error {
+	if tx.id == 0 {
+		return nil
+	}
+
+	w, err := tx.store.getWAL()
+	if err != nil {
+		return err
+	}
+
+	records := []walRecord{{op: walRecordRollback, kept: int64(kept)}}
+	if ends {
+		records = append(records, walRecord{op: walRecordCommit})
+	}
+
+	for _, t := range tx.tables {
+		t.mrw.Lock()
+		defer t.mrw.Unlock()
+	}
+
+	// the undo must be applied before a checkpoint can discard the log of the transaction
+	w.active.RLock()
+
+	err = w.appendTransaction(tx, records, ends)
+	if err == nil {
+		err = undoRecords(tx.records[kept:])
+	}
+
+	w.active.RUnlock()
+
+	if err == nil {
+		tx.records = tx.records[:kept]
+	}
+
+	for _, t := range tx.tables {
+		if err != nil {
+			t.failed = fmt.Errorf("table %s could not roll back a change and must be opened again: %w", t.Name, err)
+			continue
+		}
+
+		rErr := t.reload()
+		if rErr != nil {
+			t.failed = fmt.Errorf("table %s could not be read after a rollback and must be opened again: %w", t.Name, rErr)
+			err = rErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return w.checkpointIfFull()
+}
+
+// end marks the transaction as done, which makes its changes visible to the snapshots taken after, and releases
+// every table it wrote to once they have been vacuumed.
+func (tx *Transaction) end() {
+	tx.done = true
+
+	// the xid can only have been read from the state when the transaction began
+	m := tx.store.mvcc
+
+	if tx.snapshot != nil {
+		m.release(tx.snapshot)
+	}
+
+	m.end(tx.xid)
+
+	for _, t := range tx.tables {
+		t.autoVacuum()
+		t.lock.release()
+	}
+
+	tx.tables = nil
+	tx.records = nil
+	tx.savepoints = nil
+}
+
+// apply logs the writes of the batch, along with the bytes they overwrite, and applies them to the files of the
+// table. The table must be locked for writing by the transaction.
+func (tx *Transaction) apply(batch *walBatch) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTransactionDone
+	}
+
+	if len(batch.records) == 0 {
+		return nil
+	}
+
+	// every record is undone to the state of its file before the whole batch, the records are undone in reverse so
+	// the earlier records of the batch are undone last
+	sizes := map[dataFile]int64{}
+
+	for i := range batch.records {
+		record := &batch.records[i]
+
+		size, ok := sizes[record.file]
+		if !ok {
+			stat, err := record.file.Stat()
+			if err != nil {
+				return fmt.Errorf("could not open %s stats: %w", record.path, err)
+			}
+
+			size = stat.Size()
+			sizes[record.file] = size
+		}
+
+		start, end := record.offset, record.offset+int64(len(record.data))
+		if record.op == walRecordTruncate {
+			start, end = record.size, size
+		}
+
+		if end > size {
+			end = size
+		}
+
+		record.prevSize = size
+		record.before = nil
+
+		if start < end {
+			record.before = make([]byte, end-start)
+
+			_, err := record.file.ReadAt(record.before, start)
+			if err != nil {
+				return fmt.Errorf("could not read from %s: %w", record.path, err)
+			}
+		}
+	}
+
+	w, err := tx.store.getWAL()
+	if err != nil {
+		return err
+	}
+
+	w.active.RLock()
+
+	err = w.appendTransaction(tx, batch.records, false)
+	if err == nil {
+		// records that were logged are undone on rollback even if they could not all be applied
+		tx.records = append(tx.records, batch.records...)
+
+		for _, record := range batch.records {
+			err = record.apply()
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	w.active.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	batch.records = nil
+
+	return w.checkpointIfFull()
+}
+
+// lockTimeout is how long a statement waits to write to a table that another transaction has written to before
+// giving up. Two transactions that each wait for a table the other has written to can never continue, so one of them
+// has to give up.
+var lockTimeout = 10 * time.Second
+
+// tableLock is held by the statement or transaction that is writing to a table. A statement holds it until it is
+// done, while a transaction holds it from its first write to the table until it ends. owner is the transaction that
+// holds it, or nil if it is held by a statement that is committed on its own, and released is closed once it is
+// released.
+type tableLock struct {
+	mu       sync.Mutex
+	held     bool
+	owner    *Transaction
+	released chan struct{}
+}
+
+// release releases the lock.
+func (l *tableLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.held = false
+	l.owner = nil
+	close(l.released)
+}
+
+// lockForWrite waits until the statement of the context can write to the table, which is once no other statement
+// is writing to it and no other transaction has written to it. It must be called before the table is locked for
+// writing. If the statement is in a transaction, the transaction holds the table until it ends and the returned
+// function does nothing, otherwise it must be called once the statement is done, and vacuums the table before
+// releasing it.
+func (t *table) lockForWrite(ctx context.Context) (func(), error) {
+	tx := transactionFromContext(ctx)
+
+	err := t.store.checkTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t.lock.mu.Lock()
+
+		if !t.lock.held {
+			t.lock.held = true
+			t.lock.owner = tx
+			t.lock.released = make(chan struct{})
+			t.lock.mu.Unlock()
+
+			if tx == nil {
+				return func() {
+					t.autoVacuum()
+					t.lock.release()
+				}, nil
+			}
+
+			tx.mu.Lock()
+			tx.tables = append(tx.tables, t)
+			tx.mu.Unlock()
+
+			return func() {}, nil
+		}
+
+		if tx != nil && t.lock.owner == tx {
+			t.lock.mu.Unlock()
+			return func() {}, nil
+		}
+
+		released := t.lock.released
+		t.lock.mu.Unlock()
+
+		err = t.waitFor(ctx, released)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitFor waits until the channel is closed, giving up after lockTimeout.
+func (t *table) waitFor(ctx context.Context, released chan struct{}) error {
+	timer := time.NewTimer(lockTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("timed out waiting to write to table %s, which another transaction has written to", t.Name)
+	}
+}
@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// aggregateColumn is a single column of the rows produced by an aggregation. A column without a function is the
+// value of a GROUP BY field, which is the same for every row of a group.
+type aggregateColumn struct {
+	field    backend.Field
+	function language.AggregateFunction
+	index    int // position of the aggregated value in the input rows, -1 for COUNT(*)
+	input    backend.Primitive
+}
+
+// aggregateType returns the type of the result of an aggregate function over values of the input type. COUNT is
+// always an int and AVG always a float, SUM keeps the numeric type it sums and MIN and MAX keep any type.
+func aggregateType(function language.AggregateFunction, input backend.Primitive) (backend.Primitive, error) {
+	switch function {
+	case language.AggregateCount:
+		return backend.PrimitiveInt, nil
+	case language.AggregateSum, language.AggregateAvg:
+		if input != backend.PrimitiveInt && input != backend.PrimitiveFloat {
+			return "", fmt.Errorf("%s requires an int or float field", strings.ToUpper(string(function)))
+		}
+
+		if function == language.AggregateAvg {
+			return backend.PrimitiveFloat, nil
+		}
+
+		return input, nil
+	case language.AggregateMin, language.AggregateMax:
+		return input, nil
+	}
+
+	return "", fmt.Errorf("%s is not a valid aggregate function", function)
+}
+
+// aggregateRows groups the rows of the source, which have the given columns, by the GROUP BY fields and computes the
+// selected aggregates of each group. Groups are filtered by the HAVING clause. It returns the resulting rows and their
+// columns, which are in the order of the select list.
+func aggregateRows(ctx context.Context, source rowIterator, columns []backend.Field, args *language.SelectArgs) (rowIterator, []backend.Field, error) {
+	if args.AllFields {
+		return nil, nil, fmt.Errorf("cannot select * when grouping rows")
+	}
+
+	groupIndexes := make([]int, len(args.GroupBy))
+	for i, expression := range args.GroupBy {
+		index, err := fieldList(columns).indexOf(expression.ColumnName())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		groupIndexes[i] = index
+	}
+
+	// values that are only used by the HAVING clause are computed and then removed
+	expressions := make([]language.SelectExpression, len(args.Expressions))
+	copy(expressions, args.Expressions)
+	expressions = havingExpressions(args.Having, expressions)
+
+	var aggregates []aggregateColumn
+	var fields []backend.Field
+
+	for i, expression := range expressions {
+		column := aggregateColumn{function: expression.Aggregate, index: -1}
+
+		if expression.FieldName != "*" {
+			index, err := fieldList(columns).indexOf(qualifiedName(expression.TableName, expression.FieldName))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			column.index = index
+			column.input = columns[index].Type
+		}
+
+		// every selected field needs to have a single value per group
+		if !expression.IsAggregate() && !contains(groupIndexes, column.index) {
+			return nil, nil, fmt.Errorf("field \"%s\" must be grouped by or used in an aggregate function", expression.ColumnName())
+		}
+
+		column.field = backend.Field{Name: expression.Name(), Type: column.input}
+
+		if expression.IsAggregate() {
+			output, err := aggregateType(expression.Aggregate, column.input)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			column.field.Type = output
+		} else {
+			column.field.Name = columns[column.index].Name
+		}
+
+		// a HAVING clause can compare a value that is already selected
+		if i >= len(args.Expressions) && containsColumn(aggregates, column) {
+			continue
+		}
+
+		aggregates = append(aggregates, column)
+		fields = append(fields, column.field)
+	}
+
+	var it rowIterator = newAggregateIterator(ctx, source, groupIndexes, aggregates)
+
+	if args.Having != nil {
+		filter, err := filterFromWhereClause(args.Having, fieldList(fields))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		it = newFilterIterator(it, []backend.Filter{*filter})
+	}
+
+	if len(aggregates) != len(args.Expressions) {
+		visible := make([]int, len(args.Expressions))
+		for i := range visible {
+			visible[i] = i
+		}
+
+		it = newProjectIterator(it, visible)
+		fields = fields[:len(args.Expressions)]
+	}
+
+	return it, fields, nil
+}
+
+// containsColumn returns whether a column computes the same value as the given one.
+func containsColumn(columns []aggregateColumn, column aggregateColumn) bool {
+	for _, c := range columns {
+		if c.function == column.function && c.index == column.index {
+			return true
+		}
+	}
+
+	return false
+}
+
+// havingExpressions appends every field and aggregate compared in the HAVING clause to expressions.
+func havingExpressions(having *language.WhereClause, expressions []language.SelectExpression) []language.SelectExpression {
+	if having == nil {
+		return expressions
+	}
+
+	if having.IsCompound() {
+		for i := range having.Clauses {
+			expressions = havingExpressions(&having.Clauses[i], expressions)
+		}
+
+		return expressions
+	}
+
+	return append(expressions, language.SelectExpression{TableName: having.TableName, FieldName: having.FieldName, Aggregate: having.Aggregate})
+}
+
+// aggregateIterator groups the rows of its source with a hash table keyed by the values at the group indexes and
+// returns one row per group, in the order each group was first seen. If there are no group indexes, every row
+// belongs to a single group, which exists even if the source has no rows.
+type aggregateIterator struct {
+	ctx          context.Context
+	source       rowIterator
+	groupIndexes []int
+	columns      []aggregateColumn
+
+	aggregated bool
+	memory     *sliceIterator
+	err        error
+}
+
+func newAggregateIterator(ctx context.Context, source rowIterator, groupIndexes []int, columns []aggregateColumn) *aggregateIterator {
+	return &aggregateIterator{ctx: ctx, source: source, groupIndexes: groupIndexes, columns: columns}
+}
+
+func (a *aggregateIterator) Next() bool {
+	if a.err != nil {
+		return false
+	}
+
+	if !a.aggregated {
+		a.aggregated = true
+
+		rows, err := a.aggregate()
+		if err != nil {
+			a.err = err
+			return false
+		}
+
+		a.memory = newSliceIterator(rows)
+	}
+
+	return a.memory.Next()
+}
+
+func (a *aggregateIterator) Row() backend.Row {
+	return a.memory.Row()
+}
+
+func (a *aggregateIterator) Err() error {
+	return a.err
+}
+
+func (a *aggregateIterator) Close() error {
+	return a.source.Close()
+}
+
+// group is the state of the aggregation of a single group. first is the first row of the group, which the values
+// of the GROUP BY fields are taken from.
+type group struct {
+	first        backend.Row
+	accumulators []accumulator
+}
+
+func (a *aggregateIterator) aggregate() ([]backend.Row, error) {
+	groups := map[string]*group{}
+	var order []*group
+
+	for a.source.Next() {
+		if err := a.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		row := a.source.Row()
+		key := a.groupKey(row)
+
+		g, exists := groups[key]
+		if !exists {
+			g = &group{first: row, accumulators: make([]accumulator, len(a.columns))}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		for i, column := range a.columns {
+			if column.function != "" {
+				g.accumulators[i].add(row, column)
+			}
+		}
+	}
+
+	if err := a.source.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(a.groupIndexes) == 0 && len(order) == 0 {
+		order = append(order, &group{accumulators: make([]accumulator, len(a.columns))})
+	}
+
+	rows := make([]backend.Row, len(order))
+	for i, g := range order {
+		values := make([]backend.Value, len(a.columns))
+
+		for j, column := range a.columns {
+			var val interface{}
+
+			if column.function == "" {
+				val = g.first.Values[column.index].Val
+			} else {
+				val = g.accumulators[j].result(column)
+			}
+
+			values[j] = backend.Value{Type: column.field.Type, Val: val, FieldName: column.field.Name}
+		}
+
+		rows[i] = backend.Row{Values: values}
+	}
+
+	return rows, nil
+}
+
+// groupKey encodes the values of a row at the group indexes into a string that is equal for rows of the same group.
+func (a *aggregateIterator) groupKey(row backend.Row) string {
+	var builder strings.Builder
+
+	for _, index := range a.groupIndexes {
+		val := row.Values[index].Val
+
+		// positive and negative zero are the same value
+		if f, ok := val.(float64); ok && f == 0 {
+			val = float64(0)
+		}
+
+		fmt.Fprintf(&builder, "%T:%v\x00", val, val)
+	}
+
+	return builder.String()
+}
+
+// accumulator holds the running state of an aggregate function. count is the number of values aggregated.
+type accumulator struct {
+	count    int64
+	sumInt   int64
+	sumFloat float64
+	extreme  interface{}
+}
+
+func (acc *accumulator) add(row backend.Row, column aggregateColumn) {
+	if column.index == -1 { // COUNT(*)
+		acc.count++
+		return
+	}
+
+	val := row.Values[column.index].Val
+	if val == nil {
+		return
+	}
+
+	acc.count++
+
+	switch column.function {
+	case language.AggregateSum, language.AggregateAvg:
+		switch v := val.(type) {
+		case int64:
+			acc.sumInt += v
+		case float64:
+			acc.sumFloat += v
+		}
+	case language.AggregateMin:
+		if acc.extreme == nil || column.input.Compare(val, acc.extreme) < 0 {
+			acc.extreme = val
+		}
+	case language.AggregateMax:
+		if acc.extreme == nil || column.input.Compare(val, acc.extreme) > 0 {
+			acc.extreme = val
+		}
+	}
+}
+
+// result returns the value of the aggregate function. Aggregates other than COUNT of no values are nil.
+func (acc *accumulator) result(column aggregateColumn) interface{} {
+	if column.function == language.AggregateCount {
+		return acc.count
+	}
+
+	if acc.count == 0 {
+		return nil
+	}
+
+	switch column.function {
+	case language.AggregateSum:
+		if column.input == backend.PrimitiveInt {
+			return acc.sumInt
+		}
+
+		return acc.sumFloat
+	case language.AggregateAvg:
+		if column.input == backend.PrimitiveInt {
+			return float64(acc.sumInt) / float64(acc.count)
+		}
+
+		return acc.sumFloat / float64(acc.count)
+	}
+
+	return acc.extreme
+}
@@ -0,0 +1,363 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// openTable is a table in the catalog of the engine. ready is closed once the table has been opened or created, after
+// which table is set, or err if that failed.
+type openTable struct {
+	ready chan struct{}
+	table backend.OperableTable
+	err   error
+}
+
+// wait waits until the table has been opened or created.
+func (o *openTable) wait(ctx context.Context) (backend.OperableTable, error) {
+	select {
+	case <-o.ready:
+		return o.table, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tableKey is the key of a table in the catalog of the engine, since tables of different databases can have the same
+// name.
+type tableKey struct {
+	database string
+	name     string
+}
+
+// keyOf returns the key of the table with the name in the database of the context.
+func keyOf(ctx context.Context, name string) tableKey {
+	return tableKey{database: backend.DatabaseFromContext(ctx), name: name}
+}
+
+// getTable returns the table with the name in the database of the context, opening it the first time it is used. A
+// table is only opened once, so a statement that uses a table while another opens it waits for it to be open.
+func (e *SQLEngine) getTable(ctx context.Context, name string) (backend.OperableTable, error) {
+	key := keyOf(ctx, name)
+
+	e.mu.Lock()
+
+	o, exists := e.openTables[key]
+	if exists {
+		e.mu.Unlock()
+		return o.wait(ctx)
+	}
+
+	err := e.checkNotDropping(key.database)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+
+	o = e.reserveTable(key)
+	e.mu.Unlock()
+
+	table, err := e.store.OpenTable(ctx, name)
+	e.finishTable(key, o, table, err)
+
+	return table, err
+}
+
+// reserveTable adds an entry for the table with the key to the catalog, which must be passed to finishTable once it
+// has been opened or created. e.mu must be held.
+func (e *SQLEngine) reserveTable(key tableKey) *openTable {
+	o := &openTable{ready: make(chan struct{})}
+	e.openTables[key] = o
+
+	return o
+}
+
+// finishTable wakes every statement waiting for the table of the entry. A table that could not be opened is removed
+// from the catalog, so the next statement that uses it tries again.
+func (e *SQLEngine) finishTable(key tableKey, o *openTable, table backend.OperableTable, err error) {
+	o.table, o.err = table, err
+
+	if err != nil {
+		e.mu.Lock()
+		delete(e.openTables, key)
+		e.mu.Unlock()
+	}
+
+	close(o.ready)
+}
+
+// checkNotDropping returns an error if the database is being dropped, in which case no more of its tables are opened
+// or created. e.mu must be held.
+func (e *SQLEngine) checkNotDropping(database string) error {
+	if e.dropping[database] {
+		return fmt.Errorf("%w: %s, it is being dropped", backend.ErrDatabaseNotExist, database)
+	}
+
+	return nil
+}
+
+// addTable creates the table in the database of the context and adds it to the catalog.
+func (e *SQLEngine) addTable(ctx context.Context, name string, fields []backend.Field) (backend.OperableTable, error) {
+	key := keyOf(ctx, name)
+
+	e.mu.Lock()
+
+	if _, exists := e.openTables[key]; exists {
+		e.mu.Unlock()
+		return nil, fmt.Errorf(`table with name "%s" already exists`, name)
+	}
+
+	err := e.checkNotDropping(key.database)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+
+	o := e.reserveTable(key)
+	e.mu.Unlock()
+
+	table, err := e.store.CreateTable(ctx, name, fields)
+	e.finishTable(key, o, table, err)
+
+	return table, err
+}
+
+// takeTable returns the table with the name and replaces its entry in the catalog with one that is not ready, so the
+// statements that start using the table wait until the entry is passed to finishTable. It is used to drop or rename
+// the table, after which the entry is finished with an error, or with the table again if that failed.
+func (e *SQLEngine) takeTable(ctx context.Context, name string) (backend.OperableTable, *openTable, error) {
+	key := keyOf(ctx, name)
+
+	for {
+		table, err := e.getTable(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e.mu.Lock()
+
+		// another statement may have taken the table since it was returned
+		if o, exists := e.openTables[key]; exists && o.isReady() && o.table == table {
+			o = e.reserveTable(key)
+			e.mu.Unlock()
+
+			return table, o, nil
+		}
+
+		e.mu.Unlock()
+	}
+}
+
+// isReady returns whether the table has been opened or created.
+func (o *openTable) isReady() bool {
+	select {
+	case <-o.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// removeTable drops the table with the name and removes it from the catalog.
+func (e *SQLEngine) removeTable(ctx context.Context, name string) error {
+	table, o, err := e.takeTable(ctx, name)
+	if errors.Is(err, backend.ErrUnsupportedTableFormat) {
+		// a table that can not be opened is dropped without opening it, so that it can be created again
+		return e.store.DropUnsupportedTable(ctx, name)
+	} else if err != nil {
+		return err
+	}
+
+	key := keyOf(ctx, name)
+
+	err = table.Drop(ctx)
+	if err != nil {
+		e.finishTable(key, o, table, nil)
+		return fmt.Errorf("could not drop table: %w", err)
+	}
+
+	e.finishTable(key, o, nil, fmt.Errorf("%w: %s", backend.ErrTableNotExist, name))
+
+	return nil
+}
+
+// renameTable renames the table with the name and opens it again under its new name, which it is added to the
+// catalog with.
+func (e *SQLEngine) renameTable(ctx context.Context, name string, newName string) (backend.OperableTable, error) {
+	table, o, err := e.takeTable(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, newKey := keyOf(ctx, name), keyOf(ctx, newName)
+
+	e.mu.Lock()
+
+	if _, exists := e.openTables[newKey]; exists {
+		e.mu.Unlock()
+		e.finishTable(key, o, table, nil)
+
+		return nil, fmt.Errorf(`could not rename table: table with name "%s" already exists`, newName)
+	}
+
+	renamed := e.reserveTable(newKey)
+	e.mu.Unlock()
+
+	err = table.Rename(ctx, newName)
+	if err != nil {
+		e.finishTable(key, o, table, nil)
+		e.finishTable(newKey, renamed, nil, fmt.Errorf("%w: %s", backend.ErrTableNotExist, newName))
+
+		return nil, fmt.Errorf("could not rename table: %w", err)
+	}
+
+	e.finishTable(key, o, nil, fmt.Errorf("%w: %s", backend.ErrTableNotExist, name))
+
+	table, err = e.store.OpenTable(ctx, newName)
+	e.finishTable(newKey, renamed, table, err)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open renamed table: %w", err)
+	}
+
+	return table, nil
+}
+
+// alterTableWith changes the table with the name with fn, which changes its fields, and opens it again once it has been
+// changed. Statements that start using the table wait until it has been opened again.
+func (e *SQLEngine) alterTableWith(ctx context.Context, name string, fn func(table backend.OperableTable) error) (backend.OperableTable, error) {
+	table, o, err := e.takeTable(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keyOf(ctx, name)
+
+	err = fn(table)
+	if err != nil {
+		e.finishTable(key, o, table, nil)
+		return nil, fmt.Errorf("could not alter table: %w", err)
+	}
+
+	table, err = e.store.OpenTable(ctx, name)
+	e.finishTable(key, o, table, err)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open altered table: %w", err)
+	}
+
+	return table, nil
+}
+
+// closeTables closes every table that is open and empties the catalog.
+func (e *SQLEngine) closeTables() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var err error
+
+	for key, o := range e.openTables {
+		<-o.ready
+
+		if o.err == nil {
+			cErr := o.table.Cleanup()
+			if err == nil {
+				err = cErr
+			}
+		}
+
+		delete(e.openTables, key)
+	}
+
+	return err
+}
+
+// removeDatabase drops the database with the name and every table of it. The tables of the database that are open are
+// dropped first, one at a time, while no more of its tables are opened, after which the rest of them are dropped
+// together with the database.
+func (e *SQLEngine) removeDatabase(ctx context.Context, name string) error {
+	e.mu.Lock()
+
+	err := e.checkNotDropping(name)
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+
+	e.dropping[name] = true
+
+	var open []string
+	for key := range e.openTables {
+		if key.database != name {
+			continue
+		}
+
+		// system tables have no files of their own, so they are only removed from the catalog
+		if backend.IsSystemTable(key.name) {
+			delete(e.openTables, key)
+			continue
+		}
+
+		open = append(open, key.name)
+	}
+
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		delete(e.dropping, name)
+		e.mu.Unlock()
+	}()
+
+	tableCtx := backend.ContextWithDatabase(ctx, name)
+
+	for _, tableName := range open {
+		// a table that is no longer open was dropped or closed by another statement
+		err = e.removeTable(tableCtx, tableName)
+		if err != nil && !errors.Is(err, backend.ErrTableNotExist) && !errors.Is(err, backend.ErrDatabaseNotExist) {
+			return err
+		}
+	}
+
+	err = e.store.DropDatabase(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not drop database: %w", err)
+	}
+
+	return nil
+}
+
+// Tables returns the schemas of every table of the database of the context, sorted by name.
+func (e *SQLEngine) Tables(ctx context.Context) ([]*TableSchema, error) {
+	names, err := e.store.TableNames(backend.DatabaseFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make([]*TableSchema, 0, len(names))
+
+	for _, name := range names {
+		table, err := e.getTable(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		schemas = append(schemas, schemaOf(table))
+	}
+
+	return schemas, nil
+}
+
+// Table returns the schema of the table with the name in the database of the context. The error wraps backend.ErrTableNotExist if there is no such
+// table.
+func (e *SQLEngine) Table(ctx context.Context, name string) (*TableSchema, error) {
+	table, err := e.getTable(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaOf(table), nil
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// Cursor streams the rows selected by a SELECT statement, which are read from the tables as they are needed, so
+// results of any size can be processed. Columns are the columns of the rows, and the values of a row are like those
+// of a ResultSet. Next must be called before the first row can be read with Row. Once Next returns false, Err should
+// be checked to tell an exhausted cursor apart from a failed one. Close must always be called.
+//
+// A cursor reads from the snapshot of its statement until it is closed. Cancelling the context of the statement stops
+// the cursor, which then returns the error of the context.
+type Cursor struct {
+	Columns []Column
+
+	it      rowIterator
+	release func()
+	closed  bool
+}
+
+func (c *Cursor) Next() bool {
+	return c.it.Next()
+}
+
+func (c *Cursor) Row() []interface{} {
+	return rowValues(c.it.Row())
+}
+
+func (c *Cursor) Err() error {
+	return c.it.Err()
+}
+
+func (c *Cursor) Close() error {
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	err := c.it.Close()
+	c.release()
+
+	return err
+}
+
+// Query parses the given SELECT statement and returns a cursor over the rows it selects, in the default session of
+// the engine.
+func (e *SQLEngine) Query(ctx context.Context, statement string) (*Cursor, error) {
+	return e.session.Query(ctx, statement)
+}
+
+// Query parses the given SELECT statement and returns a cursor over the rows it selects. The session can run other
+// statements while the cursor is open, but it should be closed before the transaction of the session ends.
+func (s *Session) Query(ctx context.Context, statement string) (*Cursor, error) {
+	err := language.Validate(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, args, err := language.Parse(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	if *cmd != language.SelectCommand {
+		return nil, fmt.Errorf("only SELECT statements can be queried for rows")
+	}
+
+	return s.QueryArgs(ctx, args.(*language.SelectArgs))
+}
+
+// QueryArgs is like Query, except that the statement has already been parsed.
+func (s *Session) QueryArgs(ctx context.Context, args *language.SelectArgs) (*Cursor, error) {
+	s.mu.Lock()
+	if s.tx != nil {
+		ctx = backend.ContextWithTransaction(ctx, s.tx)
+	}
+	s.mu.Unlock()
+
+	ctx, release, err := s.engine.store.BeginStatement(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, it, err := s.engine.selectIterator(ctx, args)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return &Cursor{Columns: columns, it: it, release: release}, nil
+}
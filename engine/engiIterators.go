@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// rowIterator is a stream of rows. The operators that execute a query, such as sorting, are built on top of each
+// other through this interface. Next must be called before the first row can be read with Row. Once Next returns
+// false, Err should be checked to tell an exhausted iterator apart from a failed one. Close must always be called.
+type rowIterator interface {
+	Next() bool
+	Row() backend.Row
+	Err() error
+	Close() error
+}
+
+// sliceIterator iterates over rows that are already in memory.
+type sliceIterator struct {
+	rows    []backend.Row
+	current int
+}
+
+func newSliceIterator(rows []backend.Row) *sliceIterator {
+	return &sliceIterator{rows: rows, current: -1}
+}
+
+func (s *sliceIterator) Next() bool {
+	if s.current+1 >= len(s.rows) {
+		return false
+	}
+
+	s.current++
+
+	return true
+}
+
+func (s *sliceIterator) Row() backend.Row {
+	return s.rows[s.current]
+}
+
+func (s *sliceIterator) Err() error {
+	return nil
+}
+
+func (s *sliceIterator) Close() error {
+	s.rows = nil
+
+	return nil
+}
+
+// projectIterator keeps only the values at the given indexes from each row of its source, in the order of the
+// indexes.
+type projectIterator struct {
+	source  rowIterator
+	indexes []int
+	row     backend.Row
+}
+
+func newProjectIterator(source rowIterator, indexes []int) *projectIterator {
+	return &projectIterator{source: source, indexes: indexes}
+}
+
+func (p *projectIterator) Next() bool {
+	if !p.source.Next() {
+		return false
+	}
+
+	sourceRow := p.source.Row()
+	values := make([]backend.Value, len(p.indexes))
+
+	for i, index := range p.indexes {
+		values[i] = sourceRow.Values[index]
+	}
+
+	p.row = backend.Row{Values: values}
+
+	return true
+}
+
+func (p *projectIterator) Row() backend.Row {
+	return p.row
+}
+
+func (p *projectIterator) Err() error {
+	return p.source.Err()
+}
+
+func (p *projectIterator) Close() error {
+	return p.source.Close()
+}
+
+// collectRows reads every remaining row of the iterator and closes it.
+func collectRows(it rowIterator) ([]backend.Row, error) {
+	var rows []backend.Row
+
+	for it.Next() {
+		rows = append(rows, it.Row())
+	}
+
+	err := it.Err()
+	if err != nil {
+		it.Close()
+		return nil, err
+	}
+
+	err = it.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// limitIterator skips the first offset rows of its source and then returns at most limit rows. A negative limit
+// returns every row after the offset.
+type limitIterator struct {
+	source   rowIterator
+	limit    int
+	offset   int
+	returned int
+}
+
+func newLimitIterator(source rowIterator, limit int, offset int) *limitIterator {
+	return &limitIterator{source: source, limit: limit, offset: offset}
+}
+
+func (l *limitIterator) Next() bool {
+	if l.limit >= 0 && l.returned >= l.limit {
+		return false
+	}
+
+	for ; l.offset > 0; l.offset-- {
+		if !l.source.Next() {
+			return false
+		}
+	}
+
+	if !l.source.Next() {
+		return false
+	}
+
+	l.returned++
+
+	return true
+}
+
+func (l *limitIterator) Row() backend.Row {
+	return l.source.Row()
+}
+
+func (l *limitIterator) Err() error {
+	return l.source.Err()
+}
+
+func (l *limitIterator) Close() error {
+	return l.source.Close()
+}
+
+// filterIterator returns the rows of its source that satisfy every filter.
+type filterIterator struct {
+	source  rowIterator
+	filters []backend.Filter
+	err     error
+}
+
+func newFilterIterator(source rowIterator, filters []backend.Filter) *filterIterator {
+	return &filterIterator{source: source, filters: filters}
+}
+
+func (f *filterIterator) Next() bool {
+	for f.err == nil && f.source.Next() {
+		satisfies, err := backend.ValuesSatisfy(f.source.Row().Values, f.filters)
+		if err != nil {
+			f.err = err
+			return false
+		}
+
+		if satisfies {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *filterIterator) Row() backend.Row {
+	return f.source.Row()
+}
+
+func (f *filterIterator) Err() error {
+	if f.err != nil {
+		return f.err
+	}
+
+	return f.source.Err()
+}
+
+func (f *filterIterator) Close() error {
+	return f.source.Close()
+}
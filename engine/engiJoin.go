@@ -0,0 +1,714 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// joinCondition is the equality that rows of a join are combined on. leftIndex and rightIndex are the positions of
+// the compared values in the left and right rows.
+type joinCondition struct {
+	leftIndex  int
+	rightIndex int
+	primitive  backend.Primitive
+}
+
+// newJoinCondition resolves the fields of a JOIN clause to their positions in the left and right columns.
+func newJoinCondition(join language.JoinClause, leftColumns []backend.Field, rightColumns []backend.Field) (joinCondition, error) {
+	leftIndex, err := fieldList(leftColumns).indexOf(qualifiedName(join.ParentTable, join.ParentField))
+	if err != nil {
+		return joinCondition{}, err
+	}
+
+	rightIndex, err := fieldList(rightColumns).indexOf(qualifiedName(join.TableName, join.ChildField))
+	if err != nil {
+		return joinCondition{}, err
+	}
+
+	left, right := leftColumns[leftIndex], rightColumns[rightIndex]
+	if left.Type != right.Type {
+		return joinCondition{}, fmt.Errorf("cannot join %s of type %s on %s of type %s", left.Name, left.Type, right.Name, right.Type)
+	}
+
+	return joinCondition{leftIndex: leftIndex, rightIndex: rightIndex, primitive: left.Type}, nil
+}
+
+// matches returns whether the rows should be combined.
+func (c joinCondition) matches(left backend.Row, right backend.Row) bool {
+	l, r := joinKey(left, c.leftIndex), joinKey(right, c.rightIndex)
+	if l == nil || r == nil {
+		return false
+	}
+
+	return c.primitive.Compare(l, r) == 0
+}
+
+// joinKey returns the value of the row at the index, or nil if it can not be equal to any value, which is the case
+// for a value that does not exist and for NaN. Negative zero is returned as zero so that keys that are equal are
+// also the same map key.
+func joinKey(row backend.Row, index int) interface{} {
+	val := row.Values[index].Val
+
+	if f, ok := val.(float64); ok {
+		if math.IsNaN(f) {
+			return nil
+		}
+
+		if f == 0 {
+			return float64(0)
+		}
+	}
+
+	return val
+}
+
+// combineRows returns a row with the values of left followed by the values of right.
+func combineRows(left []backend.Value, right []backend.Value) backend.Row {
+	values := make([]backend.Value, 0, len(left)+len(right))
+	values = append(values, left...)
+	values = append(values, right...)
+
+	return backend.Row{Values: values}
+}
+
+// nullValues returns a value that does not exist for each of the columns. They pad the rows of outer joins that
+// have no match.
+func nullValues(columns []backend.Field) []backend.Value {
+	values := make([]backend.Value, len(columns))
+
+	for i, column := range columns {
+		values[i] = backend.Value{Type: column.Type, FieldName: column.Name}
+	}
+
+	return values
+}
+
+// nestedLoopJoinIterator combines every row of its left source with each row of right that satisfies the condition.
+// For LEFT and OUTER joins, left rows without a match are returned with NULLs in place of the right values. For
+// RIGHT and OUTER joins, right rows without a match are returned with NULLs in place of the left values once the
+// left source is exhausted.
+type nestedLoopJoinIterator struct {
+	ctx          context.Context
+	left         rowIterator
+	right        []backend.Row
+	condition    joinCondition
+	location     language.JoinLocation
+	leftColumns  []backend.Field
+	rightColumns []backend.Field
+
+	leftRow      backend.Row
+	hasLeft      bool
+	leftMatched  bool
+	rightPos     int
+	rightMatched []bool
+	leftDone     bool
+
+	row backend.Row
+	err error
+}
+
+func newNestedLoopJoinIterator(ctx context.Context, left rowIterator, right []backend.Row, condition joinCondition, location language.JoinLocation, leftColumns []backend.Field, rightColumns []backend.Field) *nestedLoopJoinIterator {
+	return &nestedLoopJoinIterator{
+		ctx:          ctx,
+		left:         left,
+		right:        right,
+		condition:    condition,
+		location:     location,
+		leftColumns:  leftColumns,
+		rightColumns: rightColumns,
+		rightMatched: make([]bool, len(right)),
+	}
+}
+
+func (n *nestedLoopJoinIterator) keepsLeft() bool {
+	left, _ := keepsUnmatched(n.location)
+
+	return left
+}
+
+func (n *nestedLoopJoinIterator) keepsRight() bool {
+	_, right := keepsUnmatched(n.location)
+
+	return right
+}
+
+func (n *nestedLoopJoinIterator) Next() bool {
+	if n.err != nil {
+		return false
+	}
+
+	for !n.leftDone {
+		if !n.hasLeft {
+			if err := n.ctx.Err(); err != nil {
+				n.err = err
+				return false
+			}
+
+			if !n.left.Next() {
+				n.err = n.left.Err()
+				n.leftDone = true
+				n.rightPos = 0 // the right rows are scanned once more for the ones without a match
+
+				if n.err != nil {
+					return false
+				}
+
+				break
+			}
+
+			n.leftRow = n.left.Row()
+			n.hasLeft = true
+			n.leftMatched = false
+			n.rightPos = 0
+		}
+
+		for n.rightPos < len(n.right) {
+			i := n.rightPos
+			n.rightPos++
+
+			if n.condition.matches(n.leftRow, n.right[i]) {
+				n.leftMatched = true
+				n.rightMatched[i] = true
+				n.row = combineRows(n.leftRow.Values, n.right[i].Values)
+
+				return true
+			}
+		}
+
+		n.hasLeft = false
+
+		if !n.leftMatched && n.keepsLeft() {
+			n.row = combineRows(n.leftRow.Values, nullValues(n.rightColumns))
+			return true
+		}
+	}
+
+	if n.keepsRight() {
+		for n.rightPos < len(n.right) {
+			i := n.rightPos
+			n.rightPos++
+
+			if !n.rightMatched[i] {
+				n.row = combineRows(nullValues(n.leftColumns), n.right[i].Values)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (n *nestedLoopJoinIterator) Row() backend.Row {
+	return n.row
+}
+
+func (n *nestedLoopJoinIterator) Err() error {
+	return n.err
+}
+
+func (n *nestedLoopJoinIterator) Close() error {
+	n.right = nil
+
+	return n.left.Close()
+}
+
+// joinAlgorithm is a way of finding the pairs of rows that satisfy a join condition.
+type joinAlgorithm int
+
+const (
+	joinNestedLoop joinAlgorithm = iota
+	joinHash
+	joinSortMerge
+)
+
+// nestedLoopJoinLimit is the number of rows at or below which the right side of a join is small enough that comparing
+// every pair of rows is cheaper than building a hash table or sorting. A left side that is as small is the build side
+// of a hash join instead, so that the larger right side is still only read once.
+var nestedLoopJoinLimit int64 = 32
+
+// hashJoinMemoryLimit is the approximate number of bytes the rows of the smaller side of a hash join can take up, as
+// only that side is held in memory while the other one is streamed. Joins with larger inputs are sorted and merged
+// instead, as sorts can spill to disk.
+var hashJoinMemoryLimit int64 = 64 * 1024 * 1024
+
+// joinInput is the estimated size of a side of a join.
+type joinInput struct {
+	rows    int64
+	rowSize int64
+	sorted  bool // whether the rows are ordered by the join field, as they are by an earlier inner merge join on it
+}
+
+// estimatedStringSize is the number of bytes a string without a maximum length is assumed to take up when estimating
+// the size of rows before reading them.
+const estimatedStringSize = 256
+
+// estimateColumnsSize approximates the number of bytes of memory a row with the columns takes up, assuming that every
+// string is as long as it can be, or estimatedStringSize if its length is not limited.
+func estimateColumnsSize(columns []backend.Field) int64 {
+	var size int64 = 24
+
+	for _, column := range columns {
+		size += 64
+
+		if column.Type == backend.PrimitiveString {
+			if column.MaxLength > 0 {
+				size += column.MaxLength * utf8.UTFMax
+			} else {
+				size += estimatedStringSize
+			}
+		}
+	}
+
+	return size
+}
+
+// planJoin chooses how to join the left and right inputs. Comparing every pair of rows is cheapest when the right side
+// is tiny. Otherwise, a left side that is already ordered by the join field only needs the right side to be sorted
+// before merging. A hash table is built on the smaller side if it fits in memory, and anything larger is sorted and
+// merged.
+func planJoin(left joinInput, right joinInput) joinAlgorithm {
+	if right.rows <= nestedLoopJoinLimit {
+		return joinNestedLoop
+	}
+
+	if left.sorted {
+		return joinSortMerge
+	}
+
+	smaller := left
+	if right.rows < left.rows {
+		smaller = right
+	}
+
+	if smaller.rows*smaller.rowSize <= hashJoinMemoryLimit {
+		return joinHash
+	}
+
+	return joinSortMerge
+}
+
+// newJoinIterator joins the rows of the right source to the rows of the left source with the algorithm. Only the rows
+// of the side that a nested loop or hash join compares every row of the other side with are read into memory, while
+// the other side, and both sides of a merge join, are streamed. Both sources are closed if it returns an error.
+func newJoinIterator(ctx context.Context, store *backend.Store, algorithm joinAlgorithm, left joinInput, right joinInput, leftSource rowIterator, rightSource rowIterator, condition joinCondition, location language.JoinLocation, leftColumns []backend.Field, rightColumns []backend.Field) (rowIterator, error) {
+	switch algorithm {
+	case joinHash:
+		if left.rows < right.rows {
+			leftRows, err := collectRows(leftSource)
+			if err != nil {
+				rightSource.Close()
+				return nil, err
+			}
+
+			return newHashJoinIterator(ctx, rightSource, leftRows, true, condition, location, leftColumns, rightColumns), nil
+		}
+
+		rightRows, err := collectRows(rightSource)
+		if err != nil {
+			leftSource.Close()
+			return nil, err
+		}
+
+		return newHashJoinIterator(ctx, leftSource, rightRows, false, condition, location, leftColumns, rightColumns), nil
+	case joinSortMerge:
+		if !left.sorted {
+			leftSource = newSortIterator(ctx, store, leftSource, []sortKey{{index: condition.leftIndex, primitive: condition.primitive}})
+		}
+
+		rightSource = newSortIterator(ctx, store, rightSource, []sortKey{{index: condition.rightIndex, primitive: condition.primitive}})
+
+		return newMergeJoinIterator(ctx, leftSource, rightSource, condition, location, leftColumns, rightColumns), nil
+	}
+
+	rightRows, err := collectRows(rightSource)
+	if err != nil {
+		leftSource.Close()
+		return nil, err
+	}
+
+	return newNestedLoopJoinIterator(ctx, leftSource, rightRows, condition, location, leftColumns, rightColumns), nil
+}
+
+// keepsUnmatched returns whether rows of the left and right sides without a match are returned by a join.
+func keepsUnmatched(location language.JoinLocation) (left bool, right bool) {
+	left = location == language.JoinLocationLeft || location == language.JoinLocationOuter
+	right = location == language.JoinLocationRight || location == language.JoinLocationOuter
+
+	return left, right
+}
+
+// hashJoinIterator builds a hash table of the join field of the build rows and probes it with each row of the probe
+// source. buildIsLeft is whether the build rows are the left side of the join, the combined rows always have the
+// left values first. Build rows without a match are returned once the probe source is exhausted if the join keeps
+// them.
+type hashJoinIterator struct {
+	ctx          context.Context
+	probe        rowIterator
+	build        []backend.Row
+	buildIsLeft  bool
+	condition    joinCondition
+	leftColumns  []backend.Field
+	rightColumns []backend.Field
+	keepsProbe   bool
+	keepsBuild   bool
+
+	table        map[interface{}][]int
+	buildMatched []bool
+	pending      []backend.Row
+	probeDone    bool
+	unmatchedPos int
+
+	row backend.Row
+	err error
+}
+
+func newHashJoinIterator(ctx context.Context, probe rowIterator, build []backend.Row, buildIsLeft bool, condition joinCondition, location language.JoinLocation, leftColumns []backend.Field, rightColumns []backend.Field) *hashJoinIterator {
+	h := &hashJoinIterator{
+		ctx:          ctx,
+		probe:        probe,
+		build:        build,
+		buildIsLeft:  buildIsLeft,
+		condition:    condition,
+		leftColumns:  leftColumns,
+		rightColumns: rightColumns,
+	}
+
+	keepsLeft, keepsRight := keepsUnmatched(location)
+	h.keepsProbe, h.keepsBuild = keepsRight, keepsLeft
+	if !buildIsLeft {
+		h.keepsProbe, h.keepsBuild = keepsLeft, keepsRight
+	}
+
+	return h
+}
+
+// indexes returns the positions of the join field in the probe and build rows.
+func (h *hashJoinIterator) indexes() (probe int, build int) {
+	if h.buildIsLeft {
+		return h.condition.rightIndex, h.condition.leftIndex
+	}
+
+	return h.condition.leftIndex, h.condition.rightIndex
+}
+
+// combine returns the joined row of a probe row and a build row, either of which can be nil values.
+func (h *hashJoinIterator) combine(probe []backend.Value, build []backend.Value) backend.Row {
+	if h.buildIsLeft {
+		return combineRows(build, probe)
+	}
+
+	return combineRows(probe, build)
+}
+
+// nulls returns the padding for a side without a match.
+func (h *hashJoinIterator) nulls(build bool) []backend.Value {
+	if build == h.buildIsLeft {
+		return nullValues(h.leftColumns)
+	}
+
+	return nullValues(h.rightColumns)
+}
+
+func (h *hashJoinIterator) Next() bool {
+	if h.err != nil {
+		return false
+	}
+
+	probeIndex, buildIndex := h.indexes()
+
+	if h.table == nil {
+		h.table = make(map[interface{}][]int, len(h.build))
+		h.buildMatched = make([]bool, len(h.build))
+
+		for i, row := range h.build {
+			key := joinKey(row, buildIndex)
+			if key != nil {
+				h.table[key] = append(h.table[key], i)
+			}
+		}
+	}
+
+	for len(h.pending) == 0 && !h.probeDone {
+		if err := h.ctx.Err(); err != nil {
+			h.err = err
+			return false
+		}
+
+		if !h.probe.Next() {
+			h.err = h.probe.Err()
+			h.probeDone = true
+
+			if h.err != nil {
+				return false
+			}
+
+			break
+		}
+
+		probeRow := h.probe.Row()
+
+		var matches []int
+		if key := joinKey(probeRow, probeIndex); key != nil {
+			matches = h.table[key]
+		}
+
+		for _, i := range matches {
+			h.buildMatched[i] = true
+			h.pending = append(h.pending, h.combine(probeRow.Values, h.build[i].Values))
+		}
+
+		if len(matches) == 0 && h.keepsProbe {
+			h.pending = append(h.pending, h.combine(probeRow.Values, h.nulls(true)))
+		}
+	}
+
+	if len(h.pending) != 0 {
+		h.row = h.pending[0]
+		h.pending = h.pending[1:]
+
+		return true
+	}
+
+	if h.keepsBuild {
+		for h.unmatchedPos < len(h.build) {
+			i := h.unmatchedPos
+			h.unmatchedPos++
+
+			if !h.buildMatched[i] {
+				h.row = h.combine(h.nulls(false), h.build[i].Values)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (h *hashJoinIterator) Row() backend.Row {
+	return h.row
+}
+
+func (h *hashJoinIterator) Err() error {
+	return h.err
+}
+
+func (h *hashJoinIterator) Close() error {
+	h.build = nil
+	h.table = nil
+
+	return h.probe.Close()
+}
+
+// mergeJoinIterator joins two sources that are both ordered by their join field. The right rows that share a join
+// field value are buffered as a group, which is combined with every left row of that value. Rows of either side
+// without a match are returned as they are passed if the join keeps them.
+type mergeJoinIterator struct {
+	ctx          context.Context
+	left         rowIterator
+	right        rowIterator
+	condition    joinCondition
+	leftColumns  []backend.Field
+	rightColumns []backend.Field
+	keepsLeft    bool
+	keepsRight   bool
+
+	started           bool
+	leftRow, rightRow backend.Row
+	hasLeft, hasRight bool
+	group             []backend.Row
+	groupKey          interface{}
+	groupMatched      bool
+	pending           []backend.Row
+
+	row backend.Row
+	err error
+}
+
+func newMergeJoinIterator(ctx context.Context, left rowIterator, right rowIterator, condition joinCondition, location language.JoinLocation, leftColumns []backend.Field, rightColumns []backend.Field) *mergeJoinIterator {
+	m := &mergeJoinIterator{
+		ctx:          ctx,
+		left:         left,
+		right:        right,
+		condition:    condition,
+		leftColumns:  leftColumns,
+		rightColumns: rightColumns,
+	}
+
+	m.keepsLeft, m.keepsRight = keepsUnmatched(location)
+
+	return m
+}
+
+func (m *mergeJoinIterator) advanceLeft() {
+	m.hasLeft = m.left.Next()
+	if !m.hasLeft {
+		m.err = m.left.Err()
+		return
+	}
+
+	m.leftRow = m.left.Row()
+}
+
+func (m *mergeJoinIterator) advanceRight() {
+	m.hasRight = m.right.Next()
+	if !m.hasRight {
+		m.err = m.right.Err()
+		return
+	}
+
+	m.rightRow = m.right.Row()
+}
+
+// flushGroup ends the current group of right rows, which are returned with NULLs if no left row matched them.
+func (m *mergeJoinIterator) flushGroup() {
+	if !m.groupMatched && m.keepsRight {
+		for _, row := range m.group {
+			m.pending = append(m.pending, combineRows(nullValues(m.leftColumns), row.Values))
+		}
+	}
+
+	m.group = nil
+	m.groupKey = nil
+}
+
+// step moves the join forward by at least one row of either side, adding the rows it produces to pending. It returns
+// false once both sides are exhausted.
+func (m *mergeJoinIterator) step() bool {
+	if !m.hasLeft {
+		if len(m.group) != 0 {
+			m.flushGroup()
+			return true
+		}
+
+		if !m.hasRight {
+			return false
+		}
+
+		if m.keepsRight {
+			m.pending = append(m.pending, combineRows(nullValues(m.leftColumns), m.rightRow.Values))
+		}
+		m.advanceRight()
+
+		return true
+	}
+
+	leftKey := joinKey(m.leftRow, m.condition.leftIndex)
+
+	if len(m.group) != 0 {
+		if leftKey != nil && m.condition.primitive.Compare(leftKey, m.groupKey) == 0 {
+			for _, row := range m.group {
+				m.pending = append(m.pending, combineRows(m.leftRow.Values, row.Values))
+			}
+			m.groupMatched = true
+			m.advanceLeft()
+
+			return true
+		}
+
+		m.flushGroup()
+	}
+
+	if leftKey == nil || !m.hasRight {
+		if m.keepsLeft {
+			m.pending = append(m.pending, combineRows(m.leftRow.Values, nullValues(m.rightColumns)))
+		}
+		m.advanceLeft()
+
+		return true
+	}
+
+	// keys that can not match anything are ordered last, so every remaining left row is before them
+	rightKey := joinKey(m.rightRow, m.condition.rightIndex)
+
+	c := -1
+	if rightKey != nil {
+		c = m.condition.primitive.Compare(leftKey, rightKey)
+	}
+
+	switch {
+	case c < 0:
+		if m.keepsLeft {
+			m.pending = append(m.pending, combineRows(m.leftRow.Values, nullValues(m.rightColumns)))
+		}
+		m.advanceLeft()
+	case c > 0:
+		if m.keepsRight {
+			m.pending = append(m.pending, combineRows(nullValues(m.leftColumns), m.rightRow.Values))
+		}
+		m.advanceRight()
+	default:
+		m.groupKey = rightKey
+		m.groupMatched = false
+
+		for m.hasRight {
+			key := joinKey(m.rightRow, m.condition.rightIndex)
+			if key == nil || m.condition.primitive.Compare(key, m.groupKey) != 0 {
+				break
+			}
+
+			m.group = append(m.group, m.rightRow)
+			m.advanceRight()
+		}
+	}
+
+	return true
+}
+
+func (m *mergeJoinIterator) Next() bool {
+	if !m.started {
+		m.started = true
+		m.advanceLeft()
+
+		if m.err == nil {
+			m.advanceRight()
+		}
+	}
+
+	for len(m.pending) == 0 {
+		if m.err != nil {
+			return false
+		}
+
+		if err := m.ctx.Err(); err != nil {
+			m.err = err
+			return false
+		}
+
+		if !m.step() {
+			return false
+		}
+	}
+
+	if m.err != nil {
+		return false
+	}
+
+	m.row = m.pending[0]
+	m.pending = m.pending[1:]
+
+	return true
+}
+
+func (m *mergeJoinIterator) Row() backend.Row {
+	return m.row
+}
+
+func (m *mergeJoinIterator) Err() error {
+	return m.err
+}
+
+func (m *mergeJoinIterator) Close() error {
+	err := m.left.Close()
+
+	rErr := m.right.Close()
+	if err == nil {
+		err = rErr
+	}
+
+	return err
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// withJoinLimits sets nestedLoopJoinLimit and hashJoinMemoryLimit for the test, so that joins of a few rows use the
+// algorithm that larger ones would.
+func withJoinLimits(t *testing.T, nestedLoop int64, hashMemory int64) {
+	oldNestedLoop, oldHashMemory := nestedLoopJoinLimit, hashJoinMemoryLimit
+	nestedLoopJoinLimit, hashJoinMemoryLimit = nestedLoop, hashMemory
+
+	t.Cleanup(func() {
+		nestedLoopJoinLimit, hashJoinMemoryLimit = oldNestedLoop, oldHashMemory
+	})
+}
+
+func TestPlanJoin(t *testing.T) {
+	tiny, small, large := joinInput{rows: 10, rowSize: 100}, joinInput{rows: 1000, rowSize: 100}, joinInput{rows: 1 << 40, rowSize: 100}
+
+	for _, c := range []struct {
+		left, right joinInput
+		want        joinAlgorithm
+	}{
+		{small, tiny, joinNestedLoop},
+		{tiny, large, joinHash}, // the tiny left side is the build side, so the right side is streamed
+		{small, small, joinHash},
+		{large, large, joinSortMerge},
+		{joinInput{rows: 1000, rowSize: 100, sorted: true}, small, joinSortMerge},
+	} {
+		if got := planJoin(c.left, c.right); got != c.want {
+			t.Errorf("planJoin(%v, %v) = %d, want %d", c.left, c.right, got, c.want)
+		}
+	}
+}
+
+// TestJoinAlgorithms runs joins of every kind with each algorithm, and checks that they all return the same rows.
+func TestJoinAlgorithms(t *testing.T) {
+	withSortMemoryLimit(t, 4096)
+
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE a (k int, x int)")
+	process(t, s, "CREATE TABLE b (k int, y string)")
+	process(t, s, "CREATE TABLE c (k int, z int)")
+
+	for i := 0; i < 150; i++ {
+		process(t, s, fmt.Sprintf("INSERT INTO a VALUES (%d, %d)", i*7%40, i))
+		process(t, s, fmt.Sprintf("INSERT INTO b VALUES (%d, 'y%d')", i*11%50, i))
+		process(t, s, fmt.Sprintf("INSERT INTO c VALUES (%d, %d)", i*13%30, i))
+	}
+
+	process(t, s, "INSERT INTO a VALUES (NULL, -1)")
+	process(t, s, "INSERT INTO b VALUES (NULL, 'null')")
+
+	var statements []string
+	for _, location := range []string{"", "LEFT", "RIGHT", "FULL OUTER"} {
+		statements = append(statements,
+			fmt.Sprintf("SELECT * FROM a %s JOIN b ON a.k = b.k", location),
+			fmt.Sprintf("SELECT * FROM a JOIN b ON a.k = b.k %s JOIN c ON b.k = c.k", location),
+		)
+	}
+
+	// every pair of rows is compared, which is what the other algorithms must agree with
+	withJoinLimits(t, 1<<40, 1<<40)
+
+	want := make(map[string]string, len(statements))
+	for _, statement := range statements {
+		want[statement] = sortedRows(process(t, s, statement))
+	}
+
+	for _, c := range []struct {
+		name       string
+		nestedLoop int64
+		hashMemory int64
+	}{
+		{"hash", 0, 1 << 40},
+		{"sort-merge", 0, 0},
+	} {
+		withJoinLimits(t, c.nestedLoop, c.hashMemory)
+
+		for _, statement := range statements {
+			if got := sortedRows(process(t, s, statement)); got != want[statement] {
+				t.Errorf("%s with a %s join selected %.80s, want %.80s", statement, c.name, got, want[statement])
+			}
+		}
+	}
+}
+
+// sortedRows returns the rows of the result, in sorted order, as a string.
+func sortedRows(result *ResultSet) string {
+	rows := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = fmt.Sprint(row)
+	}
+
+	sort.Strings(rows)
+
+	return fmt.Sprintf("%d rows: %v", len(rows), rows)
+}
@@ -2,29 +2,15 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"math"
+	"strings"
 
 	"github.com/Dojo456/simple-sql-db/backend"
 	"github.com/Dojo456/simple-sql-db/engine/language"
 )
 
-func (e *SQLEngine) getTable(ctx context.Context, name string) (backend.OperableTable, error) {
-	var err error
-
-	table, open := e.openTables[name]
-	if !open {
-		table, err = backend.OpenTable(ctx, name)
-		if err != nil {
-			return nil, err
-		}
-
-		e.openTables[name] = table
-	}
-
-	return table, nil
-}
-
 func (e *SQLEngine) insertRow(ctx context.Context, args *language.InsertArgs) (int, error) {
 	table, err := e.getTable(ctx, args.TableName)
 	if err != nil {
@@ -49,11 +35,15 @@ func (e *SQLEngine) insertRow(ctx context.Context, args *language.InsertArgs) (i
 		iFields = table.GetFields()
 	}
 
-	values := make([]backend.Value, len(iFields))
+	if len(args.Values) > len(iFields) {
+		return 0, fmt.Errorf("%d values were given, but table %s only has %d fields", len(args.Values), table.GetName(), len(iFields))
+	}
+
+	values := make([]backend.Value, len(args.Values))
 	for i, uVal := range args.Values {
 		field := iFields[i]
 
-		val, err := language.NewValueForField(field, uVal.Val)
+		val, err := uVal.ValueForField(field)
 		if err != nil {
 			return 0, fmt.Errorf("error with %s.%s: %w", table.GetName(), field.Name, err)
 		}
@@ -69,101 +59,282 @@ func (e *SQLEngine) insertRow(ctx context.Context, args *language.InsertArgs) (i
 	return count, nil
 }
 
-func (e *SQLEngine) selectRows(ctx context.Context, args *language.SelectArgs) ([][]string, error) {
-	// load all tables needed
-	tables := map[string]backend.OperableTable{}
+// selectRows returns the columns and the rows selected by the statement.
+func (e *SQLEngine) selectRows(ctx context.Context, args *language.SelectArgs) ([]Column, [][]interface{}, error) {
+	columns, it, err := e.selectIterator(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for _, tableField := range args.TableFields {
-		t, err := e.getTable(ctx, tableField.TableName)
-		if err != nil {
-			return nil, err
+	rows, err := collectRows(it)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	returner := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		returner[i] = rowValues(row)
+	}
+
+	return columns, returner, nil
+}
+
+// rowValues returns the values of the row, with nil for NULL.
+func rowValues(row backend.Row) []interface{} {
+	values := make([]interface{}, len(row.Values))
+	for i, cell := range row.Values {
+		values[i] = cell.Val
+	}
+
+	return values
+}
+
+// rowsBeforeLimit returns the number of rows that the statement reads before its LIMIT is reached, which is the OFFSET
+// and the LIMIT together, and false if that is more than an int holds, in which case every row is read.
+func rowsBeforeLimit(args *language.SelectArgs) (int, bool) {
+	if args.Limit > math.MaxInt-args.Offset {
+		return 0, false
+	}
+
+	return args.Offset + args.Limit, true
+}
+
+// selectIterator returns the columns selected by the statement and an iterator over the selected rows, which are only
+// read from the tables as they are iterated over, unless they have to be sorted, grouped or joined first.
+func (e *SQLEngine) selectIterator(ctx context.Context, args *language.SelectArgs) ([]Column, rowIterator, error) {
+	// without joins, an ORDER BY or aggregates, the table can stop scanning once enough rows for the LIMIT have matched
+	scanLimit := backend.NoLimit
+	if args.HasLimit && len(args.Joins) == 0 && len(args.OrderBy) == 0 && !args.HasAggregates() {
+		if n, ok := rowsBeforeLimit(args); ok {
+			scanLimit = n
 		}
+	}
 
-		tables[tableField.TableName] = t
+	it, columns, err := e.joinRows(ctx, args, scanLimit)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// first query JOINS
-	var joinFilters []backend.Filter
+	return e.planSelect(ctx, it, columns, args)
+}
+
+// selectColumns returns the columns selected by the statement without reading any rows.
+func (e *SQLEngine) selectColumns(ctx context.Context, args *language.SelectArgs) ([]Column, error) {
+	tableNames := []string{args.TableName}
 	for _, join := range args.Joins {
-		t := tables[join.TableName]
+		tableNames = append(tableNames, join.TableName)
+	}
+
+	var columns []backend.Field
 
-		field, err := t.FieldWithName(join.ChildField)
+	for _, name := range tableNames {
+		t, err := e.getTable(ctx, name)
 		if err != nil {
 			return nil, err
 		}
 
-		fieldsToSelect := args.TableFields[join.TableName].FieldNames
+		columns = append(columns, qualifiedFields(t)...)
+	}
 
-		if !contains(fieldsToSelect, join.ChildField) {
-			fieldsToSelect = append(fieldsToSelect, join.ChildField)
-		}
+	resultColumns, it, err := e.planSelect(ctx, newSliceIterator(nil), columns, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return resultColumns, it.Close()
+}
+
+// planSelect groups, sorts, limits and projects the joined rows of the statement, whose columns are columns. It
+// returns the columns that are selected and an iterator over the selected rows.
+func (e *SQLEngine) planSelect(ctx context.Context, it rowIterator, columns []backend.Field, args *language.SelectArgs) ([]Column, rowIterator, error) {
+	var err error
 
-		rows, err := selectRows(ctx, t, fieldsToSelect, join.Filter, nil)
+	// the values to return, fields that are only ordered by are removed after sorting
+	var projection []int
+
+	if args.HasAggregates() {
+		aggregated, aggregateColumns, err := aggregateRows(ctx, it, columns, args)
 		if err != nil {
-			return nil, err
+			it.Close()
+			return nil, nil, err
 		}
 
-		var vals []interface{}
-		for _, row := range rows {
-			for _, val := range row.Values {
-				if val.FieldName == join.ChildField {
-					vals = append(vals, val.Val)
-				}
+		it, columns = aggregated, aggregateColumns
+	} else if !args.AllFields {
+		projection = make([]int, len(args.Expressions))
+
+		for i, expression := range args.Expressions {
+			projection[i], err = fieldList(columns).indexOf(expression.ColumnName())
+			if err != nil {
+				it.Close()
+				return nil, nil, err
 			}
 		}
+	}
 
-		joinFilters = append(joinFilters, backend.Filter{
-			Vals:            vals,
-			Operator:        backend.OperatorEqual,
-			FieldName:       join.ParentField,
-			RangeComparison: true,
-			Value: backend.Value{
-				Type: field.Type,
-			},
-		})
+	if len(args.OrderBy) != 0 {
+		keys, err := sortKeysForOrderBy(columns, args.OrderBy)
+		if err != nil {
+			it.Close()
+			return nil, nil, err
+		}
+
+		if n, ok := rowsBeforeLimit(args); ok && args.HasLimit {
+			it = newTopNIterator(ctx, it, keys, n)
+		} else {
+			it = newSortIterator(ctx, e.store, it, keys)
+		}
+	}
+
+	if args.HasLimit || args.Offset != 0 {
+		limit := backend.NoLimit
+		if args.HasLimit {
+			limit = args.Limit
+		}
+
+		it = newLimitIterator(it, limit, args.Offset)
 	}
 
-	t := tables[args.TableName]
+	// a column is named like the expression that selects it, while the columns of SELECT * are only qualified with
+	// their table if more than one table is selected. Grouped fields keep their table until then, so that ORDER BY
+	// can refer to them either way
+	resultColumns := make([]Column, len(columns))
+	for i, column := range columns {
+		resultColumns[i] = Column{Name: column.Name, Type: column.Type}
 
-	var fieldsToSelect []string
+		if args.AllFields && len(args.Joins) == 0 {
+			resultColumns[i].Name = strings.TrimPrefix(column.Name, args.TableName+".")
+		}
 
-	if args.AllFields {
-		fieldsToSelect = nil
-	} else {
-		fieldsToSelect = args.TableFields[args.TableName].FieldNames
+		if args.HasAggregates() {
+			resultColumns[i].Name = args.Expressions[i].Name()
+		}
 	}
 
-	rows, err := selectRows(ctx, t, fieldsToSelect, args.Filter, joinFilters)
-	if err != nil {
-		return nil, err
+	if projection != nil {
+		it = newProjectIterator(it, projection)
+
+		projected := make([]Column, len(projection))
+		for i, index := range projection {
+			projected[i] = Column{Name: args.Expressions[i].Name(), Type: columns[index].Type}
+		}
+
+		resultColumns = projected
 	}
 
-	returner := make([][]string, len(rows))
+	return resultColumns, it, nil
+}
 
-	for i, valRow := range rows {
-		values := valRow.Values
+// joinRows reads the rows of the table being selected from and joins the rows of every joined table to them, in the
+// order of the JOIN clauses. The parts of the WHERE clause that only compare the fields of a single table are applied
+// while scanning that table, unless an outer join can pad its rows with NULLs. The rest of the clause is applied to
+// the joined rows. It returns the joined rows and their columns, which are the fields of every table in order.
+func (e *SQLEngine) joinRows(ctx context.Context, args *language.SelectArgs, scanLimit int) (rowIterator, []backend.Field, error) {
+	tableNames := []string{args.TableName}
+	for _, join := range args.Joins {
+		tableNames = append(tableNames, join.TableName)
+	}
 
-		row := make([]string, len(values))
+	tables := make(map[string]backend.OperableTable, len(tableNames))
+	var allColumns fieldList
 
-		for j, cell := range values {
-			var cellString string
+	for _, name := range tableNames {
+		t, err := e.getTable(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tables[name] = t
+		allColumns = append(allColumns, qualifiedFields(t)...)
+	}
+
+	// tables whose rows are padded with NULLs when there is no match can not be filtered before joining
+	nullable := map[string]bool{}
+	for i, join := range args.Joins {
+		if join.Location == language.JoinLocationLeft || join.Location == language.JoinLocationOuter {
+			nullable[join.TableName] = true
+		}
 
-			switch v := cell.Val.(type) {
-			case string:
-				cellString = fmt.Sprintf(`"%s"`, v)
-			case int64:
-				cellString = fmt.Sprintf("%d", v)
-			case float64:
-				cellString = strconv.FormatFloat(v, 'f', -1, 64)
+		if join.Location == language.JoinLocationRight || join.Location == language.JoinLocationOuter {
+			for _, name := range tableNames[:i+1] {
+				nullable[name] = true
 			}
+		}
+	}
+
+	pushedDown := map[string][]language.WhereClause{}
+	var remaining []language.WhereClause
+
+	for _, clause := range conjuncts(args.Filter) {
+		clauseTableNames, err := clauseTables(&clause, allColumns, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(clauseTableNames) == 1 && !nullable[clauseTableNames[0]] {
+			pushedDown[clauseTableNames[0]] = append(pushedDown[clauseTableNames[0]], clause)
+			continue
+		}
+
+		remaining = append(remaining, clause)
+	}
+
+	it, columns, err := streamTable(ctx, tables[args.TableName], conjunction(pushedDown[args.TableName]), scanLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the joined rows are estimated to be as many as the larger side of each join
+	left := joinInput{rows: tables[args.TableName].RowCount(), rowSize: estimateColumnsSize(columns)}
+	var sortedBy []int
+
+	for _, join := range args.Joins {
+		rightSource, rightColumns, err := streamTable(ctx, tables[join.TableName], conjunction(pushedDown[join.TableName]), backend.NoLimit)
+		if err != nil {
+			it.Close()
+			return nil, nil, err
+		}
+
+		condition, err := newJoinCondition(join, columns, rightColumns)
+		if err != nil {
+			it.Close()
+			rightSource.Close()
+			return nil, nil, err
+		}
+
+		left.sorted = contains(sortedBy, condition.leftIndex)
+		right := joinInput{rows: tables[join.TableName].RowCount(), rowSize: estimateColumnsSize(rightColumns)}
+		algorithm := planJoin(left, right)
+
+		it, err = newJoinIterator(ctx, e.store, algorithm, left, right, it, rightSource, condition, join.Location, columns, rightColumns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the rows of an inner merge join are ordered by the join fields of both sides
+		sortedBy = nil
+		if algorithm == joinSortMerge && join.Location == language.JoinLocationInner {
+			sortedBy = []int{condition.leftIndex, len(columns) + condition.rightIndex}
+		}
+
+		if right.rows > left.rows {
+			left.rows = right.rows
+		}
+		left.rowSize += right.rowSize
+		columns = append(columns[:len(columns):len(columns)], rightColumns...)
+	}
 
-			row[j] = cellString
+	if len(remaining) != 0 {
+		filter, err := filterFromWhereClause(conjunction(remaining), fieldList(columns))
+		if err != nil {
+			it.Close()
+			return nil, nil, err
 		}
 
-		returner[i] = row
+		it = newFilterIterator(it, []backend.Filter{*filter})
 	}
 
-	return returner, nil
+	return it, columns, nil
 }
 
 func (e *SQLEngine) deleteRows(ctx context.Context, args *language.DeleteArgs) (int, error) {
@@ -172,7 +343,7 @@ func (e *SQLEngine) deleteRows(ctx context.Context, args *language.DeleteArgs) (
 		return 0, err
 	}
 
-	filter, err := filterFromWhereClause(args.Filter, t)
+	filter, err := filterFromWhereClause(args.Filter, tableFinder{t})
 	if err != nil {
 		return 0, err
 	}
@@ -206,7 +377,7 @@ func (e *SQLEngine) updateRows(ctx context.Context, args *language.UpdateArgs) (
 			return 0, fmt.Errorf("error with field %s.%s: %w", t.GetName(), uVal.FieldName, err)
 		}
 
-		val, err := language.NewValueForField(field, uVal.Val)
+		val, err := uVal.ValueForField(field)
 		if err != nil {
 			return 0, fmt.Errorf("error with field %s.%s: %w", t.GetName(), uVal.FieldName, err)
 		}
@@ -214,7 +385,7 @@ func (e *SQLEngine) updateRows(ctx context.Context, args *language.UpdateArgs) (
 		vals = append(vals, val)
 	}
 
-	filter, err := filterFromWhereClause(args.Filter, t)
+	filter, err := filterFromWhereClause(args.Filter, tableFinder{t})
 	if err != nil {
 		return 0, err
 	}
@@ -228,15 +399,156 @@ func (e *SQLEngine) updateRows(ctx context.Context, args *language.UpdateArgs) (
 }
 
 func (e *SQLEngine) createTable(ctx context.Context, args *language.CreateTableArgs) (backend.OperableTable, error) {
-	name := args.TableName
-	fields := args.Fields
-
-	table, err := backend.CreateTable(ctx, name, fields)
+	table, err := e.addTable(ctx, args.TableName, args.Fields)
 	if err != nil {
 		return nil, fmt.Errorf("could not create table: %w", err)
 	}
 
-	e.openTables[name] = table
-
 	return table, nil
 }
+
+func (e *SQLEngine) createIndex(ctx context.Context, args *language.CreateIndexArgs) (backend.Index, error) {
+	t, err := e.getTable(ctx, args.TableName)
+	if err != nil {
+		return backend.Index{}, err
+	}
+
+	err = t.CreateIndex(ctx, args.Index)
+	if err != nil {
+		return backend.Index{}, fmt.Errorf("could not create index: %w", err)
+	}
+
+	return args.Index, nil
+}
+
+func (e *SQLEngine) dropIndex(ctx context.Context, args *language.DropIndexArgs) error {
+	t, err := e.getTable(ctx, args.TableName)
+	if err != nil {
+		return err
+	}
+
+	err = t.DropIndex(ctx, args.IndexName)
+	if err != nil {
+		return fmt.Errorf("could not drop index: %w", err)
+	}
+
+	return nil
+}
+
+func (e *SQLEngine) vacuum(ctx context.Context, args *language.VacuumArgs) (int, error) {
+	t, err := e.getTable(ctx, args.TableName)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := t.Vacuum(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not vacuum table: %w", err)
+	}
+
+	return n, nil
+}
+
+func (e *SQLEngine) dropTable(ctx context.Context, args *language.DropTableArgs) error {
+	err := e.removeTable(ctx, args.TableName)
+	if err != nil {
+		if args.IfExists && errors.Is(err, backend.ErrTableNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (e *SQLEngine) truncateTable(ctx context.Context, args *language.TruncateTableArgs) error {
+	t, err := e.getTable(ctx, args.TableName)
+	if err != nil {
+		return err
+	}
+
+	err = t.Truncate(ctx)
+	if err != nil {
+		return fmt.Errorf("could not truncate table: %w", err)
+	}
+
+	return nil
+}
+
+// alterTable makes the change of the action of the arguments to the table and returns the table once it is changed,
+// which is under its new name if it was renamed.
+func (e *SQLEngine) alterTable(ctx context.Context, args *language.AlterTableArgs) (backend.OperableTable, error) {
+	switch args.Action {
+	case language.AlterRenameTable:
+		return e.renameTable(ctx, args.TableName, args.NewTableName)
+	case language.AlterAddColumn:
+		var def interface{}
+
+		if args.Default != nil {
+			val, err := args.Default.ValueForField(args.Field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default for %s: %w", args.Field.Name, err)
+			}
+
+			def = val.Val
+		}
+
+		return e.alterTableWith(ctx, args.TableName, func(table backend.OperableTable) error {
+			return table.AddField(ctx, args.Field, def)
+		})
+	case language.AlterDropColumn:
+		return e.alterTableWith(ctx, args.TableName, func(table backend.OperableTable) error {
+			return table.DropField(ctx, args.FieldName)
+		})
+	case language.AlterRenameColumn:
+		return e.alterTableWith(ctx, args.TableName, func(table backend.OperableTable) error {
+			field, err := table.FieldWithName(args.FieldName)
+			if err != nil {
+				return err
+			}
+
+			field.Name = args.NewFieldName
+
+			return table.AlterField(ctx, args.FieldName, field)
+		})
+	case language.AlterColumnType:
+		return e.alterTableWith(ctx, args.TableName, func(table backend.OperableTable) error {
+			return table.AlterField(ctx, args.FieldName, args.Field)
+		})
+	}
+
+	return nil, fmt.Errorf("invalid ALTER TABLE action")
+}
+
+func (e *SQLEngine) createDatabase(ctx context.Context, args *language.CreateDatabaseArgs) error {
+	err := e.store.CreateDatabase(ctx, args.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+
+	return nil
+}
+
+// dropDatabase drops the database of the arguments, which can not be the default database or the database that the
+// statement is run in.
+func (e *SQLEngine) dropDatabase(ctx context.Context, args *language.DropDatabaseArgs) error {
+	if args.DatabaseName == backend.DefaultDatabase {
+		return fmt.Errorf("could not drop database: the %s database can not be dropped", backend.DefaultDatabase)
+	}
+
+	if backend.DatabaseFromContext(ctx) == args.DatabaseName {
+		return fmt.Errorf("could not drop database: %s is the database in use", args.DatabaseName)
+	}
+
+	err := e.removeDatabase(ctx, args.DatabaseName)
+	if err != nil {
+		if args.IfExists && errors.Is(err, backend.ErrDatabaseNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
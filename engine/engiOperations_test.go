@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestLimitAndOffsetDoNotOverflow(t *testing.T) {
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE t (k int)")
+
+	for _, k := range []int{3, 1, 2} {
+		process(t, s, fmt.Sprintf("INSERT INTO t VALUES (%d)", k))
+	}
+
+	for statement, want := range map[string]string{
+		"SELECT k FROM t ORDER BY k LIMIT 9223372036854775807 OFFSET 1":      "[[2] [3]]",
+		"SELECT k FROM t LIMIT 9223372036854775807 OFFSET 2":                 "[[2]]",
+		"SELECT k FROM t ORDER BY k DESC LIMIT 1 OFFSET 9223372036854775807": "[]",
+	} {
+		if got := fmt.Sprint(process(t, s, statement).Rows); got != want {
+			t.Errorf("%s selected %s, want %s", statement, got, want)
+		}
+	}
+}
+
+func TestQuotedNullIsAString(t *testing.T) {
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE t (name string, n int)")
+	process(t, s, `INSERT INTO t VALUES ("NULL", 1)`)
+	process(t, s, "INSERT INTO t VALUES (NULL, 2)")
+	process(t, s, "INSERT INTO t VALUES ('x', 3)")
+	process(t, s, `UPDATE t SET name = 'NULL' WHERE n = 3`)
+
+	for statement, want := range map[string]string{
+		`SELECT n FROM t WHERE name = "NULL" ORDER BY n`: "[[1] [3]]",
+		"SELECT n FROM t WHERE name = 'NULL' ORDER BY n": "[[1] [3]]",
+		"SELECT n FROM t WHERE name IS NULL":             "[[2]]",
+	} {
+		if got := fmt.Sprint(process(t, s, statement).Rows); got != want {
+			t.Errorf("%s selected %s, want %s", statement, got, want)
+		}
+	}
+
+	process(t, s, "UPDATE t SET name = NULL WHERE n = 1")
+
+	if got := count(t, s, "SELECT COUNT(*) FROM t WHERE name IS NULL"); got != "2" {
+		t.Errorf("%s rows are NULL once one was set to NULL, want 2", got)
+	}
+}
+
+func TestAddColumnDefault(t *testing.T) {
+	dir := t.TempDir()
+
+	e, err := New(context.Background(), WithDataDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE animals (name string)")
+	process(t, s, "INSERT INTO animals VALUES ('cat')")
+	process(t, s, "ALTER TABLE animals ADD COLUMN legs int DEFAULT 4")
+	process(t, s, "INSERT INTO animals (name) VALUES ('dog')")
+	process(t, s, "INSERT INTO animals VALUES ('snake', NULL)")
+	process(t, s, "ALTER TABLE animals ADD COLUMN sound string DEFAULT 'a sound that is too long to be inlined'")
+	process(t, s, "ALTER TABLE animals RENAME COLUMN legs TO feet")
+	process(t, s, "INSERT INTO animals (name) VALUES ('bird')")
+
+	want := "[[bird 4 a sound that is too long to be inlined] [cat 4 a sound that is too long to be inlined] " +
+		"[dog 4 a sound that is too long to be inlined] [snake <nil> a sound that is too long to be inlined]]"
+
+	if got := fmt.Sprint(process(t, s, "SELECT name, feet, sound FROM animals ORDER BY name").Rows); got != want {
+		t.Fatalf("selected %s, want %s", got, want)
+	}
+
+	err = e.Cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the defaults are kept once the table is opened again, converted if the type of the field is changed
+	e = newTestEngine(t, WithDataDir(dir))
+	s = e.NewSession()
+
+	process(t, s, "ALTER TABLE animals ALTER COLUMN feet TYPE float")
+	process(t, s, "INSERT INTO animals (name) VALUES ('fish')")
+
+	if got := fmt.Sprint(process(t, s, "SELECT feet, sound FROM animals WHERE name = 'fish'").Rows); got != "[[4 a sound that is too long to be inlined]]" {
+		t.Fatalf("selected %s for the row inserted after the table was opened again", got)
+	}
+}
+
+func TestDoubledQuotes(t *testing.T) {
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE t (name string)")
+	process(t, s, "INSERT INTO t VALUES ('it''s')")
+	process(t, s, `UPDATE t SET name = "it's ""quoted""" WHERE name = 'it''s'`)
+
+	if got := count(t, s, `SELECT name FROM t WHERE name = 'it''s "quoted"'`); got != `it's "quoted"` {
+		t.Errorf("selected %s", got)
+	}
+}
+
+func TestGroupedColumnNames(t *testing.T) {
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE people (name string, age int)")
+	process(t, s, "CREATE TABLE pets (owner string, kind string)")
+	process(t, s, "INSERT INTO people VALUES ('a', 30)")
+	process(t, s, "INSERT INTO pets VALUES ('a', 'cat')")
+
+	for statement, want := range map[string]string{
+		"SELECT age, COUNT(*) FROM people GROUP BY age ORDER BY people.age":                                         "[age count(*)]",
+		"SELECT people.age, MAX(name) FROM people GROUP BY age HAVING people.age > 1":                               "[age max(name)]",
+		"SELECT pets.kind, COUNT(people.name) FROM people JOIN pets ON people.name = pets.owner GROUP BY pets.kind": "[kind count(name)]",
+	} {
+		result := process(t, s, statement)
+
+		var names []string
+		for _, column := range result.Columns {
+			names = append(names, column.Name)
+		}
+
+		if got := fmt.Sprint(names); got != want {
+			t.Errorf("%s selected the columns %s, want %s", statement, got, want)
+		}
+
+		if len(result.Rows) != 1 {
+			t.Errorf("%s selected %v", statement, result.Rows)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// ResultSet is the result of executing a statement, whose command is Command.
+//
+// A SELECT statement has Columns, and a row in Rows for every row it selected with a value for each column, in the
+// same order. A value is nil if it is NULL, otherwise it is a string, int64, float64 or bool for a column of type
+// backend.PrimitiveString, backend.PrimitiveInt, backend.PrimitiveFloat or backend.PrimitiveBool.
+//
+// RowsAffected is the number of rows an INSERT, UPDATE or DELETE statement changed, or the number of row versions
+// that VACUUM reclaimed. Table is the table that CREATE TABLE created or ALTER TABLE altered, and Index the index that
+// CREATE INDEX created. Formatting a result as text is left to the caller.
+type ResultSet struct {
+	Command      language.Command
+	Columns      []Column
+	Rows         [][]interface{}
+	RowsAffected int
+	Table        *TableSchema
+	Index        *backend.Index
+}
+
+// Column is a column of the rows of a ResultSet.
+type Column struct {
+	Name string
+	Type backend.Primitive
+}
+
+// TableSchema describes a table.
+type TableSchema struct {
+	Name   string
+	Fields []backend.Field
+}
+
+// schemaOf returns the schema of the table.
+func schemaOf(t backend.OperableTable) *TableSchema {
+	return &TableSchema{Name: t.GetName(), Fields: t.GetFields()}
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// Session runs statements one after the other, like a connection to the database. Once BEGIN starts a transaction,
+// every statement of the session runs in it until it is committed or rolled back, otherwise each statement is
+// committed on its own.
+//
+// Tables are created and opened in the database of the session, which is the database of the engine until USE
+// changes it.
+//
+// Once a statement of a transaction fails, the transaction fails with it, since the statement can have made some of its
+// changes before it failed. Every statement after it returns ErrTransactionFailed until the transaction is rolled back,
+// or rolled back to a savepoint from before the statement, and committing it rolls it back instead.
+//
+// A session can be used by more than one goroutine. Statements that are committed on their own run concurrently,
+// while mu is held for every statement of a transaction, and while a transaction begins or ends, so that they run one
+// at a time.
+type Session struct {
+	engine   *SQLEngine
+	mu       sync.Mutex
+	tx       *backend.Transaction
+	failed   bool
+	database string
+}
+
+// ErrTransactionFailed is returned for the statements of a transaction after one of its statements failed, until it is
+// rolled back.
+var ErrTransactionFailed = errors.New("transaction failed")
+
+// NewSession returns a new session of the engine. It should be closed once it is no longer used.
+func (e *SQLEngine) NewSession() *Session {
+	return &Session{engine: e, database: e.database}
+}
+
+// Process parses then executes the given statement string.
+func (s *Session) Process(ctx context.Context, statement string) (result *ResultSet, err error) {
+	defer recoverStatement(&err)
+
+	// syntax validation
+	err = language.Validate(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, args, err := language.Parse(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	// semantic validation
+	val, err := s.Execute(ctx, *cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return val, err
+}
+
+// recoverStatement recovers from a panic of the statement that is being run, which is returned as err instead. It
+// must be deferred.
+func recoverStatement(err *error) {
+	if r := recover(); r != nil {
+		log.Println("\nEngine panic recovered", r)
+		debug.PrintStack()
+
+		*err = fmt.Errorf("could not execute statement: %v", r)
+	}
+}
+
+// Execute runs the given command with given args.
+func (s *Session) Execute(ctx context.Context, cmd language.Command, args interface{}) (*ResultSet, error) {
+	s.mu.Lock()
+
+	ctx = backend.ContextWithDatabase(ctx, s.database)
+
+	if s.tx == nil && !isSessionCommand(cmd) {
+		s.mu.Unlock()
+		return s.executeStatement(ctx, cmd, args)
+	}
+
+	defer s.mu.Unlock()
+
+	if s.failed && cmd != language.CommitCommand && cmd != language.RollbackCommand {
+		return nil, fmt.Errorf("%w, statements are ignored until it is rolled back", ErrTransactionFailed)
+	}
+
+	var err error
+
+	switch cmd {
+	case language.BeginCommand:
+		err = s.begin(args.(*language.BeginArgs))
+	case language.CommitCommand:
+		err = s.commit()
+	case language.RollbackCommand:
+		err = s.rollback(args.(*language.RollbackArgs))
+	case language.SavepointCommand:
+		err = s.savepoint(args.(*language.SavepointArgs))
+	case language.ReleaseSavepointCommand:
+		err = s.releaseSavepoint(args.(*language.SavepointArgs))
+	case language.UseCommand:
+		err = s.use(args.(*language.UseArgs))
+	default:
+		// the transaction stays failed if the statement panics
+		s.failed = true
+
+		result, err := s.executeStatement(backend.ContextWithTransaction(ctx, s.tx), cmd, args)
+		s.failed = err != nil
+
+		return result, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResultSet{Command: cmd}, nil
+}
+
+// isSessionCommand returns whether the command changes the session instead of the tables, which are the transaction
+// commands and USE.
+func isSessionCommand(cmd language.Command) bool {
+	switch cmd {
+	case language.BeginCommand, language.CommitCommand, language.RollbackCommand, language.SavepointCommand, language.ReleaseSavepointCommand,
+		language.UseCommand:
+		return true
+	}
+
+	return false
+}
+
+// executeStatement runs a command that is not a transaction command, in the transaction of the context if it has one.
+func (s *Session) executeStatement(ctx context.Context, cmd language.Command, args interface{}) (*ResultSet, error) {
+	// every table the statement reads sees the same snapshot
+	ctx, release, err := s.engine.store.BeginStatement(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	for {
+		result, err := s.engine.execute(ctx, cmd, args)
+
+		// a statement that started using a table before it was altered runs again with the table as it is now
+		if !errors.Is(err, backend.ErrTableAltered) {
+			return result, err
+		}
+	}
+}
+
+// InTransaction returns whether the session has a transaction that has not been committed or rolled back yet.
+func (s *Session) InTransaction() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tx != nil
+}
+
+// InFailedTransaction returns whether the session has a transaction that failed, and that has to be rolled back.
+func (s *Session) InFailedTransaction() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.failed
+}
+
+// Close rolls back the transaction of the session, if it has one.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tx == nil {
+		return nil
+	}
+
+	tx := s.tx
+	s.tx, s.failed = nil, false
+
+	return tx.Rollback()
+}
+
+func (s *Session) begin(args *language.BeginArgs) error {
+	if s.tx != nil {
+		return fmt.Errorf("a transaction has already begun")
+	}
+
+	tx, err := s.engine.store.BeginTransaction(args.Isolation)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+
+	s.tx = tx
+
+	return nil
+}
+
+func (s *Session) commit() error {
+	if s.tx == nil {
+		return fmt.Errorf("there is no transaction to commit")
+	}
+
+	if s.failed {
+		err := s.rollback(&language.RollbackArgs{})
+		if err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%w, it was rolled back instead of committed", ErrTransactionFailed)
+	}
+
+	// the transaction is kept if it could not be committed, so it can still be rolled back
+	err := s.tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	s.tx = nil
+
+	return nil
+}
+
+func (s *Session) rollback(args *language.RollbackArgs) error {
+	if s.tx == nil {
+		return fmt.Errorf("there is no transaction to roll back")
+	}
+
+	if args.SavepointName != "" {
+		err := s.tx.RollbackTo(args.SavepointName)
+		if err != nil {
+			return err
+		}
+
+		// the changes of the statement that failed are undone along with the rest since the savepoint
+		s.failed = false
+
+		return nil
+	}
+
+	// the transaction has ended even if its changes could not be undone
+	tx := s.tx
+	s.tx, s.failed = nil, false
+
+	err := tx.Rollback()
+	if err != nil {
+		return fmt.Errorf("could not roll back transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Session) savepoint(args *language.SavepointArgs) error {
+	if s.tx == nil {
+		return fmt.Errorf("savepoints can only be created inside a transaction")
+	}
+
+	return s.tx.Savepoint(args.SavepointName)
+}
+
+func (s *Session) releaseSavepoint(args *language.SavepointArgs) error {
+	if s.tx == nil {
+		return fmt.Errorf("savepoints can only be released inside a transaction")
+	}
+
+	return s.tx.ReleaseSavepoint(args.SavepointName)
+}
+
+// Database returns the database that the session creates and opens tables in.
+func (s *Session) Database() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.database
+}
+
+// use makes the database of the arguments the database of the session. A transaction can use the tables of more than
+// one database, so the database can be changed inside of one.
+func (s *Session) use(args *language.UseArgs) error {
+	exists, err := s.engine.store.DatabaseExists(args.DatabaseName)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("%w: %s", backend.ErrDatabaseNotExist, args.DatabaseName)
+	}
+
+	s.database = args.DatabaseName
+
+	return nil
+}
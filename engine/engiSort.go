@@ -0,0 +1,454 @@
+package engine
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// sortMemoryLimit is the approximate number of bytes of rows a sort will hold in memory. Once exceeded, the rows
+// are sorted and spilled to a temporary file as a run, and the runs are merged once all rows have been read.
+var sortMemoryLimit int64 = 64 * 1024 * 1024
+
+// sortKey is a single value of a row to order by.
+type sortKey struct {
+	index      int
+	primitive  backend.Primitive
+	descending bool
+}
+
+// compareRows returns -1, 0 or 1 depending on whether r1 should be ordered before, with or after r2.
+func compareRows(r1 backend.Row, r2 backend.Row, keys []sortKey) int {
+	for _, key := range keys {
+		c := key.primitive.Compare(r1.Values[key.index].Val, r2.Values[key.index].Val)
+
+		if key.descending {
+			c = -c
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// estimateRowSize approximates the number of bytes of memory a row takes up.
+func estimateRowSize(row backend.Row) int64 {
+	var size int64 = 24
+
+	for _, val := range row.Values {
+		size += 64
+
+		if s, ok := val.Val.(string); ok {
+			size += int64(len(s))
+		}
+	}
+
+	return size
+}
+
+// sortIterator returns the rows of its source ordered by the sort keys. The sort is stable. Rows are sorted in
+// memory if they fit within sortMemoryLimit, otherwise an external merge sort is performed with the sorted runs kept
+// in temporary files in the data directory of the store.
+type sortIterator struct {
+	ctx    context.Context
+	store  *backend.Store
+	source rowIterator
+	keys   []sortKey
+
+	sorted bool
+	memory *sliceIterator
+	runs   []*sortRun
+	merge  mergeHeap
+
+	row backend.Row
+	err error
+}
+
+func newSortIterator(ctx context.Context, store *backend.Store, source rowIterator, keys []sortKey) *sortIterator {
+	return &sortIterator{ctx: ctx, store: store, source: source, keys: keys}
+}
+
+func (s *sortIterator) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	if !s.sorted {
+		s.sorted = true
+
+		err := s.sort()
+		if err != nil {
+			s.err = err
+			return false
+		}
+	}
+
+	if s.memory != nil {
+		if !s.memory.Next() {
+			return false
+		}
+
+		s.row = s.memory.Row()
+
+		return true
+	}
+
+	if s.merge.Len() == 0 {
+		return false
+	}
+
+	run := s.merge.runs[0]
+	s.row = run.row
+
+	more, err := run.advance()
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	if more {
+		heap.Fix(&s.merge, 0)
+	} else {
+		heap.Pop(&s.merge)
+	}
+
+	return true
+}
+
+func (s *sortIterator) Row() backend.Row {
+	return s.row
+}
+
+func (s *sortIterator) Err() error {
+	return s.err
+}
+
+func (s *sortIterator) Close() error {
+	err := s.source.Close()
+
+	for _, run := range s.runs {
+		rErr := run.remove()
+		if err == nil {
+			err = rErr
+		}
+	}
+	s.runs = nil
+
+	return err
+}
+
+// sort reads every row of the source, spilling sorted runs to disk whenever the memory limit is exceeded, and
+// prepares either the in memory rows or the merge of the runs for iteration.
+func (s *sortIterator) sort() error {
+	var buffer []backend.Row
+	var bufferSize int64
+
+	for s.source.Next() {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		row := s.source.Row()
+
+		buffer = append(buffer, row)
+		bufferSize += estimateRowSize(row)
+
+		if bufferSize >= sortMemoryLimit {
+			err := s.spill(buffer)
+			if err != nil {
+				return err
+			}
+
+			buffer = nil
+			bufferSize = 0
+		}
+	}
+
+	if err := s.source.Err(); err != nil {
+		return err
+	}
+
+	if len(s.runs) == 0 { // everything fit in memory
+		s.sortRows(buffer)
+		s.memory = newSliceIterator(buffer)
+
+		return nil
+	}
+
+	if len(buffer) != 0 {
+		err := s.spill(buffer)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.merge = mergeHeap{keys: s.keys}
+	for _, run := range s.runs {
+		more, err := run.advance()
+		if err != nil {
+			return err
+		}
+
+		if more {
+			s.merge.runs = append(s.merge.runs, run)
+		}
+	}
+	heap.Init(&s.merge)
+
+	return nil
+}
+
+func (s *sortIterator) sortRows(rows []backend.Row) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareRows(rows[i], rows[j], s.keys) < 0
+	})
+}
+
+// spill sorts the rows and writes them to a new run file.
+func (s *sortIterator) spill(rows []backend.Row) error {
+	s.sortRows(rows)
+
+	file, err := s.store.CreateTempFile("sort-*")
+	if err != nil {
+		return fmt.Errorf("could not create sort run file: %w", err)
+	}
+
+	run := &sortRun{file: file, order: len(s.runs)}
+	s.runs = append(s.runs, run)
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	for _, row := range rows {
+		err := encoder.Encode(row.Values)
+		if err != nil {
+			return fmt.Errorf("could not write sort run: %w", err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("could not write sort run: %w", err)
+	}
+
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return fmt.Errorf("could not rewind sort run: %w", err)
+	}
+
+	run.decoder = gob.NewDecoder(bufio.NewReader(file))
+
+	return nil
+}
+
+// sortRun is a file of sorted rows. row is the row at the head of the run.
+type sortRun struct {
+	file    *os.File
+	decoder *gob.Decoder
+	order   int
+	row     backend.Row
+}
+
+// advance reads the next row of the run into row. It returns false once the run is exhausted.
+func (r *sortRun) advance() (bool, error) {
+	var values []backend.Value
+
+	err := r.decoder.Decode(&values)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("could not read sort run: %w", err)
+	}
+
+	r.row = backend.Row{Values: values}
+
+	return true, nil
+}
+
+func (r *sortRun) remove() error {
+	err := r.file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(r.file.Name())
+}
+
+// mergeHeap is a min heap of runs, ordered by the row at the head of each run. Ties are broken by the order the runs
+// were created in to keep the merge stable.
+type mergeHeap struct {
+	runs []*sortRun
+	keys []sortKey
+}
+
+func (h mergeHeap) Len() int {
+	return len(h.runs)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	c := compareRows(h.runs[i].row, h.runs[j].row, h.keys)
+	if c == 0 {
+		return h.runs[i].order < h.runs[j].order
+	}
+
+	return c < 0
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h.runs[i], h.runs[j] = h.runs[j], h.runs[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.runs = append(h.runs, x.(*sortRun))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+
+	return last
+}
+
+// topNIterator returns the first n rows of its source as ordered by the sort keys. Instead of sorting every row, it
+// keeps a bounded heap of the n best rows seen so far, so memory use is proportional to n rather than the input.
+type topNIterator struct {
+	ctx    context.Context
+	source rowIterator
+	n      int
+	best   topNHeap
+
+	sorted bool
+	memory *sliceIterator
+	err    error
+}
+
+func newTopNIterator(ctx context.Context, source rowIterator, keys []sortKey, n int) *topNIterator {
+	return &topNIterator{ctx: ctx, source: source, n: n, best: topNHeap{keys: keys}}
+}
+
+func (t *topNIterator) Next() bool {
+	if t.err != nil {
+		return false
+	}
+
+	if !t.sorted {
+		t.sorted = true
+
+		rows, err := t.selectTop()
+		if err != nil {
+			t.err = err
+			return false
+		}
+
+		t.memory = newSliceIterator(rows)
+	}
+
+	return t.memory.Next()
+}
+
+func (t *topNIterator) Row() backend.Row {
+	return t.memory.Row()
+}
+
+func (t *topNIterator) Err() error {
+	return t.err
+}
+
+func (t *topNIterator) Close() error {
+	return t.source.Close()
+}
+
+// selectTop reads every row of the source and returns the best n in order.
+func (t *topNIterator) selectTop() ([]backend.Row, error) {
+	if t.n == 0 {
+		return nil, nil
+	}
+
+	seq := 0
+
+	for t.source.Next() {
+		if err := t.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		candidate := topNRow{row: t.source.Row(), seq: seq}
+		seq++
+
+		if t.best.Len() < t.n {
+			heap.Push(&t.best, candidate)
+		} else if t.best.before(candidate, t.best.rows[0]) { // candidate beats the worst of the best rows
+			t.best.rows[0] = candidate
+			heap.Fix(&t.best, 0)
+		}
+	}
+
+	if err := t.source.Err(); err != nil {
+		return nil, err
+	}
+
+	// popping the heap yields the worst row first
+	rows := make([]backend.Row, t.best.Len())
+	for i := len(rows) - 1; i >= 0; i-- {
+		rows[i] = heap.Pop(&t.best).(topNRow).row
+	}
+
+	return rows, nil
+}
+
+// topNRow is a row along with its position in the input, which is used to keep the order stable.
+type topNRow struct {
+	row backend.Row
+	seq int
+}
+
+// topNHeap is a max heap, the row that would be ordered last is at the top so it can be replaced first.
+type topNHeap struct {
+	rows []topNRow
+	keys []sortKey
+}
+
+// before returns whether r1 is ordered before r2.
+func (h topNHeap) before(r1 topNRow, r2 topNRow) bool {
+	c := compareRows(r1.row, r2.row, h.keys)
+	if c == 0 {
+		return r1.seq < r2.seq
+	}
+
+	return c < 0
+}
+
+func (h topNHeap) Len() int {
+	return len(h.rows)
+}
+
+func (h topNHeap) Less(i, j int) bool {
+	return h.before(h.rows[j], h.rows[i])
+}
+
+func (h topNHeap) Swap(i, j int) {
+	h.rows[i], h.rows[j] = h.rows[j], h.rows[i]
+}
+
+func (h *topNHeap) Push(x interface{}) {
+	h.rows = append(h.rows, x.(topNRow))
+}
+
+func (h *topNHeap) Pop() interface{} {
+	last := h.rows[len(h.rows)-1]
+	h.rows = h.rows[:len(h.rows)-1]
+
+	return last
+}
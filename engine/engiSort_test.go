@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// withSortMemoryLimit sets sortMemoryLimit for the test, so that sorts of a few rows spill to disk.
+func withSortMemoryLimit(t *testing.T, limit int64) {
+	old := sortMemoryLimit
+	sortMemoryLimit = limit
+
+	t.Cleanup(func() {
+		sortMemoryLimit = old
+	})
+}
+
+func TestExternalSort(t *testing.T) {
+	withSortMemoryLimit(t, 4096)
+
+	e := newTestEngine(t)
+
+	const count = 2000
+
+	rows := make([]backend.Row, count)
+	for i := range rows {
+		rows[i] = backend.Row{Values: []backend.Value{
+			{Type: backend.PrimitiveString, Val: fmt.Sprintf("key%02d", i*7919%50), FieldName: "k"},
+			{Type: backend.PrimitiveInt, Val: int64(i), FieldName: "i"},
+		}}
+	}
+
+	it := newSortIterator(context.Background(), e.store, newSliceIterator(rows), []sortKey{{index: 0, primitive: backend.PrimitiveString, descending: true}})
+
+	var sorted []backend.Row
+
+	for it.Next() {
+		sorted = append(sorted, it.Row())
+	}
+
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+
+	if len(it.runs) < 2 {
+		t.Fatalf("the rows were sorted in %d runs, want them spilled to more than one", len(it.runs))
+	}
+
+	if len(sorted) != count {
+		t.Fatalf("sorted %d rows, want %d", len(sorted), count)
+	}
+
+	for i := 1; i < len(sorted); i++ {
+		k0, k1 := sorted[i-1].Values[0].Val.(string), sorted[i].Values[0].Val.(string)
+		i0, i1 := sorted[i-1].Values[1].Val.(int64), sorted[i].Values[1].Val.(int64)
+
+		// the sort is stable, so rows with the same key keep the order they were read in
+		if k0 < k1 || (k0 == k1 && i0 > i1) {
+			t.Fatalf("row %d (%s, %d) is ordered after (%s, %d)", i, k1, i1, k0, i0)
+		}
+	}
+
+	err := it.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(e.store.Dir(), "sort-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Fatalf("the run files %v were not removed", files)
+	}
+}
+
+func TestOrderBySpills(t *testing.T) {
+	withSortMemoryLimit(t, 4096)
+
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE t (a int, b string)")
+
+	for i := 0; i < 300; i++ {
+		process(t, s, fmt.Sprintf("INSERT INTO t VALUES (%d, 'b%d')", i%7, i%11))
+	}
+
+	result := process(t, s, "SELECT a, b FROM t ORDER BY a DESC, b")
+	if len(result.Rows) != 300 {
+		t.Fatalf("selected %d rows, want 300", len(result.Rows))
+	}
+
+	for i := 1; i < len(result.Rows); i++ {
+		a0, a1 := result.Rows[i-1][0].(int64), result.Rows[i][0].(int64)
+		b0, b1 := result.Rows[i-1][1].(string), result.Rows[i][1].(string)
+
+		if a0 < a1 || (a0 == a1 && b0 > b1) {
+			t.Fatalf("row %d (%d, %s) is ordered after (%d, %s)", i, a1, b1, a0, b0)
+		}
+	}
+}
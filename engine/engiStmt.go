@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// Stmt is a statement that is parsed once by Prepare and can then be run any number of times in the session that
+// prepared it. Its values can be parameters, which are either numbered $1, $2 and so on or are each a ?, and whose
+// arguments are given every time it is run. The arguments are validated against the types of their fields, and
+// strings are taken as they are, so they never need to be quoted or escaped. NumParams is the number of arguments it
+// takes.
+type Stmt struct {
+	NumParams int
+
+	session *Session
+	cmd     language.Command
+	args    interface{}
+}
+
+// Prepare parses the given statement so it can be run in the default session of the engine.
+func (e *SQLEngine) Prepare(ctx context.Context, statement string) (*Stmt, error) {
+	return e.session.Prepare(ctx, statement)
+}
+
+// Prepare parses the given statement so it can be run in the session.
+func (s *Session) Prepare(ctx context.Context, statement string) (*Stmt, error) {
+	cmd, args, err := language.Parse(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{NumParams: language.NumParams(args), session: s, cmd: *cmd, args: args}, nil
+}
+
+// Command returns the command of the statement.
+func (st *Stmt) Command() language.Command {
+	return st.cmd
+}
+
+// Exec runs the statement with the arguments bound to its parameters, the first argument being $1.
+func (st *Stmt) Exec(ctx context.Context, params ...interface{}) (result *ResultSet, err error) {
+	defer recoverStatement(&err)
+
+	args, err := language.Bind(st.args, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.session.Execute(ctx, st.cmd, args)
+}
+
+// Query is like Exec, except that the statement must be a SELECT statement, and a cursor over the rows it selects is
+// returned, see Session.Query.
+func (st *Stmt) Query(ctx context.Context, params ...interface{}) (*Cursor, error) {
+	if st.cmd != language.SelectCommand {
+		return nil, fmt.Errorf("only SELECT statements can be queried for rows")
+	}
+
+	args, err := language.Bind(st.args, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.session.QueryArgs(ctx, args.(*language.SelectArgs))
+}
+
+// Columns returns the columns of the rows that the statement selects, without running it, or no columns if it is not
+// a SELECT statement.
+func (st *Stmt) Columns(ctx context.Context) ([]Column, error) {
+	if st.cmd != language.SelectCommand {
+		return nil, nil
+	}
+
+	// the values do not change the columns, so every parameter is NULL
+	args, err := language.Bind(st.args, make([]interface{}, st.NumParams))
+	if err != nil {
+		return nil, err
+	}
+
+	return st.session.engine.selectColumns(ctx, args.(*language.SelectArgs))
+}
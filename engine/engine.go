@@ -3,83 +3,182 @@ package engine
 import (
 	"context"
 	"fmt"
-	"log"
-	"runtime/debug"
+	"sync"
 
 	"github.com/Dojo456/simple-sql-db/backend"
 	"github.com/Dojo456/simple-sql-db/engine/language"
 )
 
+// SQLEngine executes statements against the tables of the databases of its data directory, which it has open as a
+// backend.Store. It is safe for concurrent use by multiple goroutines.
+//
+// mu only guards the catalog of open tables and is never held while a statement runs, so statements never wait for
+// each other in the engine. Statements that write to the same table take turns in the backend, while statements that
+// read a table never wait for the ones writing to it, see backend.BeginStatement.
 type SQLEngine struct {
-	openTables map[string]backend.OperableTable
+	store      *backend.Store
+	mu         sync.Mutex
+	openTables map[tableKey]*openTable
+	dropping   map[string]bool
+	database   string
+	session    *Session
 }
 
 type Cleanable interface {
 	Cleanup() error
 }
 
-// New returns a new engine instance that can then be used to execute SQL statements.
-func New(ctx context.Context) (*SQLEngine, error) {
-	return &SQLEngine{
-		map[string]backend.OperableTable{},
-	}, nil
+// Option configures an engine that is returned by New.
+type Option func(*options)
+
+type options struct {
+	dataDir        string
+	database       string
+	bufferPoolSize int64
 }
 
-// Process parses then executes the given statement string. Returned values are strings that are formatted.
-func (e *SQLEngine) Process(ctx context.Context, statement string) (interface{}, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println("\nEngine panic recovered", r)
-			debug.PrintStack()
-		}
-	}()
+// WithDataDir sets the directory that the files of the databases are stored in, which is the database directory of the
+// working directory otherwise. Only one engine of a process can use a directory at a time, see backend.OpenStore.
+func WithDataDir(dir string) Option {
+	return func(o *options) {
+		o.dataDir = dir
+	}
+}
 
-	// syntax validation
-	err := language.Validate(statement)
-	if err != nil {
-		return nil, err
+// WithDatabase sets the database that sessions use until USE changes it, which is backend.DefaultDatabase otherwise.
+// The database must exist.
+func WithDatabase(name string) Option {
+	return func(o *options) {
+		o.database = name
+	}
+}
+
+// WithBufferPoolSize sets the number of bytes of pages of the tables of the engine that are kept in memory, which is
+// 32MB otherwise.
+func WithBufferPoolSize(size int64) Option {
+	return func(o *options) {
+		o.bufferPoolSize = size
+	}
+}
+
+// New returns a new engine instance that can then be used to execute SQL statements. Any statements that were
+// committed but not yet written to the tables when the database last stopped are recovered first.
+func New(ctx context.Context, opts ...Option) (*SQLEngine, error) {
+	o := options{database: backend.DefaultDatabase}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	cmd, args, err := language.Parse(statement)
+	store, err := backend.OpenStore(o.dataDir, o.bufferPoolSize)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+
+	exists, err := store.DatabaseExists(o.database)
+	if err == nil && !exists {
+		err = fmt.Errorf("%w: %s", backend.ErrDatabaseNotExist, o.database)
 	}
 
-	// semantic validation
-	val, err := e.Execute(ctx, *cmd, args)
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
 
-	return val, err
+	e := &SQLEngine{
+		store:      store,
+		openTables: map[tableKey]*openTable{},
+		dropping:   map[string]bool{},
+		database:   o.database,
+	}
+
+	e.session = e.NewSession()
+
+	return e, nil
+}
+
+// Process parses then executes the given statement string in the default session of the engine.
+func (e *SQLEngine) Process(ctx context.Context, statement string) (*ResultSet, error) {
+	return e.session.Process(ctx, statement)
 }
 
-// Execute runs the given command with given args. It will return a value if the executed statement requires
-// one. Else, the return value is nil.
-func (e *SQLEngine) Execute(ctx context.Context, cmd language.Command, args interface{}) (interface{}, error) {
+// Execute runs the given command with given args in the default session of the engine.
+func (e *SQLEngine) Execute(ctx context.Context, cmd language.Command, args interface{}) (*ResultSet, error) {
+	return e.session.Execute(ctx, cmd, args)
+}
+
+// execute runs a command that is not a transaction command. The context has the transaction of the session, if it
+// has one.
+func (e *SQLEngine) execute(ctx context.Context, cmd language.Command, args interface{}) (*ResultSet, error) {
+	result := &ResultSet{Command: cmd}
+
+	var err error
+
 	switch cmd {
 	case language.CreateTableCommand:
-		return e.createTable(ctx, args.(*language.CreateTableArgs))
+		var table backend.OperableTable
+
+		table, err = e.createTable(ctx, args.(*language.CreateTableArgs))
+		if err == nil {
+			result.Table = schemaOf(table)
+		}
 	case language.SelectCommand:
-		return e.selectRows(ctx, args.(*language.SelectArgs))
+		result.Columns, result.Rows, err = e.selectRows(ctx, args.(*language.SelectArgs))
 	case language.InsertCommand:
-		return e.insertRow(ctx, args.(*language.InsertArgs))
+		result.RowsAffected, err = e.insertRow(ctx, args.(*language.InsertArgs))
 	case language.DeleteCommand:
-		return e.deleteRows(ctx, args.(*language.DeleteArgs))
+		result.RowsAffected, err = e.deleteRows(ctx, args.(*language.DeleteArgs))
 	case language.UpdateCommand:
-		return e.updateRows(ctx, args.(*language.UpdateArgs))
+		result.RowsAffected, err = e.updateRows(ctx, args.(*language.UpdateArgs))
+	case language.CreateIndexCommand:
+		var index backend.Index
+
+		index, err = e.createIndex(ctx, args.(*language.CreateIndexArgs))
+		if err == nil {
+			result.Index = &index
+		}
+	case language.DropIndexCommand:
+		err = e.dropIndex(ctx, args.(*language.DropIndexArgs))
+	case language.VacuumCommand:
+		result.RowsAffected, err = e.vacuum(ctx, args.(*language.VacuumArgs))
+	case language.DropTableCommand:
+		err = e.dropTable(ctx, args.(*language.DropTableArgs))
+	case language.TruncateTableCommand:
+		err = e.truncateTable(ctx, args.(*language.TruncateTableArgs))
+	case language.AlterTableCommand:
+		var table backend.OperableTable
+
+		table, err = e.alterTable(ctx, args.(*language.AlterTableArgs))
+		if err == nil {
+			result.Table = schemaOf(table)
+		}
+	case language.CreateDatabaseCommand:
+		err = e.createDatabase(ctx, args.(*language.CreateDatabaseArgs))
+	case language.DropDatabaseCommand:
+		err = e.dropDatabase(ctx, args.(*language.DropDatabaseArgs))
+	default:
+		err = fmt.Errorf("invalid command")
 	}
 
-	return nil, fmt.Errorf("invalid command")
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
+// Cleanup rolls back the transaction of the default session, if it has one, closes every table and closes the data
+// directory, which checkpoints its write-ahead log. Every other session must be closed, and every statement must have
+// returned, before it is called.
 func (e *SQLEngine) Cleanup() error {
-	for _, table := range e.openTables {
-		err := table.Cleanup()
-		if err != nil {
-			return err
-		}
+	err := e.session.Close()
+	if err != nil {
+		return err
+	}
+
+	err = e.closeTables()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return e.store.Close()
 }
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// newTestEngine returns an engine of a new data directory, which is cleaned up once the test is done.
+func newTestEngine(t *testing.T, opts ...Option) *SQLEngine {
+	t.Helper()
+
+	e, err := New(context.Background(), append([]Option{WithDataDir(t.TempDir())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := e.Cleanup()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return e
+}
+
+// process runs the statement in the session and returns its result, failing the test if it returns an error. It can
+// be called from any goroutine.
+func process(t *testing.T, s *Session, statement string) *ResultSet {
+	result, err := s.Process(context.Background(), statement)
+	if err != nil {
+		t.Errorf("%s: %s", statement, err)
+		return &ResultSet{}
+	}
+
+	return result
+}
+
+// count returns the single value that the statement selects as a string.
+func count(t *testing.T, s *Session, statement string) string {
+	result := process(t, s, statement)
+	if len(result.Rows) != 1 || len(result.Rows[0]) != 1 {
+		t.Errorf("%s: selected %v instead of a single value", statement, result.Rows)
+		return ""
+	}
+
+	return fmt.Sprint(result.Rows[0][0])
+}
+
+// TestConcurrentSessions runs statements and transactions of many sessions at once, and one session from more than
+// one goroutine, and checks that every change that was committed is in the tables once they are done. Run it with
+// the race detector.
+func TestConcurrentSessions(t *testing.T) {
+	const (
+		workers = 8
+		rows    = 30
+	)
+
+	e := newTestEngine(t)
+	s := e.NewSession()
+
+	process(t, s, "CREATE TABLE items (id int, n int)")
+	process(t, s, "CREATE UNIQUE INDEX items_id ON items (id)")
+	process(t, s, "CREATE TABLE events (worker int, i int)")
+	process(t, s, "CREATE TABLE shared (worker int)")
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		w := w
+
+		// each worker changes its own rows of items with statements that are committed on their own
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s := e.NewSession()
+			defer s.Close()
+
+			for i := 0; i < rows; i++ {
+				id := w*rows + i
+
+				process(t, s, fmt.Sprintf("INSERT INTO items VALUES (%d, %d)", id, i))
+				process(t, s, fmt.Sprintf("UPDATE items SET n = %d WHERE id = %d", -i, id))
+
+				if i%3 == 0 {
+					process(t, s, fmt.Sprintf("DELETE FROM items WHERE id = %d", id))
+				}
+
+				count(t, s, "SELECT COUNT(*) FROM items")
+			}
+		}()
+
+		// and writes to events in transactions, every other one of which is rolled back
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s := e.NewSession()
+			defer s.Close()
+
+			for i := 0; i < rows; i++ {
+				process(t, s, "BEGIN")
+				process(t, s, fmt.Sprintf("INSERT INTO events VALUES (%d, %d)", w, i))
+				process(t, s, fmt.Sprintf("INSERT INTO events VALUES (%d, %d)", w, -i))
+
+				// the transaction sees its own rows, but no rows of transactions that have not committed
+				if got, want := count(t, s, fmt.Sprintf("SELECT COUNT(*) FROM events WHERE worker = %d", w)), fmt.Sprint((i+1)/2*2+2); got != want {
+					t.Errorf("worker %d counted %s of its events in its transaction, want %s", w, got, want)
+				}
+
+				if i%2 == 0 {
+					process(t, s, "COMMIT")
+				} else {
+					process(t, s, "ROLLBACK")
+				}
+			}
+		}()
+
+		// and creates, uses and drops a table of its own
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s := e.NewSession()
+			defer s.Close()
+
+			name := fmt.Sprintf("scratch%d", w)
+
+			for i := 0; i < 3; i++ {
+				process(t, s, fmt.Sprintf("CREATE TABLE %s (a int)", name))
+				process(t, s, fmt.Sprintf("INSERT INTO %s VALUES (%d)", name, i))
+				count(t, s, fmt.Sprintf("SELECT a FROM %s", name))
+				process(t, s, fmt.Sprintf("DROP TABLE %s", name))
+			}
+		}()
+
+		// while every worker also shares a session
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < rows; i++ {
+				process(t, s, fmt.Sprintf("INSERT INTO shared VALUES (%d)", w))
+				process(t, s, "SELECT worker FROM shared")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if t.Failed() {
+		return
+	}
+
+	// a third of the rows of each worker were deleted
+	if got, want := count(t, s, "SELECT COUNT(*) FROM items"), fmt.Sprint(workers*rows*2/3); got != want {
+		t.Errorf("items has %s rows, want %s", got, want)
+	}
+
+	if got := count(t, s, "SELECT COUNT(*) FROM items WHERE n > 0"); got != "0" {
+		t.Errorf("%s rows of items were not updated", got)
+	}
+
+	if got, want := count(t, s, "SELECT COUNT(*) FROM events"), fmt.Sprint(workers*rows); got != want {
+		t.Errorf("events has %s rows, want the %s of the transactions that were committed", got, want)
+	}
+
+	if got, want := count(t, s, "SELECT COUNT(*) FROM shared"), fmt.Sprint(workers*rows); got != want {
+		t.Errorf("shared has %s rows, want %s", got, want)
+	}
+
+	if got := count(t, s, "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'scratch0'"); got != "0" {
+		t.Errorf("the dropped table is still in the catalog %s times", got)
+	}
+}
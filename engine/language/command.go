@@ -16,8 +16,53 @@ const (
 	InsertCommand
 	DeleteCommand
 	UpdateCommand
+	CreateIndexCommand
+	DropIndexCommand
+	BeginCommand
+	CommitCommand
+	RollbackCommand
+	SavepointCommand
+	ReleaseSavepointCommand
+	VacuumCommand
+	DropTableCommand
+	TruncateTableCommand
+	AlterTableCommand
+	CreateDatabaseCommand
+	DropDatabaseCommand
+	UseCommand
 )
 
+var commandNames = map[Command]string{
+	CreateTableCommand:      "CREATE TABLE",
+	SelectCommand:           "SELECT",
+	InsertCommand:           "INSERT",
+	DeleteCommand:           "DELETE",
+	UpdateCommand:           "UPDATE",
+	CreateIndexCommand:      "CREATE INDEX",
+	DropIndexCommand:        "DROP INDEX",
+	BeginCommand:            "BEGIN",
+	CommitCommand:           "COMMIT",
+	RollbackCommand:         "ROLLBACK",
+	SavepointCommand:        "SAVEPOINT",
+	ReleaseSavepointCommand: "RELEASE SAVEPOINT",
+	VacuumCommand:           "VACUUM",
+	DropTableCommand:        "DROP TABLE",
+	TruncateTableCommand:    "TRUNCATE TABLE",
+	AlterTableCommand:       "ALTER TABLE",
+	CreateDatabaseCommand:   "CREATE DATABASE",
+	DropDatabaseCommand:     "DROP DATABASE",
+	UseCommand:              "USE",
+}
+
+// String returns the keywords of the command, such as "CREATE TABLE".
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Command(%d)", int(c))
+}
+
 func getCommand(keywords []keyword) (*Command, error) {
 	if len(keywords) == 0 {
 		return nil, nil
@@ -36,10 +81,38 @@ func getCommand(keywords []keyword) (*Command, error) {
 				case KeywordTable:
 					returner = CreateTableCommand
 					found = true
+				case KeywordIndex:
+					returner = CreateIndexCommand
+					found = true
+				case KeywordDatabase:
+					returner = CreateDatabaseCommand
+					found = true
+				case KeywordUnique:
+					if len(keywords) > 2 && keywords[2] == KeywordIndex {
+						returner = CreateIndexCommand
+						found = true
+					}
 				}
 			}
 		}
-	case KeywordSelect:
+	case KeywordDrop:
+		{
+			if len(keywords) > 1 {
+				second := keywords[1]
+				switch second {
+				case KeywordIndex:
+					returner = DropIndexCommand
+					found = true
+				case KeywordTable:
+					returner = DropTableCommand
+					found = true
+				case KeywordDatabase:
+					returner = DropDatabaseCommand
+					found = true
+				}
+			}
+		}
+	case KeywordSelect, KeywordShow, KeywordDescribe:
 		returner = SelectCommand
 		found = true
 	case KeywordInsert:
@@ -67,6 +140,41 @@ func getCommand(keywords []keyword) (*Command, error) {
 	case KeywordUpdate:
 		returner = UpdateCommand
 		found = true
+	case KeywordBegin:
+		returner = BeginCommand
+		found = true
+	case KeywordCommit:
+		returner = CommitCommand
+		found = true
+	case KeywordRollback:
+		returner = RollbackCommand
+		found = true
+	case KeywordSavepoint:
+		returner = SavepointCommand
+		found = true
+	case KeywordRelease:
+		returner = ReleaseSavepointCommand
+		found = true
+	case KeywordVacuum:
+		returner = VacuumCommand
+		found = true
+	case KeywordUse:
+		returner = UseCommand
+		found = true
+	case KeywordTruncate:
+		returner = TruncateTableCommand
+		found = true
+	case KeywordAlter:
+		{
+			if len(keywords) > 1 {
+				second := keywords[1]
+				switch second {
+				case KeywordTable:
+					returner = AlterTableCommand
+					found = true
+				}
+			}
+		}
 	}
 
 	if !found {
@@ -76,10 +184,18 @@ func getCommand(keywords []keyword) (*Command, error) {
 	return &returner, nil
 }
 
-// UntypedValue is an unparsed value that has the potential to be parsed into a backend.Value.
+// UntypedValue is an unparsed value that has the potential to be parsed into a backend.Value. Param is the number of
+// the parameter that the value is a placeholder for, or 0 if Val is the literal of the value. The argument of the
+// parameter is set by Bind. Quoted is whether the literal was quoted, in which case Val is the string inside of the
+// quotes, which is never the NULL keyword.
 type UntypedValue struct {
 	Val       string
 	FieldName string
+	Param     int
+	Quoted    bool
+
+	arg   interface{}
+	bound bool
 }
 
 type CreateTableArgs struct {
@@ -92,12 +208,66 @@ type TableFields struct {
 	FieldNames []string
 }
 
+// SelectExpression is a single item of the select list, either a field or an aggregate function of a field. The
+// field of COUNT(*) is "*". TableName is empty if the field was not qualified with one.
+type SelectExpression struct {
+	TableName string
+	FieldName string
+	Aggregate AggregateFunction
+}
+
+// IsAggregate returns whether the expression is an aggregate function.
+func (s SelectExpression) IsAggregate() bool {
+	return s.Aggregate != ""
+}
+
+// Name is how the expression is referred to in the result and other clauses, such as "age" or "count(*)".
+func (s SelectExpression) Name() string {
+	if s.IsAggregate() {
+		return aggregateName(s.Aggregate, s.FieldName)
+	}
+
+	return s.FieldName
+}
+
+// ColumnName is like Name, except that fields qualified with a table are in the format of {tableName}.{fieldName}.
+func (s SelectExpression) ColumnName() string {
+	if !s.IsAggregate() && !isEmptyString(s.TableName) {
+		return s.TableName + "." + s.FieldName
+	}
+
+	return s.Name()
+}
+
 type SelectArgs struct {
 	TableFields map[string]TableFields
 	TableName   string
 	AllFields   bool
 	Filter      *WhereClause
 	Joins       []JoinClause
+	Expressions []SelectExpression
+	GroupBy     []SelectExpression
+	Having      *WhereClause
+	OrderBy     []OrderByClause
+	Limit       int
+	HasLimit    bool
+	Offset      int
+}
+
+// HasAggregates returns whether the statement groups rows, either through a GROUP BY clause or by selecting an
+// aggregate function.
+func (s *SelectArgs) HasAggregates() bool {
+	if len(s.GroupBy) != 0 || s.Having != nil {
+		return true
+	}
+
+	for _, expression := range s.Expressions {
+		if expression.IsAggregate() {
+			return true
+		}
+	}
+
+	return false
 }
 
 type InsertArgs struct {
@@ -117,6 +287,89 @@ type UpdateArgs struct {
 	Filter    *WhereClause
 }
 
+type CreateIndexArgs struct {
+	TableName string
+	Index     backend.Index
+}
+
+type DropIndexArgs struct {
+	TableName string
+	IndexName string
+}
+
+// BeginArgs are the arguments of a BEGIN statement.
+type BeginArgs struct {
+	Isolation backend.IsolationLevel
+}
+
+// RollbackArgs are the arguments of a ROLLBACK statement. SavepointName is empty if the whole transaction is rolled
+// back.
+type RollbackArgs struct {
+	SavepointName string
+}
+
+// SavepointArgs are the arguments of a SAVEPOINT or a RELEASE SAVEPOINT statement.
+type SavepointArgs struct {
+	SavepointName string
+}
+
+type VacuumArgs struct {
+	TableName string
+}
+
+// DropTableArgs are the arguments of a DROP TABLE statement. If IfExists is set, it is not an error if the table does
+// not exist.
+type DropTableArgs struct {
+	TableName string
+	IfExists  bool
+}
+
+type TruncateTableArgs struct {
+	TableName string
+}
+
+// AlterTableAction is the change that an ALTER TABLE statement makes to its table.
+type AlterTableAction int
+
+const (
+	AlterRenameTable  AlterTableAction = iota // RENAME TO {newTableName}
+	AlterAddColumn                            // ADD [COLUMN] {field} [DEFAULT {value}]
+	AlterDropColumn                           // DROP [COLUMN] {fieldName}
+	AlterRenameColumn                         // RENAME [COLUMN] {fieldName} TO {newFieldName}
+	AlterColumnType                           // ALTER [COLUMN] {fieldName} [SET DATA] TYPE {type}
+)
+
+// AlterTableArgs are the arguments of an ALTER TABLE statement. NewTableName is set when renaming the table, Field
+// and Default when adding a column, FieldName when dropping, renaming or changing the type of a column, NewFieldName
+// when renaming it and Field, which is the column with its new type, when changing its type. Default is nil if the
+// added column has no default, in which case it is NULL in every row.
+type AlterTableArgs struct {
+	TableName    string
+	Action       AlterTableAction
+	NewTableName string
+	Field        backend.Field
+	Default      *UntypedValue
+	FieldName    string
+	NewFieldName string
+}
+
+// CreateDatabaseArgs are the arguments of a CREATE DATABASE statement.
+type CreateDatabaseArgs struct {
+	DatabaseName string
+}
+
+// DropDatabaseArgs are the arguments of a DROP DATABASE statement. If IfExists is set, it is not an error if the
+// database does not exist.
+type DropDatabaseArgs struct {
+	DatabaseName string
+	IfExists     bool
+}
+
+// UseArgs are the arguments of a USE statement.
+type UseArgs struct {
+	DatabaseName string
+}
+
 // captureArguments will capture all arguments required for an executable from the list of tokens with the start index
 // being the index of the last token in the command statement. If arguments cannot be properly captured, an error
 // will be returned. It returns the arguments as an evaluable slice and the index of the last argument token.
@@ -130,11 +383,46 @@ func captureArguments(forCommand Command, tokens []token, start int) (args inter
 	case InsertCommand:
 		args, index, err = captureInsertArgs(truncated)
 	case SelectCommand:
-		args, index, err = captureSelectArgs(truncated)
+		switch {
+		case isKeywordToken(tokens[start], KeywordShow):
+			args, index, err = captureShowArgs(truncated)
+		case isKeywordToken(tokens[start], KeywordDescribe):
+			args, index, err = captureDescribeArgs(truncated)
+		default:
+			args, index, err = captureSelectArgs(truncated)
+		}
 	case DeleteCommand:
 		args, index, err = captureDeleteArgs(truncated)
 	case UpdateCommand:
 		args, index, err = captureUpdateArgs(truncated)
+	case CreateIndexCommand:
+		args, index, err = captureCreateIndexArgs(truncated, isKeywordToken(tokens[start-1], KeywordUnique))
+	case DropIndexCommand:
+		args, index, err = captureDropIndexArgs(truncated)
+	case BeginCommand:
+		args, index, err = captureBeginArgs(truncated)
+	case CommitCommand:
+		index = captureTransactionKeyword(truncated)
+	case RollbackCommand:
+		args, index, err = captureRollbackArgs(truncated)
+	case SavepointCommand:
+		args, index, err = captureSavepointArgs(truncated, false)
+	case ReleaseSavepointCommand:
+		args, index, err = captureSavepointArgs(truncated, true)
+	case VacuumCommand:
+		args, index, err = captureVacuumArgs(truncated)
+	case DropTableCommand:
+		args, index, err = captureDropTableArgs(truncated)
+	case TruncateTableCommand:
+		args, index, err = captureTruncateTableArgs(truncated)
+	case AlterTableCommand:
+		args, index, err = captureAlterTableArgs(truncated)
+	case CreateDatabaseCommand:
+		args, index, err = captureCreateDatabaseArgs(truncated)
+	case DropDatabaseCommand:
+		args, index, err = captureDropDatabaseArgs(truncated)
+	case UseCommand:
+		args, index, err = captureUseArgs(truncated)
 	}
 
 	if err != nil {
@@ -227,7 +515,14 @@ func captureInsertArgs(truncated []token) (*InsertArgs, int, error) {
 	}
 	tokensUsed++
 
-	valueStrings := strings.Split(cleanString(valuesToken.s), ",")
+	valueStrings, err := splitList(valuesToken.s)
+	if err != nil {
+		return nil, tokensUsed, err
+	}
+
+	if hasFieldNames && len(fieldNames) != len(valueStrings) {
+		return nil, tokensUsed, fmt.Errorf("number of values (%d) does not match number of fields (%d)", len(valueStrings), len(fieldNames))
+	}
 
 	if !hasFieldNames {
 		fieldNames = make([]string, len(valueStrings))
@@ -240,10 +535,12 @@ func captureInsertArgs(truncated []token) (*InsertArgs, int, error) {
 
 	var values []UntypedValue
 	for i, value := range valueStrings {
-		values = append(values, UntypedValue{
-			Val:       value,
-			FieldName: strippedNames[i],
-		})
+		val, err := listValue(value, strippedNames[i])
+		if err != nil {
+			return nil, tokensUsed, err
+		}
+
+		values = append(values, val)
 	}
 
 	return &InsertArgs{TableName: name.s, Values: values, HasFieldNames: hasFieldNames}, tokensUsed, nil
@@ -253,18 +550,40 @@ func captureSelectArgs(truncated []token) (*SelectArgs, int, error) {
 	tokensUsed := 0
 
 	var fieldNames []string
+	var expressions []SelectExpression
 	for l := len(truncated); tokensUsed < l; tokensUsed++ {
 		c := truncated[tokensUsed]
 		if strings.ToLower(c.s) == string(KeywordFrom) {
 			break
 		}
 
+		// aggregate functions are a function name followed by a parenthesis group containing the field
+		if tokensUsed+1 < l && isAggregateFunction(c.s) && truncated[tokensUsed+1].t == TokenTypeParenthesisGroup {
+			tokensUsed++
+
+			expression, err := parseAggregate(c.s, truncated[tokensUsed].s)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			expressions = append(expressions, expression)
+			continue
+		}
+
 		split := strings.Split(c.s, ",")
 
 		fieldNames = append(fieldNames, split...)
+		for _, fieldName := range split {
+			tableName, fieldName := asTableField(fieldName)
+			expressions = append(expressions, SelectExpression{TableName: tableName, FieldName: fieldName})
+		}
 	}
 	tokensUsed++
 
+	if tokensUsed >= len(truncated) {
+		return nil, 0, fmt.Errorf("expecting FROM followed by a table name")
+	}
+
 	// check for * select all
 	allFields := len(fieldNames) == 1 && fieldNames[0] == "*"
 	if allFields {
@@ -274,8 +593,8 @@ func captureSelectArgs(truncated []token) (*SelectArgs, int, error) {
 	tableName := truncated[tokensUsed].s
 	tokensUsed++
 
-	// search for WHERE clause
-	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, tableName)
+	// search for a WHERE clause before the JOIN clauses, which can only filter the table being selected from
+	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, []string{tableName})
 	if err != nil {
 		return nil, 0, fmt.Errorf("could not parse WHERE clause: %w", err)
 	}
@@ -293,6 +612,55 @@ func captureSelectArgs(truncated []token) (*SelectArgs, int, error) {
 		tableNames = append(tableNames, clause.TableName)
 	}
 
+	// search for a WHERE clause after the JOIN clauses, which can filter on any of the tables
+	if len(joinClauses) != 0 {
+		joinedWhereClause, temp, err := searchWhereClause(truncated, tokensUsed, tableNames)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not parse WHERE clause: %w", err)
+		}
+		tokensUsed += temp
+
+		if whereClause == nil {
+			whereClause = joinedWhereClause
+		} else if joinedWhereClause != nil {
+			whereClause = &WhereClause{Logic: backend.LogicalAnd, Clauses: []WhereClause{*whereClause, *joinedWhereClause}}
+		}
+	}
+
+	// search for GROUP BY clause
+	groupBy, temp, err := searchGroupByClause(truncated, tokensUsed, tableNames)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse GROUP BY clause: %w", err)
+	}
+	tokensUsed += temp
+
+	// search for HAVING clause
+	havingClause, temp, err := searchHavingClause(truncated, tokensUsed, tableNames)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse HAVING clause: %w", err)
+	}
+	tokensUsed += temp
+
+	// search for ORDER BY clause
+	orderByClauses, temp, err := searchOrderByClause(truncated, tokensUsed, tableNames)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse ORDER BY clause: %w", err)
+	}
+	tokensUsed += temp
+
+	// search for LIMIT and OFFSET clauses
+	limit, hasLimit, offset, temp, err := searchLimitClause(truncated, tokensUsed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse LIMIT clause: %w", err)
+	}
+	tokensUsed += temp
+
+	for _, expression := range expressions {
+		if !isEmptyString(expression.TableName) && !contains(tableNames, expression.TableName) {
+			return nil, 0, fmt.Errorf("table %s is not part of the statement", expression.TableName)
+		}
+	}
+
 	tableFields, _ := asTableFields(fieldNames, tableNames)
 
 	return &SelectArgs{
@@ -301,6 +669,13 @@ func captureSelectArgs(truncated []token) (*SelectArgs, int, error) {
 		AllFields:   allFields,
 		Filter:      whereClause,
 		Joins:       joinClauses,
+		Expressions: expressions,
+		GroupBy:     groupBy,
+		Having:      havingClause,
+		OrderBy:     orderByClauses,
+		Limit:       limit,
+		HasLimit:    hasLimit,
+		Offset:      offset,
 	}, tokensUsed, nil
 }
 
@@ -318,7 +693,7 @@ func captureDeleteArgs(truncated []token) (*DeleteArgs, int, error) {
 	tokensUsed++
 
 	// search for WHERE clause
-	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, name.s)
+	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, []string{name.s})
 	if err != nil {
 		return nil, 0, fmt.Errorf("could not parse WHERE clause: %w", err)
 	}
@@ -382,13 +757,10 @@ func captureUpdateArgs(truncated []token) (*UpdateArgs, int, error) {
 			return nil, 0, fmt.Errorf("value statement must use \"=\" between field name and value")
 		}
 
-		vals = append(vals, UntypedValue{
-			Val:       e2.s,
-			FieldName: stripTableNameFromField(e1.s, name.s),
-		})
+		vals = append(vals, untypedValue(e2, stripTableNameFromField(e1.s, name.s)))
 	}
 
-	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, name.s)
+	whereClause, temp, err := searchWhereClause(truncated, tokensUsed, []string{name.s})
 	if err != nil {
 		return nil, 0, fmt.Errorf("could not parse WHERE clause: %w", err)
 	}
@@ -400,3 +772,514 @@ func captureUpdateArgs(truncated []token) (*UpdateArgs, int, error) {
 		Filter:    whereClause,
 	}, tokensUsed, nil
 }
+
+// captureCreateIndexArgs captures the arguments of a CREATE [UNIQUE] INDEX {indexName} ON {tableName} ({fieldName})
+// statement.
+func captureCreateIndexArgs(truncated []token, unique bool) (*CreateIndexArgs, int, error) {
+	if len(truncated) < 4 {
+		return nil, 0, fmt.Errorf("not enough arguments")
+	}
+
+	tokensUsed := 0
+
+	name := truncated[tokensUsed]
+	if name.t != TokenTypeValue || isKeyword(name.s) {
+		return nil, 0, fmt.Errorf("invalid index name")
+	}
+	tokensUsed++
+
+	if !isKeywordToken(truncated[tokensUsed], KeywordOn) {
+		return nil, 0, fmt.Errorf("expecting ON followed by a table name")
+	}
+	tokensUsed++
+
+	tableName := truncated[tokensUsed]
+	if tableName.t != TokenTypeValue {
+		return nil, 0, fmt.Errorf("invalid table name")
+	}
+	tokensUsed++
+
+	field := truncated[tokensUsed]
+	if field.t != TokenTypeParenthesisGroup {
+		return nil, 0, fmt.Errorf("expecting the field to index in parenthesis")
+	}
+	tokensUsed++
+
+	fieldName := cleanString(field.s)
+	if strings.Contains(fieldName, ",") {
+		return nil, 0, fmt.Errorf("an index can only be of a single field")
+	}
+
+	if isEmptyString(fieldName) {
+		return nil, 0, fmt.Errorf("expecting the field to index in parenthesis")
+	}
+
+	return &CreateIndexArgs{
+		TableName: tableName.s,
+		Index: backend.Index{
+			Name:      name.s,
+			FieldName: stripTableNameFromField(fieldName, tableName.s),
+			Unique:    unique,
+		},
+	}, tokensUsed, nil
+}
+
+// captureDropIndexArgs captures the arguments of a DROP INDEX {indexName} ON {tableName} statement. Indexes belong to
+// a table, so the table has to be named.
+func captureDropIndexArgs(truncated []token) (*DropIndexArgs, int, error) {
+	if len(truncated) < 3 {
+		return nil, 0, fmt.Errorf("expecting an index name followed by ON and a table name")
+	}
+
+	tokensUsed := 0
+
+	name := truncated[tokensUsed]
+	if name.t != TokenTypeValue {
+		return nil, 0, fmt.Errorf("invalid index name")
+	}
+	tokensUsed++
+
+	if !isKeywordToken(truncated[tokensUsed], KeywordOn) {
+		return nil, 0, fmt.Errorf("expecting ON followed by a table name")
+	}
+	tokensUsed++
+
+	tableName := truncated[tokensUsed]
+	if tableName.t != TokenTypeValue {
+		return nil, 0, fmt.Errorf("invalid table name")
+	}
+	tokensUsed++
+
+	return &DropIndexArgs{TableName: tableName.s, IndexName: name.s}, tokensUsed, nil
+}
+
+// captureTransactionKeyword captures the optional TRANSACTION or WORK after BEGIN, COMMIT and ROLLBACK, which do not
+// change their meaning.
+func captureTransactionKeyword(truncated []token) int {
+	if len(truncated) != 0 && truncated[0].t == TokenTypeValue {
+		switch strings.ToLower(truncated[0].s) {
+		case "transaction", "work":
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// captureBeginArgs captures the arguments of a BEGIN [TRANSACTION] [ISOLATION LEVEL {level}] statement, where the
+// level is READ COMMITTED, or SNAPSHOT or REPEATABLE READ, which are the same. The words of the clause are not
+// keywords, so they can still be used as names.
+func captureBeginArgs(truncated []token) (*BeginArgs, int, error) {
+	tokensUsed := captureTransactionKeyword(truncated)
+
+	if tokensUsed == len(truncated) {
+		return &BeginArgs{}, tokensUsed, nil
+	}
+
+	var words []string
+	for _, t := range truncated[tokensUsed:] {
+		if t.t != TokenTypeValue {
+			break
+		}
+
+		words = append(words, strings.ToLower(t.s))
+	}
+
+	if len(words) < 3 || words[0] != "isolation" || words[1] != "level" {
+		return nil, 0, fmt.Errorf("expecting ISOLATION LEVEL followed by an isolation level")
+	}
+
+	switch level := strings.Join(words[2:], " "); level {
+	case "read committed":
+		return &BeginArgs{Isolation: backend.IsolationReadCommitted}, tokensUsed + len(words), nil
+	case "snapshot", "repeatable read":
+		return &BeginArgs{Isolation: backend.IsolationSnapshot}, tokensUsed + len(words), nil
+	default:
+		return nil, 0, fmt.Errorf(`invalid isolation level "%s", expecting READ COMMITTED, REPEATABLE READ or SNAPSHOT`, level)
+	}
+}
+
+// captureRollbackArgs captures the arguments of a ROLLBACK [TRANSACTION] [TO [SAVEPOINT] {savepointName}] statement.
+func captureRollbackArgs(truncated []token) (*RollbackArgs, int, error) {
+	tokensUsed := captureTransactionKeyword(truncated)
+
+	if tokensUsed == len(truncated) {
+		return &RollbackArgs{}, tokensUsed, nil
+	}
+
+	if !isKeywordToken(truncated[tokensUsed], KeywordTo) {
+		return nil, 0, fmt.Errorf("expecting TO followed by a savepoint name")
+	}
+	tokensUsed++
+
+	args, used, err := captureSavepointArgs(truncated[tokensUsed:], true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &RollbackArgs{SavepointName: args.SavepointName}, tokensUsed + used, nil
+}
+
+// captureSavepointArgs captures the name of a SAVEPOINT {savepointName} statement. If optionalKeyword is true, the
+// name can also come after the SAVEPOINT keyword, as in RELEASE [SAVEPOINT] {savepointName}.
+func captureSavepointArgs(truncated []token, optionalKeyword bool) (*SavepointArgs, int, error) {
+	tokensUsed := 0
+
+	if optionalKeyword && len(truncated) != 0 && isKeywordToken(truncated[0], KeywordSavepoint) {
+		tokensUsed++
+	}
+
+	if tokensUsed == len(truncated) {
+		return nil, 0, fmt.Errorf("expecting a savepoint name")
+	}
+
+	name := truncated[tokensUsed]
+	if name.t != TokenTypeValue || isKeyword(name.s) {
+		return nil, 0, fmt.Errorf("invalid savepoint name")
+	}
+	tokensUsed++
+
+	return &SavepointArgs{SavepointName: name.s}, tokensUsed, nil
+}
+
+// captureVacuumArgs captures the table name of a VACUUM {tableName} statement.
+func captureVacuumArgs(truncated []token) (*VacuumArgs, int, error) {
+	if len(truncated) == 0 {
+		return nil, 0, fmt.Errorf("expecting a table name")
+	}
+
+	tableName := truncated[0]
+	if tableName.t != TokenTypeValue {
+		return nil, 0, fmt.Errorf("invalid table name")
+	}
+
+	return &VacuumArgs{TableName: tableName.s}, 1, nil
+}
+
+// captureDropTableArgs captures the arguments of a DROP TABLE [IF EXISTS] {tableName} statement. IF and EXISTS are
+// not keywords, so they can still be used as names.
+func captureDropTableArgs(truncated []token) (*DropTableArgs, int, error) {
+	tokensUsed := 0
+	ifExists := false
+
+	if len(truncated) > 2 && isWordToken(truncated[0], "if") && isWordToken(truncated[1], "exists") {
+		ifExists = true
+		tokensUsed += 2
+	}
+
+	tableName, err := captureTableName(truncated[tokensUsed:])
+	if err != nil {
+		return nil, 0, err
+	}
+	tokensUsed++
+
+	return &DropTableArgs{TableName: tableName, IfExists: ifExists}, tokensUsed, nil
+}
+
+// captureCreateDatabaseArgs captures the database name of a CREATE DATABASE {databaseName} statement.
+func captureCreateDatabaseArgs(truncated []token) (*CreateDatabaseArgs, int, error) {
+	databaseName, err := captureDatabaseName(truncated)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &CreateDatabaseArgs{DatabaseName: databaseName}, 1, nil
+}
+
+// captureDropDatabaseArgs captures the arguments of a DROP DATABASE [IF EXISTS] {databaseName} statement, see
+// captureDropTableArgs.
+func captureDropDatabaseArgs(truncated []token) (*DropDatabaseArgs, int, error) {
+	tokensUsed := 0
+	ifExists := false
+
+	if len(truncated) > 2 && isWordToken(truncated[0], "if") && isWordToken(truncated[1], "exists") {
+		ifExists = true
+		tokensUsed += 2
+	}
+
+	databaseName, err := captureDatabaseName(truncated[tokensUsed:])
+	if err != nil {
+		return nil, 0, err
+	}
+	tokensUsed++
+
+	return &DropDatabaseArgs{DatabaseName: databaseName, IfExists: ifExists}, tokensUsed, nil
+}
+
+// captureUseArgs captures the database name of a USE {databaseName} statement.
+func captureUseArgs(truncated []token) (*UseArgs, int, error) {
+	databaseName, err := captureDatabaseName(truncated)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &UseArgs{DatabaseName: databaseName}, 1, nil
+}
+
+// captureTruncateTableArgs captures the table name of a TRUNCATE [TABLE] {tableName} statement.
+func captureTruncateTableArgs(truncated []token) (*TruncateTableArgs, int, error) {
+	tokensUsed := 0
+
+	if len(truncated) != 0 && isKeywordToken(truncated[0], KeywordTable) {
+		tokensUsed++
+	}
+
+	tableName, err := captureTableName(truncated[tokensUsed:])
+	if err != nil {
+		return nil, 0, err
+	}
+	tokensUsed++
+
+	return &TruncateTableArgs{TableName: tableName}, tokensUsed, nil
+}
+
+// captureAlterTableArgs captures the arguments of an ALTER TABLE {tableName} statement, followed by one of the
+// actions of AlterTableAction. COLUMN, ADD, DEFAULT, DATA and TYPE are not keywords, so they can still be used as
+// names.
+func captureAlterTableArgs(truncated []token) (*AlterTableArgs, int, error) {
+	tableName, err := captureTableName(truncated)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args := &AlterTableArgs{TableName: tableName}
+	tokensUsed := 1
+
+	if len(truncated) == tokensUsed {
+		return nil, 0, fmt.Errorf("expecting RENAME, ADD, DROP or ALTER after the table name")
+	}
+
+	action := truncated[tokensUsed]
+	tokensUsed++
+
+	// the optional COLUMN of every action but renaming the table
+	skipColumn := func() {
+		if len(truncated) > tokensUsed+1 && isWordToken(truncated[tokensUsed], "column") {
+			tokensUsed++
+		}
+	}
+
+	switch {
+	case isKeywordToken(action, KeywordRename):
+		if len(truncated) > tokensUsed && isKeywordToken(truncated[tokensUsed], KeywordTo) {
+			tokensUsed++
+
+			args.NewTableName, err = captureTableName(truncated[tokensUsed:])
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid new table name: %w", err)
+			}
+			tokensUsed++
+
+			return args, tokensUsed, nil
+		}
+
+		skipColumn()
+
+		args.Action = AlterRenameColumn
+
+		args.FieldName, err = captureFieldName(truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed++
+
+		if len(truncated) == tokensUsed || !isKeywordToken(truncated[tokensUsed], KeywordTo) {
+			return nil, 0, fmt.Errorf("expecting TO followed by the new column name")
+		}
+		tokensUsed++
+
+		args.NewFieldName, err = captureFieldName(truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid new column name: %w", err)
+		}
+		tokensUsed++
+	case isWordToken(action, "add"):
+		skipColumn()
+
+		args.Action = AlterAddColumn
+
+		name, err := captureFieldName(truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed++
+
+		field, used, err := captureFieldType(name, truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed += used
+
+		args.Field = field
+
+		if len(truncated) > tokensUsed && isWordToken(truncated[tokensUsed], "default") {
+			tokensUsed++
+
+			if len(truncated) == tokensUsed {
+				return nil, 0, fmt.Errorf("expecting a value after DEFAULT")
+			}
+
+			value := truncated[tokensUsed]
+			if value.t != TokenTypeValue && value.t != TokenTypeQuoteGroup {
+				return nil, 0, fmt.Errorf("invalid default value")
+			}
+			tokensUsed++
+
+			def := untypedValue(value, field.Name)
+			args.Default = &def
+		}
+	case isKeywordToken(action, KeywordDrop):
+		skipColumn()
+
+		args.Action = AlterDropColumn
+
+		args.FieldName, err = captureFieldName(truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed++
+	case isKeywordToken(action, KeywordAlter):
+		skipColumn()
+
+		args.Action = AlterColumnType
+
+		args.FieldName, err = captureFieldName(truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed++
+
+		if len(truncated) > tokensUsed+1 && isKeywordToken(truncated[tokensUsed], KeywordSet) && isWordToken(truncated[tokensUsed+1], "data") {
+			tokensUsed += 2
+		}
+
+		if len(truncated) == tokensUsed || !isWordToken(truncated[tokensUsed], "type") {
+			return nil, 0, fmt.Errorf("expecting TYPE followed by the new type of the column")
+		}
+		tokensUsed++
+
+		field, used, err := captureFieldType(args.FieldName, truncated[tokensUsed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		tokensUsed += used
+
+		args.Field = field
+	default:
+		return nil, 0, fmt.Errorf("expecting RENAME, ADD, DROP or ALTER after the table name")
+	}
+
+	return args, tokensUsed, nil
+}
+
+// captureFieldName captures the field name that the tokens begin with.
+func captureFieldName(truncated []token) (string, error) {
+	if len(truncated) == 0 {
+		return "", fmt.Errorf("expecting a column name")
+	}
+
+	fieldName := truncated[0]
+	if fieldName.t != TokenTypeValue || isKeyword(fieldName.s) {
+		return "", fmt.Errorf("invalid column name")
+	}
+
+	return fieldName.s, nil
+}
+
+// captureFieldType captures the type that the tokens begin with as the type of a field with the name, in the same
+// way as the fields of a CREATE TABLE statement. The length of a varchar is the parenthesis group after it. It
+// returns the field and the number of tokens used.
+func captureFieldType(name string, truncated []token) (backend.Field, int, error) {
+	if len(truncated) == 0 || truncated[0].t != TokenTypeValue {
+		return backend.Field{}, 0, fmt.Errorf("expecting the type of column %s", name)
+	}
+
+	declaration := truncated[0].s
+	tokensUsed := 1
+
+	if len(truncated) > 1 && truncated[1].t == TokenTypeParenthesisGroup {
+		declaration += "(" + truncated[1].s + ")"
+		tokensUsed++
+	}
+
+	field, err := parseField(name + " " + declaration)
+	if err != nil {
+		return backend.Field{}, 0, fmt.Errorf("could not parse column %s: %w", name, err)
+	}
+
+	return field, tokensUsed, nil
+}
+
+// captureTableName captures the table name that the tokens begin with.
+func captureTableName(truncated []token) (string, error) {
+	if len(truncated) == 0 {
+		return "", fmt.Errorf("expecting a table name")
+	}
+
+	tableName := truncated[0]
+	if tableName.t != TokenTypeValue || isKeyword(tableName.s) {
+		return "", fmt.Errorf("invalid table name")
+	}
+
+	return tableName.s, nil
+}
+
+func captureDatabaseName(truncated []token) (string, error) {
+	if len(truncated) == 0 {
+		return "", fmt.Errorf("expecting a database name")
+	}
+
+	databaseName := truncated[0]
+	if databaseName.t != TokenTypeValue || isKeyword(databaseName.s) {
+		return "", fmt.Errorf("invalid database name")
+	}
+
+	return databaseName.s, nil
+}
+
+// captureShowArgs captures the arguments of a SHOW TABLES statement, which selects the name of every table from the
+// information_schema.tables system table. TABLES is not a keyword, so it can still be used as a name.
+func captureShowArgs(truncated []token) (*SelectArgs, int, error) {
+	if len(truncated) == 0 || !isWordToken(truncated[0], "tables") {
+		return nil, 0, fmt.Errorf("expecting TABLES after SHOW")
+	}
+
+	args, err := systemSelectArgs(`table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' ORDER BY table_name`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return args, 1, nil
+}
+
+// captureDescribeArgs captures the arguments of a DESCRIBE {tableName} statement, which selects the fields of the table
+// from the information_schema.columns system table. A table that does not exist has no fields.
+func captureDescribeArgs(truncated []token) (*SelectArgs, int, error) {
+	tableName, err := captureTableName(truncated)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args, err := systemSelectArgs(`column_name, data_type, character_maximum_length FROM information_schema.columns WHERE table_name = '' ORDER BY ordinal_position`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the name is set after parsing, so it is never parsed as part of the statement
+	args.Filter.Val = tableName
+
+	return args, 1, nil
+}
+
+// systemSelectArgs captures the arguments of the SELECT statement, without its SELECT keyword, that a statement about
+// the catalog is run as.
+func systemSelectArgs(statement string) (*SelectArgs, error) {
+	tokens, err := split(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	args, _, err := captureSelectArgs(tokens)
+
+	return args, err
+}
@@ -9,20 +9,50 @@ import (
 type keyword string
 
 const (
-	KeywordSelect keyword = "select"
-	KeywordFrom   keyword = "from"
-	KeywordAs     keyword = "as"
-	KeywordTable  keyword = "table"
-	KeywordCreate keyword = "create"
-	KeywordInsert keyword = "insert"
-	KeywordInto   keyword = "into"
-	KeywordValues keyword = "values"
-	KeywordDelete keyword = "delete"
-	KeywordUpdate keyword = "update"
-	KeywordSet    keyword = "set"
-	KeywordWhere  keyword = "where"
-	KeywordJoin   keyword = "join"
-	KeywordOn     keyword = "on"
+	KeywordSelect    keyword = "select"
+	KeywordFrom      keyword = "from"
+	KeywordAs        keyword = "as"
+	KeywordTable     keyword = "table"
+	KeywordCreate    keyword = "create"
+	KeywordInsert    keyword = "insert"
+	KeywordInto      keyword = "into"
+	KeywordValues    keyword = "values"
+	KeywordDelete    keyword = "delete"
+	KeywordUpdate    keyword = "update"
+	KeywordSet       keyword = "set"
+	KeywordWhere     keyword = "where"
+	KeywordJoin      keyword = "join"
+	KeywordOn        keyword = "on"
+	KeywordAnd       keyword = "and"
+	KeywordOr        keyword = "or"
+	KeywordNot       keyword = "not"
+	KeywordOrder     keyword = "order"
+	KeywordBy        keyword = "by"
+	KeywordAsc       keyword = "asc"
+	KeywordDesc      keyword = "desc"
+	KeywordLimit     keyword = "limit"
+	KeywordOffset    keyword = "offset"
+	KeywordGroup     keyword = "group"
+	KeywordHaving    keyword = "having"
+	KeywordIs        keyword = "is"
+	KeywordNull      keyword = "null"
+	KeywordIndex     keyword = "index"
+	KeywordUnique    keyword = "unique"
+	KeywordDrop      keyword = "drop"
+	KeywordBegin     keyword = "begin"
+	KeywordCommit    keyword = "commit"
+	KeywordRollback  keyword = "rollback"
+	KeywordSavepoint keyword = "savepoint"
+	KeywordRelease   keyword = "release"
+	KeywordTo        keyword = "to"
+	KeywordVacuum    keyword = "vacuum"
+	KeywordTruncate  keyword = "truncate"
+	KeywordAlter     keyword = "alter"
+	KeywordRename    keyword = "rename"
+	KeywordShow      keyword = "show"
+	KeywordDescribe  keyword = "describe"
+	KeywordDatabase  keyword = "database"
+	KeywordUse       keyword = "use"
 )
 
 func isKeyword(s string) bool {
@@ -40,7 +70,12 @@ func asKeyword(s string) keyword {
 
 func (k keyword) IsValid() bool {
 	switch k {
-	case KeywordOn, KeywordJoin, KeywordSelect, KeywordFrom, KeywordAs, KeywordTable, KeywordCreate, KeywordInsert, KeywordInto, KeywordValues, KeywordWhere, KeywordDelete, KeywordUpdate, KeywordSet:
+	case KeywordOn, KeywordJoin, KeywordSelect, KeywordFrom, KeywordAs, KeywordTable, KeywordCreate, KeywordInsert, KeywordInto, KeywordValues, KeywordWhere, KeywordDelete, KeywordUpdate, KeywordSet,
+		KeywordAnd, KeywordOr, KeywordNot, KeywordOrder, KeywordBy, KeywordAsc, KeywordDesc,
+		KeywordLimit, KeywordOffset, KeywordGroup, KeywordHaving, KeywordIs, KeywordNull, KeywordIndex, KeywordUnique, KeywordDrop,
+		KeywordBegin, KeywordCommit, KeywordRollback, KeywordSavepoint, KeywordRelease, KeywordTo,
+		KeywordVacuum, KeywordTruncate, KeywordAlter, KeywordRename, KeywordShow, KeywordDescribe,
+		KeywordDatabase, KeywordUse:
 		return true
 	}
 	return false
@@ -89,9 +124,36 @@ type token struct {
 	t tokenType
 }
 
+// WhereClause is a node of the boolean predicate tree of a WHERE clause. A leaf compares the field of UntypedValue
+// against its Val using Operator, or if Aggregate is set, the aggregate of that field. TableName is the table of the
+// field if the field was qualified with one. If Logic is set, the node is instead compound and combines the results
+// of Clauses, NOT nodes have exactly one clause while AND and OR nodes have two or more.
 type WhereClause struct {
 	UntypedValue
-	Operator backend.Operator
+	TableName string
+	Operator  backend.Operator
+	Aggregate AggregateFunction
+	Logic     backend.LogicalOperator
+	Clauses   []WhereClause
+}
+
+// ColumnName is the name of the value a leaf compares, which is the field name in the format of
+// {tableName}.{fieldName} if it was qualified, unless it compares an aggregate.
+func (w *WhereClause) ColumnName() string {
+	if w.Aggregate != "" {
+		return aggregateName(w.Aggregate, w.FieldName)
+	}
+
+	if !isEmptyString(w.TableName) {
+		return w.TableName + "." + w.FieldName
+	}
+
+	return w.FieldName
+}
+
+// IsCompound returns whether the WhereClause combines child clauses instead of comparing a field.
+func (w *WhereClause) IsCompound() bool {
+	return w.Logic != ""
 }
 
 type JoinLocation string
@@ -107,11 +169,16 @@ const (
 func asJoinLocation(s string) JoinLocation {
 	s = strings.ToLower(s)
 
+	// a FULL JOIN is another name for an OUTER JOIN
+	if s == "full" {
+		return JoinLocationOuter
+	}
+
 	return JoinLocation(s)
 }
 
 func isJoinLocation(s string) bool {
-	switch JoinLocation(s) {
+	switch asJoinLocation(s) {
 	case JoinLocationInner, JoinLocationLeft, JoinLocationRight, JoinLocationOuter:
 		return true
 	}
@@ -119,10 +186,47 @@ func isJoinLocation(s string) bool {
 	return false
 }
 
+// JoinClause joins the table TableName to the rows of the tables before it. Rows are combined where ChildField of
+// the joined table equals ParentField of ParentTable, which is any table that comes before it in the statement.
 type JoinClause struct {
+	ParentTable string
 	ParentField string
 	ChildField  string
 	TableName   string
 	Location    JoinLocation
-	Filter      *WhereClause
+}
+
+// OrderByClause is a single field of an ORDER BY clause. TableName is empty if the field was not qualified with one.
+type OrderByClause struct {
+	TableName  string
+	FieldName  string
+	Descending bool
+}
+
+// AggregateFunction computes a single value out of the values of a field across a group of rows.
+type AggregateFunction string
+
+const (
+	AggregateCount AggregateFunction = "count"
+	AggregateSum   AggregateFunction = "sum"
+	AggregateAvg   AggregateFunction = "avg"
+	AggregateMin   AggregateFunction = "min"
+	AggregateMax   AggregateFunction = "max"
+)
+
+// asAggregateFunction turns a string into an AggregateFunction, ignoring case. This is preferred over calling
+// AggregateFunction(s).
+func asAggregateFunction(s string) AggregateFunction {
+	s = strings.ToLower(s)
+
+	return AggregateFunction(s)
+}
+
+func isAggregateFunction(s string) bool {
+	switch asAggregateFunction(s) {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		return true
+	}
+
+	return false
 }
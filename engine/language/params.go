@@ -0,0 +1,327 @@
+package language
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// A parameter is a placeholder for a value in VALUES, SET, WHERE or HAVING that is given as an argument once the
+// statement is run, see Bind. Parameters are either numbered $1, $2 and so on, or are each a ?, which are numbered in
+// the order they appear. The two styles can not be mixed in one statement. A placeholder is a whole value on its own,
+// so a ? or $ that is part of a longer value, such as what? or us$, is part of that value.
+
+// numberPlaceholders replaces every ? placeholder of the statement with its numbered placeholder. It returns the
+// numbers of the placeholders of the statement, in the order they appear. Placeholders inside quotes are part of the
+// quoted string.
+func numberPlaceholders(statement string) (string, []int, error) {
+	var b strings.Builder
+	var numbers []int
+	questionMarks, numbered := false, false
+
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+
+		if isQuote(rune(c)) {
+			_, end, err := captureQuoteGroup(statement, i)
+			if err != nil {
+				return "", nil, err
+			}
+
+			b.WriteString(statement[i : end+1])
+			i = end
+			continue
+		}
+
+		if (c != '?' && c != '$') || (i > 0 && !isValueBoundary(statement[i-1])) {
+			b.WriteByte(c)
+			continue
+		}
+
+		end := i + 1
+		for end < len(statement) && !isValueBoundary(statement[end]) {
+			end++
+		}
+
+		switch value := statement[i:end]; {
+		case value == "?":
+			questionMarks = true
+			numbers = append(numbers, len(numbers)+1)
+			b.WriteString("$" + strconv.Itoa(len(numbers)))
+		case c == '$' && len(value) > 1 && strings.Trim(value[1:], "0123456789") == "":
+			n := asParam(value)
+			if n == 0 {
+				return "", nil, fmt.Errorf("invalid parameter %s at %d", value, i)
+			}
+
+			numbered = true
+			numbers = append(numbers, n)
+			b.WriteString(value)
+		default:
+			b.WriteString(value)
+		}
+
+		i = end - 1
+	}
+
+	if questionMarks && numbered {
+		return "", nil, fmt.Errorf("? and numbered parameters can not be used in the same statement")
+	}
+
+	return b.String(), numbers, nil
+}
+
+// isValueBoundary returns whether the byte ends a value that is not quoted, which is whitespace or a symbol such as a
+// comma or an operator.
+func isValueBoundary(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || isSymbol(rune(c))
+}
+
+// asParam returns the number of the parameter if the string is a numbered placeholder, or 0 if it is not.
+func asParam(s string) int {
+	if len(s) < 2 || s[0] != '$' {
+		return 0
+	}
+
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 || strconv.Itoa(n) != s[1:] {
+		return 0
+	}
+
+	return n
+}
+
+// untypedValue returns the UntypedValue of the value token, which is bound to a parameter if the token is a
+// placeholder.
+func untypedValue(t token, fieldName string) UntypedValue {
+	param := 0
+	if t.t == TokenTypeValue {
+		param = asParam(t.s)
+	}
+
+	return UntypedValue{Val: t.s, FieldName: fieldName, Param: param, Quoted: t.t == TokenTypeQuoteGroup}
+}
+
+// listValue returns the UntypedValue of a value of a list, such as the values of an INSERT, which are split from the
+// statement as they are, with their quotes. It is an error if the value is only partly quoted, see escapeQuotedString.
+func listValue(s string, fieldName string) (UntypedValue, error) {
+	unquoted, err := escapeQuotedString(s)
+	if err != nil {
+		return UntypedValue{}, err
+	}
+
+	if s != "" && isQuote(rune(s[0])) {
+		return UntypedValue{Val: unquoted, FieldName: fieldName, Quoted: true}, nil
+	}
+
+	return UntypedValue{Val: s, FieldName: fieldName, Param: asParam(s)}, nil
+}
+
+// checkParams returns an error if not every placeholder of the statement is the value of its args, since they can not
+// be used anywhere else.
+func checkParams(args interface{}, placeholders []int) error {
+	found := make(map[int]int, len(placeholders))
+	for _, val := range untypedValues(args) {
+		if val.Param != 0 {
+			found[val.Param]++
+		}
+	}
+
+	for _, n := range placeholders {
+		if found[n] == 0 {
+			return fmt.Errorf("parameter $%d can only be used as a value", n)
+		}
+
+		found[n]--
+	}
+
+	return nil
+}
+
+// untypedValues returns every value of the arguments of a command, in the order they appear in the statement.
+func untypedValues(args interface{}) []*UntypedValue {
+	var vals []*UntypedValue
+
+	var walk func(w *WhereClause)
+	walk = func(w *WhereClause) {
+		if w == nil {
+			return
+		}
+
+		if !w.IsCompound() {
+			vals = append(vals, &w.UntypedValue)
+		}
+
+		for i := range w.Clauses {
+			walk(&w.Clauses[i])
+		}
+	}
+
+	switch args := args.(type) {
+	case *InsertArgs:
+		for i := range args.Values {
+			vals = append(vals, &args.Values[i])
+		}
+	case *UpdateArgs:
+		for i := range args.Values {
+			vals = append(vals, &args.Values[i])
+		}
+		walk(args.Filter)
+	case *DeleteArgs:
+		walk(args.Filter)
+	case *SelectArgs:
+		walk(args.Filter)
+		walk(args.Having)
+	case *AlterTableArgs:
+		if args.Default != nil {
+			vals = append(vals, args.Default)
+		}
+	}
+
+	return vals
+}
+
+// NumParams returns the number of parameters of the arguments of a command, which is the highest parameter number
+// used.
+func NumParams(args interface{}) int {
+	n := 0
+	for _, val := range untypedValues(args) {
+		if val.Param > n {
+			n = val.Param
+		}
+	}
+
+	return n
+}
+
+// Bind returns a copy of the arguments of a command with each parameter bound to the argument of its number, the
+// first argument being $1. There must be exactly one argument for every parameter. The arguments are validated once
+// the values are created with UntypedValue.ValueForField.
+func Bind(args interface{}, params []interface{}) (interface{}, error) {
+	if n := NumParams(args); n != len(params) {
+		return nil, fmt.Errorf("expecting %d arguments, got %d", n, len(params))
+	}
+
+	var bound interface{}
+
+	switch args := args.(type) {
+	case *InsertArgs:
+		c := *args
+		c.Values = append([]UntypedValue(nil), args.Values...)
+		bound = &c
+	case *UpdateArgs:
+		c := *args
+		c.Values = append([]UntypedValue(nil), args.Values...)
+		c.Filter = copyWhereClause(args.Filter)
+		bound = &c
+	case *DeleteArgs:
+		c := *args
+		c.Filter = copyWhereClause(args.Filter)
+		bound = &c
+	case *SelectArgs:
+		c := *args
+		c.Filter = copyWhereClause(args.Filter)
+		c.Having = copyWhereClause(args.Having)
+		bound = &c
+	case *AlterTableArgs:
+		c := *args
+		if args.Default != nil {
+			def := *args.Default
+			c.Default = &def
+		}
+		bound = &c
+	default:
+		return args, nil
+	}
+
+	for _, val := range untypedValues(bound) {
+		if val.Param != 0 {
+			val.arg = params[val.Param-1]
+			val.bound = true
+		}
+	}
+
+	return bound, nil
+}
+
+// copyWhereClause returns a deep copy of the predicate tree.
+func copyWhereClause(w *WhereClause) *WhereClause {
+	if w == nil {
+		return nil
+	}
+
+	c := *w
+	c.Clauses = make([]WhereClause, len(w.Clauses))
+	for i := range w.Clauses {
+		c.Clauses[i] = *copyWhereClause(&w.Clauses[i])
+	}
+
+	return &c
+}
+
+// ValueForField creates a Value for the Field from the literal of the value, or from its argument if it is bound to a
+// parameter, see NewValueForArgument. A quoted literal is taken like the argument of a parameter, so it is never NULL.
+func (u UntypedValue) ValueForField(field backend.Field) (backend.Value, error) {
+	if u.Quoted {
+		return NewValueForArgument(field, u.Val)
+	}
+
+	if u.Param == 0 {
+		return NewValueForField(field, u.Val)
+	}
+
+	if !u.bound {
+		return backend.Value{}, fmt.Errorf("parameter $%d is not bound", u.Param)
+	}
+
+	val, err := NewValueForArgument(field, u.arg)
+	if err != nil {
+		return backend.Value{}, fmt.Errorf("parameter $%d: %w", u.Param, err)
+	}
+
+	return val, nil
+}
+
+// NewValueForArgument is like NewValueForField, except that val is the argument of a parameter instead of a literal.
+// Strings are taken as they are, so they are never NULL and keep their quotes, and every Go integer and float type is
+// accepted, with integers also accepted for float fields.
+func NewValueForArgument(field backend.Field, val interface{}) (backend.Value, error) {
+	switch v := val.(type) {
+	case int:
+		val = int64(v)
+	case int8:
+		val = int64(v)
+	case int16:
+		val = int64(v)
+	case int32:
+		val = int64(v)
+	case uint8:
+		val = int64(v)
+	case uint16:
+		val = int64(v)
+	case uint32:
+		val = int64(v)
+	case float32:
+		val = float64(v)
+	case string:
+		if field.Type == backend.PrimitiveString {
+			return backend.Value{Type: backend.PrimitiveString, Val: v, FieldName: field.Name}, nil
+		}
+
+		if asKeyword(v) == KeywordNull {
+			return backend.Value{}, fmt.Errorf("could not parse %s", field.Type)
+		}
+	case nil, int64, float64, bool:
+	default:
+		return backend.Value{}, fmt.Errorf("arguments of type %T are not supported", val)
+	}
+
+	// integers are exact floats as long as they are small enough, so they are accepted for float fields
+	if i, ok := val.(int64); ok && field.Type == backend.PrimitiveFloat {
+		val = float64(i)
+	}
+
+	return NewValueForField(field, val)
+}
@@ -0,0 +1,61 @@
+package language
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberPlaceholders(t *testing.T) {
+	for _, c := range []struct {
+		statement string
+		want      string
+		numbers   []int
+	}{
+		{"INSERT INTO t VALUES (?, ?)", "INSERT INTO t VALUES ($1, $2)", []int{1, 2}},
+		{"SELECT a FROM t WHERE a=? AND b>=$x", "SELECT a FROM t WHERE a=$1 AND b>=$x", []int{1}},
+		{"UPDATE t SET a = $2 WHERE b = $1", "UPDATE t SET a = $2 WHERE b = $1", []int{2, 1}},
+		// a ? or $ that is part of a value, or quoted, is not a placeholder
+		{"INSERT INTO t VALUES (what?, us$, $, '?', \"$1\")", "INSERT INTO t VALUES (what?, us$, $, '?', \"$1\")", nil},
+		{"INSERT INTO t VALUES (a?b, ?c, $1c)", "INSERT INTO t VALUES (a?b, ?c, $1c)", nil},
+	} {
+		got, numbers, err := numberPlaceholders(c.statement)
+		if err != nil {
+			t.Errorf("%s: %s", c.statement, err)
+			continue
+		}
+
+		if got != c.want || !reflect.DeepEqual(numbers, c.numbers) {
+			t.Errorf("%s was numbered as %s %v, want %s %v", c.statement, got, numbers, c.want, c.numbers)
+		}
+	}
+
+	for _, statement := range []string{
+		"SELECT a FROM t WHERE a = $0",
+		"SELECT a FROM t WHERE a = $01",
+		"SELECT a FROM t WHERE a = $1 AND b = ?",
+		"SELECT a FROM t WHERE a = 'unclosed",
+	} {
+		if got, _, err := numberPlaceholders(statement); err == nil {
+			t.Errorf("%s was numbered as %s instead of failing", statement, got)
+		}
+	}
+}
+
+func TestParsePlaceholders(t *testing.T) {
+	_, args, err := Parse("INSERT INTO t VALUES (us$, what?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := args.(*InsertArgs).Values
+	if values[0].Val != "us$" || values[1].Val != "what?" || values[0].Param != 0 || values[1].Param != 0 || values[2].Param != 1 {
+		t.Errorf("parsed the values as %+v", values)
+	}
+
+	// placeholders can only be values
+	for _, statement := range []string{"SELECT ? FROM t", "SELECT a FROM t ORDER BY $1"} {
+		if _, _, err := Parse(statement); err == nil {
+			t.Errorf("%s was parsed", statement)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package language
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/Dojo456/simple-sql-db/backend"
@@ -17,8 +18,13 @@ func Validate(statement string) error {
 }
 
 // Parse takes the SQL statement and returns an executable command the arguments for that command. If err is nil, then command
-// is guaranteed to be not-nil.
+// is guaranteed to be not-nil. The values of the statement can be parameters, whose arguments are bound with Bind.
 func Parse(statement string) (cmd *Command, args interface{}, err error) {
+	statement, placeholders, err := numberPlaceholders(statement)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not number parameters: %w", err)
+	}
+
 	tokens, err := split(statement)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not split statement: %w", err)
@@ -56,6 +62,15 @@ func Parse(statement string) (cmd *Command, args interface{}, err error) {
 		}
 	}
 
+	if cmd == nil {
+		return nil, nil, fmt.Errorf("no command found")
+	}
+
+	err = checkParams(args, placeholders)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return
 }
 
@@ -83,6 +98,8 @@ func split(statement string) ([]token, error) {
 	}
 
 	// using iterator loop to allow for easier of movement of cursor (incrementing or decrementing i)
+	// the statement is scanned byte by byte, every rune with syntactic meaning is ASCII so multibyte UTF-8 sequences
+	// are copied through unchanged
 	for i := 0; i < len(statement); i++ {
 		r := rune(statement[i])
 
@@ -136,7 +153,7 @@ func split(statement string) ([]token, error) {
 		} else if r == ' ' { // found end to current token, begin next token
 			addCurrentToken()
 		} else { // adding to current token
-			currentToken.WriteRune(r)
+			currentToken.WriteByte(statement[i])
 			continue
 		}
 	}
@@ -150,7 +167,7 @@ func split(statement string) ([]token, error) {
 // captureParenthesisGroup captures the parenthesis group starting at the given index in the given string.
 // It is an error if the rune at the start index is not an open parenthesis.
 // It returns the entire group as one string, excluding the outermost parenthesis, and also returns the rune index of
-// the closing parenthesis.
+// the closing parenthesis. Nested parenthesis and parenthesis inside of quotes are kept as part of the group.
 func captureParenthesisGroup(s string, start int) (group string, end int, err error) {
 	if s[start] != '(' {
 		return "", 0, fmt.Errorf("starting rune is not an open parenthesis")
@@ -159,16 +176,34 @@ func captureParenthesisGroup(s string, start int) (group string, end int, err er
 
 	i := start + 1
 	closed := false
+	depth := 0
 
 	for ; i < len(s); i++ {
 		c := rune(s[i])
 
-		if c == ')' {
-			closed = true
-			break
+		if isQuote(c) { // copy quote groups verbatim, including the quotes themselves
+			_, quoteEnd, err := captureQuoteGroup(s, i)
+			if err != nil {
+				return "", 0, err
+			}
+
+			captured.WriteString(s[i : quoteEnd+1])
+			i = quoteEnd
+			continue
 		}
 
-		captured.WriteRune(c)
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				closed = true
+				break
+			}
+
+			depth--
+		}
+
+		captured.WriteByte(s[i])
 	}
 
 	if !closed {
@@ -178,11 +213,46 @@ func captureParenthesisGroup(s string, start int) (group string, end int, err er
 	return captured.String(), i, nil
 }
 
+// splitList splits the contents of a parenthesis group into its elements, which are separated by commas, and trims the
+// spaces around each of them. Commas inside of quotes or nested parenthesis are kept as part of their element.
+func splitList(s string) ([]string, error) {
+	var elements []string
+
+	start := 0
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		c := rune(s[i])
+
+		if isQuote(c) {
+			_, quoteEnd, err := captureQuoteGroup(s, i)
+			if err != nil {
+				return nil, err
+			}
+
+			i = quoteEnd
+			continue
+		}
+
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			depth--
+		} else if c == ',' && depth == 0 {
+			elements = append(elements, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(elements, strings.TrimSpace(s[start:])), nil
+}
+
 // captureQuoteGroup captures a group surrounded by either single or double quotes starting at the given index in the
 // given string.
 // It is an error if the rune at the start index is not either a single or double quote.
 // It returns the entire group as one string, excluding the enclosing quotes, and also the rune index of the closing
-// quote.
+// quote, which is the same kind of quote as the opening one, so the other kind can be used inside of the group. The
+// quote is part of the group if it is written twice in a row, or if it is escaped with a backslash.
 func captureQuoteGroup(s string, start int) (group string, end int, err error) {
 	if !isQuote(rune(s[start])) {
 		return "", 0, fmt.Errorf("starting rune is not a quote")
@@ -197,7 +267,7 @@ func captureQuoteGroup(s string, start int) (group string, end int, err error) {
 		r := rune(s[i])
 
 		if escaped {
-			captured.WriteRune(r)
+			captured.WriteByte(s[i])
 			escaped = false
 			continue
 		}
@@ -207,12 +277,18 @@ func captureQuoteGroup(s string, start int) (group string, end int, err error) {
 			continue
 		}
 
-		if isQuote(r) {
+		if s[i] == s[start] {
+			if i+1 < len(s) && s[i+1] == s[start] {
+				captured.WriteByte(s[i])
+				i++
+				continue
+			}
+
 			closed = true
 			break
 		}
 
-		captured.WriteRune(r)
+		captured.WriteByte(s[i])
 	}
 
 	if !closed {
@@ -242,7 +318,7 @@ func captureSymbolGroup(s string, start int) (group string, end int, err error)
 			break
 		}
 
-		captured.WriteRune(r)
+		captured.WriteByte(s[i])
 	}
 
 	return captured.String(), i, nil
@@ -279,84 +355,280 @@ func parseEquation(tokens []token) (e1 token, operator backend.Operator, e2 toke
 	return tokens[0], backend.Operator(tokens[1].s), tokens[2], nil
 }
 
-func searchWhereClause(truncated []token, start int, tableName string) (*WhereClause, int, error) {
-	if start != len(truncated) && keyword(strings.ToLower(truncated[start].s)) == KeywordWhere {
-		tokensUsed := start
+// searchWhereClause parses a WHERE clause if one begins at the start index. Predicates can be combined with AND, OR
+// and NOT and grouped with parenthesis, with NOT binding tightest and OR loosest. It returns the root of the
+// predicate tree and the number of tokens used.
+func searchWhereClause(truncated []token, start int, tableNames []string) (*WhereClause, int, error) {
+	return searchPredicateClause(KeywordWhere, truncated, start, tableNames)
+}
+
+// searchHavingClause parses a HAVING clause if one begins at the start index. It has the same syntax as a WHERE
+// clause, except that aggregate functions such as COUNT(*) can be compared as well as fields.
+func searchHavingClause(truncated []token, start int, tableNames []string) (*WhereClause, int, error) {
+	return searchPredicateClause(KeywordHaving, truncated, start, tableNames)
+}
 
-		if len(truncated) < tokensUsed+3 {
-			return nil, 0, fmt.Errorf("incomplete WHERE clause")
+// searchPredicateClause parses a predicate that is introduced by the given keyword if one begins at the start index.
+// Fields can only be qualified with one of the table names.
+func searchPredicateClause(k keyword, truncated []token, start int, tableNames []string) (*WhereClause, int, error) {
+	if start != len(truncated) && asKeyword(truncated[start].s) == k {
+		if len(truncated) < start+2 {
+			return nil, 0, fmt.Errorf("incomplete %s clause", strings.ToUpper(string(k)))
 		}
 
-		tokensUsed++
-		wT := truncated[tokensUsed : tokensUsed+3]
+		p := predicateParser{tokens: truncated, pos: start + 1, tableNames: tableNames}
+
+		clause, err := p.parseOr()
+		if err != nil {
+			return nil, (p.pos - start), err
+		}
+
+		return &clause, (p.pos - start), nil
+	}
+
+	return nil, 0, nil
+}
+
+// predicateParser is a recursive descent parser for the boolean expressions of WHERE clauses. It stops at the first
+// token that can not continue the expression, leaving the rest of the tokens for the caller.
+type predicateParser struct {
+	tokens     []token
+	pos        int
+	tableNames []string
+}
+
+// peekKeyword returns whether the current token is the given keyword.
+func (p *predicateParser) peekKeyword(k keyword) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+
+	return isKeywordToken(p.tokens[p.pos], k)
+}
+
+// isKeywordToken returns whether the token is the given keyword. Quoted values are never keywords.
+func isKeywordToken(t token, k keyword) bool {
+	return t.t == TokenTypeValue && asKeyword(t.s) == k
+}
 
-		// parse field name
-		fieldNameToken := wT[0]
-		if fieldNameToken.t != TokenTypeValue {
-			return nil, tokensUsed, fmt.Errorf("could not parse WHERE clause")
+// isWordToken returns whether the token is the word, ignoring case, for the words of clauses that are not keywords.
+func isWordToken(t token, word string) bool {
+	return t.t == TokenTypeValue && strings.EqualFold(t.s, word)
+}
+
+func (p *predicateParser) parseOr() (WhereClause, error) {
+	return p.parseLogical(backend.LogicalOr, KeywordOr, p.parseAnd)
+}
+
+func (p *predicateParser) parseAnd() (WhereClause, error) {
+	return p.parseLogical(backend.LogicalAnd, KeywordAnd, p.parseNot)
+}
+
+// parseLogical parses one or more operands separated by the given keyword. Chains of the same operator are
+// flattened into a single compound clause.
+func (p *predicateParser) parseLogical(logic backend.LogicalOperator, k keyword, operand func() (WhereClause, error)) (WhereClause, error) {
+	first, err := operand()
+	if err != nil {
+		return WhereClause{}, err
+	}
+
+	clauses := []WhereClause{first}
+
+	for p.peekKeyword(k) {
+		p.pos++
+
+		next, err := operand()
+		if err != nil {
+			return WhereClause{}, err
 		}
-		fieldTableName, fieldName := asTableField(fieldNameToken.s)
-		if !isEmptyString(fieldTableName) && fieldTableName != tableName {
-			return nil, (tokensUsed - start), fmt.Errorf("field must be from table %s", tableName)
+
+		clauses = append(clauses, next)
+	}
+
+	if len(clauses) == 1 {
+		return first, nil
+	}
+
+	return WhereClause{Logic: logic, Clauses: clauses}, nil
+}
+
+func (p *predicateParser) parseNot() (WhereClause, error) {
+	if p.peekKeyword(KeywordNot) {
+		p.pos++
+
+		operand, err := p.parseNot()
+		if err != nil {
+			return WhereClause{}, err
 		}
 
-		// parse operator
-		operatorToken := wT[1]
-		if fieldNameToken.t != TokenTypeValue {
-			return nil, (tokensUsed - start), fmt.Errorf("could not parse WHERE clause")
+		return WhereClause{Logic: backend.LogicalNot, Clauses: []WhereClause{operand}}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses either a parenthesized expression or a single comparison.
+func (p *predicateParser) parsePrimary() (WhereClause, error) {
+	if p.pos >= len(p.tokens) {
+		return WhereClause{}, fmt.Errorf("incomplete WHERE clause")
+	}
+
+	current := p.tokens[p.pos]
+
+	if current.t == TokenTypeParenthesisGroup {
+		p.pos++
+
+		tokens, err := split(current.s)
+		if err != nil {
+			return WhereClause{}, fmt.Errorf("could not split parenthesis group: %w", err)
 		}
-		operator := backend.Operator(operatorToken.s)
-		if !operator.IsValid() {
-			return nil, (tokensUsed - start), fmt.Errorf("%s is not a valid operator", operatorToken.s)
+
+		sub := predicateParser{tokens: tokens, tableNames: p.tableNames}
+
+		clause, err := sub.parseOr()
+		if err != nil {
+			return WhereClause{}, err
 		}
 
-		// parse valueString to compare to
-		valueToken := wT[2]
-		tokensUsed += 3
+		if sub.pos != len(tokens) {
+			return WhereClause{}, fmt.Errorf("unexpected %s in parenthesis group", tokens[sub.pos].s)
+		}
 
-		return &WhereClause{
-			UntypedValue: UntypedValue{
-				Val:       valueToken.s,
-				FieldName: fieldName,
-			},
-			Operator: operator,
-		}, (tokensUsed - start), nil
+		return clause, nil
 	}
 
-	return nil, 0, nil
+	// an aggregate function is two tokens, the function name and the field in parenthesis. The field is moved into the
+	// function name's token so the comparison can be parsed like any other
+	equation := p.tokens[p.pos:]
+	var aggregate AggregateFunction
+	if len(equation) > 1 && current.t == TokenTypeValue && isAggregateFunction(current.s) && equation[1].t == TokenTypeParenthesisGroup {
+		aggregate = asAggregateFunction(current.s)
+		equation = append([]token{{s: equation[1].s, t: TokenTypeValue}}, equation[2:]...)
+	}
+
+	if len(equation) < 3 {
+		return WhereClause{}, fmt.Errorf("incomplete WHERE clause")
+	}
+
+	var fieldNameToken, valueToken token
+	var operator backend.Operator
+	var err error
+	used := 3
+
+	if isKeywordToken(equation[1], KeywordIs) { // {field} IS [NOT] NULL
+		fieldNameToken = equation[0]
+		operator = backend.OperatorIsNull
+
+		if isKeywordToken(equation[2], KeywordNot) {
+			operator = backend.OperatorIsNotNull
+			used++
+		}
+
+		if len(equation) < used || !isKeywordToken(equation[used-1], KeywordNull) {
+			return WhereClause{}, fmt.Errorf("expecting NULL after IS")
+		}
+	} else {
+		fieldNameToken, operator, valueToken, err = parseEquation(equation[:3])
+		if err != nil {
+			return WhereClause{}, fmt.Errorf("could not parse WHERE clause: %w", err)
+		}
+	}
+
+	fieldTableName, fieldName := asTableField(fieldNameToken.s)
+	if aggregate != "" {
+		expression, err := parseAggregate(string(aggregate), fieldNameToken.s)
+		if err != nil {
+			return WhereClause{}, err
+		}
+
+		fieldTableName, fieldName = expression.TableName, expression.FieldName
+		p.pos++
+	}
+
+	if !isEmptyString(fieldTableName) && !contains(p.tableNames, fieldTableName) {
+		return WhereClause{}, fmt.Errorf("table %s is not part of the statement", fieldTableName)
+	}
+	p.pos += used
+
+	return WhereClause{
+		UntypedValue: untypedValue(valueToken, fieldName),
+		TableName:    fieldTableName,
+		Operator:     operator,
+		Aggregate:    aggregate,
+	}, nil
 }
 
+// searchJoinClauses parses every JOIN clause beginning at the start index. A join can be preceded by INNER, LEFT,
+// RIGHT, FULL or OUTER, optionally followed by OUTER, and its ON equation must compare a field of the joined table
+// with a field of any table before it. It returns the clauses in order and the number of tokens used.
 func searchJoinClauses(truncated []token, start int, parentTable string) ([]JoinClause, int, error) {
 	var clauses []JoinClause
 	tokensUsed := start
+	tableNames := []string{parentTable}
 
-	for tokensUsed <= len(truncated)-6 { // multiple join clauses can be made, so loop. each clause requires at least 7
+	next := func() (string, error) {
+		if tokensUsed >= len(truncated) {
+			return "", fmt.Errorf("incomplete JOIN clause")
+		}
+
+		s := truncated[tokensUsed].s
+		tokensUsed++
+
+		return s, nil
+	}
+
+	for tokensUsed < len(truncated) { // multiple join clauses can be made, so loop
 		joinOrLoc := truncated[tokensUsed].s
+
+		if !isJoinLocation(joinOrLoc) && asKeyword(joinOrLoc) != KeywordJoin { // not a join clause, leave it for the caller
+			break
+		}
 		tokensUsed++
 
 		location := JoinLocationInner
 
 		if isJoinLocation(joinOrLoc) { // found a join clause with loc specifier
-			s := truncated[tokensUsed].s
-			tokensUsed++
+			location = asJoinLocation(joinOrLoc)
+
+			s, err := next()
+			if err != nil {
+				return nil, (tokensUsed - start), err
+			}
+
+			// LEFT OUTER JOIN is the same as LEFT JOIN
+			if strings.EqualFold(s, string(JoinLocationOuter)) && location != JoinLocationInner && !strings.EqualFold(joinOrLoc, string(JoinLocationOuter)) {
+				s, err = next()
+				if err != nil {
+					return nil, (tokensUsed - start), err
+				}
+			}
 
 			if asKeyword(s) != KeywordJoin {
 				return nil, (tokensUsed - start), fmt.Errorf("expecting JOIN after join location specifier: %s", s)
 			}
+		}
 
-			location = asJoinLocation(joinOrLoc)
-		} else if asKeyword(joinOrLoc) != KeywordJoin { // check for error scenario
-			return nil, (tokensUsed - start), fmt.Errorf("expecting JOIN keyword, instead found %s", joinOrLoc)
+		tableName, err := next()
+		if err != nil {
+			return nil, (tokensUsed - start), err
 		}
 
-		tableName := truncated[tokensUsed].s
-		tokensUsed++
+		if contains(tableNames, tableName) {
+			return nil, (tokensUsed - start), fmt.Errorf("table %s is already part of the statement", tableName)
+		}
+
+		onKeyword, err := next()
+		if err != nil {
+			return nil, (tokensUsed - start), err
+		}
 
-		onKeyword := truncated[tokensUsed].s
 		if asKeyword(onKeyword) != KeywordOn {
 			return nil, (tokensUsed - start), fmt.Errorf("expecting ON keyword, instead found %s", onKeyword)
 		}
-		tokensUsed++
+
+		if tokensUsed+3 > len(truncated) {
+			return nil, (tokensUsed - start), fmt.Errorf("incomplete JOIN clause")
+		}
 
 		e1, op, e2, err := parseEquation(truncated[tokensUsed : tokensUsed+3])
 		if err != nil {
@@ -385,6 +657,10 @@ func searchJoinClauses(truncated []token, start int, parentTable string) ([]Join
 				hasSpecifier = true
 			}
 
+			if tableFieldName != tableName && !contains(tableNames, tableFieldName) {
+				return nil, (tokensUsed - start), fmt.Errorf("table %s is not joined before table %s", tableFieldName, tableName)
+			}
+
 			_, exists := fields[tableFieldName]
 			if exists {
 				return nil, (tokensUsed - start), fmt.Errorf("cannot specify two fields from the same table in JOIN clause")
@@ -396,30 +672,172 @@ func searchJoinClauses(truncated []token, start int, parentTable string) ([]Join
 			return nil, (tokensUsed - start), fmt.Errorf("at least one field must specify table name in format of {tableName}.{fieldName}")
 		}
 
-		parentField, exists := fields[parentTable]
-		if !exists {
-			return nil, (tokensUsed - start), fmt.Errorf("at least one of the fields of ON should be from table %s", parentTable)
-		}
-
 		childField, exists := fields[tableName]
 		if !exists {
 			return nil, (tokensUsed - start), fmt.Errorf("at least one of the fields of ON should be from table %s", tableName)
 		}
 
-		whereClause, i, err := searchWhereClause(truncated, tokensUsed, tableName)
-		if err != nil {
-			return nil, (tokensUsed - start), err
+		var joinedTable, parentField string
+		for fieldTable, fieldName := range fields {
+			if fieldTable != tableName {
+				joinedTable, parentField = fieldTable, fieldName
+			}
 		}
-		tokensUsed += i
 
 		clauses = append(clauses, JoinClause{
+			ParentTable: joinedTable,
 			ParentField: parentField,
 			ChildField:  childField,
 			TableName:   tableName,
 			Location:    location,
-			Filter:      whereClause,
 		})
+		tableNames = append(tableNames, tableName)
 	}
 
 	return clauses, (tokensUsed - start), nil
 }
+
+// searchOrderByClause parses an ORDER BY clause if one begins at the start index. Each field to order by can be
+// followed by either ASC or DESC, the default being ascending. It returns the clauses in order of precedence and the
+// number of tokens used.
+func searchOrderByClause(truncated []token, start int, tableNames []string) ([]OrderByClause, int, error) {
+	if start == len(truncated) || asKeyword(truncated[start].s) != KeywordOrder {
+		return nil, 0, nil
+	}
+
+	tokensUsed := start + 1
+
+	if tokensUsed == len(truncated) || asKeyword(truncated[tokensUsed].s) != KeywordBy {
+		return nil, (tokensUsed - start), fmt.Errorf("expecting BY after ORDER")
+	}
+	tokensUsed++
+
+	var clauses []OrderByClause
+
+	for tokensUsed < len(truncated) {
+		current := truncated[tokensUsed]
+		if current.t != TokenTypeValue || isKeyword(current.s) { // end of the ORDER BY clause
+			break
+		}
+		tokensUsed++
+
+		fieldTableName, fieldName := asTableField(current.s)
+
+		// results can also be ordered by an aggregate function that was selected
+		if tokensUsed < len(truncated) && isAggregateFunction(current.s) && truncated[tokensUsed].t == TokenTypeParenthesisGroup {
+			expression, err := parseAggregate(current.s, truncated[tokensUsed].s)
+			if err != nil {
+				return nil, (tokensUsed - start), err
+			}
+			tokensUsed++
+
+			// aggregates are referred to by their name alone
+			fieldTableName, fieldName = "", expression.Name()
+		}
+
+		if !isEmptyString(fieldTableName) && !contains(tableNames, fieldTableName) {
+			return nil, (tokensUsed - start), fmt.Errorf("table %s is not part of the statement", fieldTableName)
+		}
+
+		clause := OrderByClause{TableName: fieldTableName, FieldName: fieldName}
+
+		if tokensUsed < len(truncated) {
+			switch asKeyword(truncated[tokensUsed].s) {
+			case KeywordDesc:
+				clause.Descending = true
+				tokensUsed++
+			case KeywordAsc:
+				tokensUsed++
+			}
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	if len(clauses) == 0 {
+		return nil, (tokensUsed - start), fmt.Errorf("expecting at least one field to order by")
+	}
+
+	return clauses, (tokensUsed - start), nil
+}
+
+// searchLimitClause parses a LIMIT clause, an OFFSET clause or both if they begin at the start index, LIMIT must
+// come first if both are present. It returns the limit, whether there was a limit, the offset and the number of
+// tokens used.
+func searchLimitClause(truncated []token, start int) (limit int, hasLimit bool, offset int, used int, err error) {
+	tokensUsed := start
+
+	parseCount := func(k keyword) (int, error) {
+		tokensUsed++
+
+		if tokensUsed == len(truncated) {
+			return 0, fmt.Errorf("expecting a number after %s", strings.ToUpper(string(k)))
+		}
+
+		s := truncated[tokensUsed].s
+		tokensUsed++
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s must be a non-negative integer, instead found %s", strings.ToUpper(string(k)), s)
+		}
+
+		return n, nil
+	}
+
+	if tokensUsed < len(truncated) && asKeyword(truncated[tokensUsed].s) == KeywordLimit {
+		limit, err = parseCount(KeywordLimit)
+		if err != nil {
+			return 0, false, 0, (tokensUsed - start), err
+		}
+
+		hasLimit = true
+	}
+
+	if tokensUsed < len(truncated) && asKeyword(truncated[tokensUsed].s) == KeywordOffset {
+		offset, err = parseCount(KeywordOffset)
+		if err != nil {
+			return 0, false, 0, (tokensUsed - start), err
+		}
+	}
+
+	return limit, hasLimit, offset, (tokensUsed - start), nil
+}
+
+// searchGroupByClause parses a GROUP BY clause if one begins at the start index. It returns the fields to group by
+// and the number of tokens used.
+func searchGroupByClause(truncated []token, start int, tableNames []string) ([]SelectExpression, int, error) {
+	if start == len(truncated) || asKeyword(truncated[start].s) != KeywordGroup {
+		return nil, 0, nil
+	}
+
+	tokensUsed := start + 1
+
+	if tokensUsed == len(truncated) || asKeyword(truncated[tokensUsed].s) != KeywordBy {
+		return nil, (tokensUsed - start), fmt.Errorf("expecting BY after GROUP")
+	}
+	tokensUsed++
+
+	var fields []SelectExpression
+
+	for tokensUsed < len(truncated) {
+		current := truncated[tokensUsed]
+		if current.t != TokenTypeValue || isKeyword(current.s) { // end of the GROUP BY clause
+			break
+		}
+		tokensUsed++
+
+		fieldTableName, fieldName := asTableField(current.s)
+		if !isEmptyString(fieldTableName) && !contains(tableNames, fieldTableName) {
+			return nil, (tokensUsed - start), fmt.Errorf("table %s is not part of the statement", fieldTableName)
+		}
+
+		fields = append(fields, SelectExpression{TableName: fieldTableName, FieldName: fieldName})
+	}
+
+	if len(fields) == 0 {
+		return nil, (tokensUsed - start), fmt.Errorf("expecting at least one field to group by")
+	}
+
+	return fields, (tokensUsed - start), nil
+}
@@ -0,0 +1,119 @@
+package language
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatClause returns the predicate tree as a string, with each compound clause in parenthesis after its operator.
+func formatClause(w *WhereClause) string {
+	if w == nil {
+		return ""
+	}
+
+	if w.IsCompound() {
+		clauses := make([]string, len(w.Clauses))
+		for i := range w.Clauses {
+			clauses[i] = formatClause(&w.Clauses[i])
+		}
+
+		return fmt.Sprintf("(%s %s)", w.Logic, strings.Join(clauses, " "))
+	}
+
+	if w.Operator == "is null" || w.Operator == "is not null" {
+		return fmt.Sprintf("%s %s", w.ColumnName(), w.Operator)
+	}
+
+	return fmt.Sprintf("%s%s%s", w.ColumnName(), w.Operator, w.Val)
+}
+
+func TestParseWhereClause(t *testing.T) {
+	for statement, want := range map[string]string{
+		"SELECT a FROM t WHERE a = 1":                                 "a=1",
+		"SELECT a FROM t WHERE t.a >= 1 AND b != 'x y'":               "(and t.a>=1 b!=x y)",
+		"SELECT a FROM t WHERE a = 1 AND b = 2 AND c = 3":             "(and a=1 b=2 c=3)",
+		"SELECT a FROM t WHERE a = 1 OR b = 2 AND c = 3":              "(or a=1 (and b=2 c=3))",
+		"SELECT a FROM t WHERE (a = 1 OR b = 2) AND c = 3":            "(and (or a=1 b=2) c=3)",
+		"SELECT a FROM t WHERE NOT a = 1 OR b < 2":                    "(or (not a=1) b<2)",
+		"SELECT a FROM t WHERE NOT (a = 1 OR NOT b IS NULL)":          "(not (or a=1 (not b is null)))",
+		"SELECT a FROM t WHERE a IS NOT NULL AND (b > 1 OR (c <= 2))": "(and a is not null (or b>1 c<=2))",
+		"SELECT a FROM t WHERE a='and' ORDER BY a":                    "a=and",
+		"SELECT a FROM t WHERE a = 1 LIMIT 5":                         "a=1",
+	} {
+		_, args, err := Parse(statement)
+		if err != nil {
+			t.Errorf("%s: %s", statement, err)
+			continue
+		}
+
+		if got := formatClause(args.(*SelectArgs).Filter); got != want {
+			t.Errorf("%s was parsed as %s, want %s", statement, got, want)
+		}
+	}
+
+	_, args, err := Parse("SELECT a, COUNT(*) FROM t GROUP BY a HAVING COUNT(*) > 1 AND MAX(t.b) < 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := formatClause(args.(*SelectArgs).Having), "(and count(*)>1 max(b)<5)"; got != want {
+		t.Errorf("parsed the HAVING clause as %s, want %s", got, want)
+	}
+
+	for _, statement := range []string{
+		"SELECT a FROM t WHERE",
+		"SELECT a FROM t WHERE a =",
+		"SELECT a FROM t WHERE a = 1 AND",
+		"SELECT a FROM t WHERE NOT",
+		"SELECT a FROM t WHERE a IS 1",
+		"SELECT a FROM t WHERE a ~ 1",
+		"SELECT a FROM t WHERE (a = 1 b = 2)",
+		"SELECT a FROM t WHERE other.a = 1",
+	} {
+		if _, args, err := Parse(statement); err == nil {
+			t.Errorf("%s was parsed as %s", statement, formatClause(args.(*SelectArgs).Filter))
+		}
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	_, args, err := Parse(`INSERT INTO t VALUES ('it''s', "say ""hi""", 'a\'b', '', "'", 'NULL')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"it's", `say "hi"`, "a'b", "", "'", "NULL"}
+
+	values := args.(*InsertArgs).Values
+	if len(values) != len(want) {
+		t.Fatalf("parsed %d values, want %d", len(values), len(want))
+	}
+
+	for i, val := range values {
+		if val.Val != want[i] || !val.Quoted {
+			t.Errorf("parsed value %d as %+v, want the quoted %s", i, val, want[i])
+		}
+	}
+
+	_, args, err = Parse("SELECT a FROM t WHERE name = 'it''s'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter := args.(*SelectArgs).Filter; filter.Val != "it's" || !filter.Quoted {
+		t.Errorf("parsed the value of the filter as %+v", filter.UntypedValue)
+	}
+
+	// quotes that can not be told apart from the value are an error rather than part of it
+	for _, statement := range []string{
+		"INSERT INTO t VALUES ('a'b, 1)",
+		"INSERT INTO t VALUES (a'b', 1)",
+		"INSERT INTO t VALUES ('a' 'b', 1)",
+		"SELECT a FROM t WHERE name = 'unclosed",
+	} {
+		if _, args, err := Parse(statement); err == nil {
+			t.Errorf("%s was parsed as %+v", statement, args)
+		}
+	}
+}
@@ -9,12 +9,27 @@ import (
 	"github.com/Dojo456/simple-sql-db/backend"
 )
 
-func escapeQuotedString(s string) string {
-	if s[0] == '"' && s[len(s)-1] == '"' {
-		return s[1 : len(s)-1]
+// escapeQuotedString returns the string inside of the quotes if s is quoted with either single or double quotes, read
+// in the same way as a quoted value outside of a parenthesis group, see captureQuoteGroup. Otherwise s is returned as
+// it is. It is an error if s is only partly quoted, such as 'a'b, since its quotes can not be told apart from its
+// content.
+func escapeQuotedString(s string) (string, error) {
+	if !strings.ContainsAny(s, `'"`) {
+		return s, nil
 	}
 
-	return s
+	if isQuote(rune(s[0])) {
+		group, end, err := captureQuoteGroup(s, 0)
+		if err != nil {
+			return "", fmt.Errorf("could not read quoted value %s: %w", s, err)
+		}
+
+		if end == len(s)-1 {
+			return group, nil
+		}
+	}
+
+	return "", fmt.Errorf("value %s is only partly quoted", s)
 }
 
 func isEmptyString(s string) bool {
@@ -29,8 +44,36 @@ var regexpSpaceBeforeComma = regexp.MustCompile(" ,")
 var regexpSpaceAfterComma = regexp.MustCompile(", ")
 
 // cleanString removes all newline characters and replaces it with spaces. It also removes redundant white
-// space characters, such as the ones before and after an equal sign.
+// space characters, such as the ones before and after an equal sign. Quoted strings are kept as they are.
 func cleanString(s string) string {
+	var cleaned strings.Builder
+
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		if !isQuote(rune(s[i])) {
+			continue
+		}
+
+		_, end, err := captureQuoteGroup(s, i)
+		if err != nil { // the unclosed quotes are reported once the statement is split
+			break
+		}
+
+		cleaned.WriteString(cleanUnquoted(s[start:i]))
+		cleaned.WriteString(s[i : end+1])
+
+		i = end
+		start = end + 1
+	}
+
+	cleaned.WriteString(cleanUnquoted(s[start:]))
+
+	return cleaned.String()
+}
+
+// cleanUnquoted cleans a part of a string that is not inside of quotes, see cleanString.
+func cleanUnquoted(s string) string {
 	s = strings.ReplaceAll(s, "\n", " ")
 	s = strings.ReplaceAll(s, "  ", " ")
 
@@ -43,17 +86,36 @@ func cleanString(s string) string {
 	return s
 }
 
-// parseField takes in a string that is the name and data type of the field separated by a space
+var regexpVarchar = regexp.MustCompile(`^varchar\((\d+)\)$`)
+
+// parseField takes in a string that is the name and data type of the field separated by a space. Besides the
+// primitive types, a string field can be declared as text, which is the same as string, or as varchar(n), which
+// limits it to n characters.
 //
-// i.e. "name string"
+// i.e. "name string" or "name varchar(64)"
 func parseField(s string) (backend.Field, error) {
 	tokens := strings.Split(s, " ")
-	if len(tokens) != 2 {
+	if len(tokens) < 2 {
 		return backend.Field{}, fmt.Errorf("%s is not acceptable", s)
 	}
 
 	name := tokens[0]
-	dataType := backend.Primitive(strings.ToLower(tokens[1]))
+	declaration := strings.ToLower(strings.Join(tokens[1:], ""))
+
+	if declaration == "text" {
+		return backend.Field{Name: name, Type: backend.PrimitiveString}, nil
+	}
+
+	if matches := regexpVarchar.FindStringSubmatch(declaration); matches != nil {
+		maxLength, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil || maxLength == 0 {
+			return backend.Field{}, fmt.Errorf("%s is not a valid length for varchar", matches[1])
+		}
+
+		return backend.Field{Name: name, Type: backend.PrimitiveString, MaxLength: maxLength}, nil
+	}
+
+	dataType := backend.Primitive(declaration)
 
 	if !dataType.IsValid() {
 		return backend.Field{}, fmt.Errorf("%s is not a valid data type", dataType)
@@ -66,10 +128,10 @@ func parseField(s string) (backend.Field, error) {
 }
 
 func stripTableNameFromField(fieldName string, tableName string) string {
-	tokens := strings.Split(fieldName, ".")
+	fieldTableName, name := asTableField(fieldName)
 
-	if len(tokens) == 2 && tokens[0] == tableName { // field name is {tableName}.{fieldName}
-		return tokens[1]
+	if fieldTableName != "" && fieldTableName == tableName { // field name is {tableName}.{fieldName}
+		return name
 	}
 
 	return fieldName
@@ -77,33 +139,24 @@ func stripTableNameFromField(fieldName string, tableName string) string {
 
 // asTableField can be used to separate a single tableField in the format of {tableName}.{fieldName}.
 // It is a convenient function as this function is used often. If tableName is not specified, then it
-// will be blank.
+// will be blank. The field name is after the last dot, since the names of system tables such as
+// information_schema.tables have a dot in them.
 func asTableField(s string) (tableName string, fieldName string) {
-	tokens := strings.Split(s, ".")
-
-	if len(tokens) == 2 {
-		return tokens[0], tokens[1]
-
+	i := strings.LastIndex(s, ".")
+	if i == -1 {
+		return "", s
 	}
 
-	return "", tokens[0]
+	return s[:i], s[i+1:]
 }
 
 func asTableFields(fieldNames []string, tableNames []string) (map[string]TableFields, error) {
-	fTokens := make([][]string, len(fieldNames))
-	for i, name := range fieldNames {
-		fTokens[i] = strings.Split(name, ".")
-	}
-
 	tableToFields := map[string][]string{}
 
-	for _, tokens := range fTokens {
-		tableName := tableNames[0]
-		fieldName := tokens[0]
-
-		if len(tokens) == 2 {
-			tableName = tokens[0]
-			fieldName = tokens[1]
+	for _, name := range fieldNames {
+		tableName, fieldName := asTableField(name)
+		if tableName == "" {
+			tableName = tableNames[0]
 		}
 
 		tableToFields[tableName] = append(tableToFields[tableName], fieldName)
@@ -120,10 +173,38 @@ func asTableFields(fieldNames []string, tableNames []string) (map[string]TableFi
 	return tableFields, nil
 }
 
+// parseAggregate parses an aggregate function call, given the name of the function and the contents of the
+// parenthesis group after it.
+func parseAggregate(function string, group string) (SelectExpression, error) {
+	aggregate := asAggregateFunction(function)
+	arg := strings.TrimSpace(group)
+
+	if isEmptyString(arg) || strings.ContainsAny(arg, " ,") {
+		return SelectExpression{}, fmt.Errorf("%s takes a single field", strings.ToUpper(string(aggregate)))
+	}
+
+	if arg == "*" && aggregate != AggregateCount {
+		return SelectExpression{}, fmt.Errorf("only COUNT can be used with *")
+	}
+
+	tableName, fieldName := asTableField(arg)
+
+	return SelectExpression{TableName: tableName, FieldName: fieldName, Aggregate: aggregate}, nil
+}
+
+// aggregateName is the name an aggregate function of a field is referred to by, such as "count(*)".
+func aggregateName(aggregate AggregateFunction, fieldName string) string {
+	return fmt.Sprintf("%s(%s)", aggregate, fieldName)
+}
+
 // NewValueForField creates a Value for the Field. This is the preferred way to create a Value struct. If the val is
 // of the correct Go type for that field, it will be entered directly. If it is of string type and the field is not,
-// it will attempt to parse the value into the correct type.
+// it will attempt to parse the value into the correct type. A val of nil or the unquoted string NULL is NULL.
 func NewValueForField(field backend.Field, val interface{}) (backend.Value, error) {
+	if s, ok := val.(string); val == nil || ok && asKeyword(s) == KeywordNull {
+		return backend.Value{Type: field.Type, FieldName: field.Name}, nil
+	}
+
 	switch field.Type {
 	case backend.PrimitiveString:
 		{
@@ -132,9 +213,14 @@ func NewValueForField(field backend.Field, val interface{}) (backend.Value, erro
 				return backend.Value{}, fmt.Errorf("must be a string")
 			}
 
+			escaped, err := escapeQuotedString(s)
+			if err != nil {
+				return backend.Value{}, err
+			}
+
 			return backend.Value{
 				Type:      backend.PrimitiveString,
-				Val:       escapeQuotedString(s),
+				Val:       escaped,
 				FieldName: field.Name,
 			}, nil
 		}
@@ -183,7 +269,39 @@ func NewValueForField(field backend.Field, val interface{}) (backend.Value, erro
 				FieldName: field.Name,
 			}, nil
 		}
+	case backend.PrimitiveBool:
+		{
+			b, ok := val.(bool)
+			if !ok {
+				s, ok := val.(string)
+				if !ok {
+					return backend.Value{}, fmt.Errorf("could not parse bool")
+				}
+
+				sB, err := strconv.ParseBool(s)
+				if err != nil {
+					return backend.Value{}, fmt.Errorf("could not parse bool")
+				}
+
+				b = sB
+			}
+			return backend.Value{
+				Type:      backend.PrimitiveBool,
+				Val:       b,
+				FieldName: field.Name,
+			}, nil
+		}
 	}
 
 	return backend.Value{}, nil
 }
+
+func contains[T comparable](slice []T, element T) bool {
+	for _, t := range slice {
+		if t == element {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,27 +2,112 @@ package engine
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/Dojo456/simple-sql-db/backend"
 	"github.com/Dojo456/simple-sql-db/engine/language"
 )
 
-func filterFromWhereClause(whereClause *language.WhereClause, table backend.OperableTable) (*backend.Filter, error) {
+// fieldFinder looks up the fields that the values of a filter are parsed for. backend.OperableTable is one.
+type fieldFinder interface {
+	FieldWithName(fieldName string) (backend.Field, error)
+}
+
+// fieldList is a fieldFinder over the columns of rows that are not read directly from a table, such as joined rows
+// or the result of an aggregation. Columns of rows read from a table are named in the format of
+// {tableName}.{fieldName}, see qualifiedFields.
+type fieldList []backend.Field
+
+func (l fieldList) FieldWithName(fieldName string) (backend.Field, error) {
+	index, err := l.indexOf(fieldName)
+	if err != nil {
+		return backend.Field{}, err
+	}
+
+	return l[index], nil
+}
+
+// indexOf returns the position of the column with the given name. A field name that is not qualified with a table
+// matches the field of any table, as long as only one table has a field with that name.
+func (l fieldList) indexOf(fieldName string) (int, error) {
+	for i, field := range l {
+		if field.Name == fieldName {
+			return i, nil
+		}
+	}
+
+	index := -1
+
+	if !strings.Contains(fieldName, ".") {
+		for i, field := range l {
+			if strings.HasSuffix(field.Name, "."+fieldName) {
+				if index != -1 {
+					return -1, fmt.Errorf("field \"%s\" is ambiguous, specify its table in the format of {tableName}.{fieldName}", fieldName)
+				}
+
+				index = i
+			}
+		}
+	}
+
+	if index == -1 {
+		return -1, fmt.Errorf("field \"%s\" does not exist", fieldName)
+	}
+
+	return index, nil
+}
+
+// tableFinder is a fieldFinder over the fields of a single table that also accepts field names qualified with the
+// name of the table.
+type tableFinder struct {
+	backend.OperableTable
+}
+
+func (t tableFinder) FieldWithName(fieldName string) (backend.Field, error) {
+	return t.OperableTable.FieldWithName(strings.TrimPrefix(fieldName, t.GetName()+"."))
+}
+
+// filterFromWhereClause converts the predicate tree of a WHERE clause into a backend.Filter tree, parsing each value
+// into the type of the field it is compared against.
+func filterFromWhereClause(whereClause *language.WhereClause, table fieldFinder) (*backend.Filter, error) {
 	if whereClause == nil {
 		return nil, nil
 	}
 
+	if whereClause.IsCompound() {
+		children := make([]backend.Filter, len(whereClause.Clauses))
+
+		for i := range whereClause.Clauses {
+			child, err := filterFromWhereClause(&whereClause.Clauses[i], table)
+			if err != nil {
+				return nil, err
+			}
+
+			children[i] = *child
+		}
+
+		return &backend.Filter{
+			Logic:   whereClause.Logic,
+			Filters: children,
+		}, nil
+	}
+
 	var filter *backend.Filter
 
-	field, err := table.FieldWithName(whereClause.FieldName)
+	field, err := table.FieldWithName(whereClause.ColumnName())
 	if err != nil {
 		return nil, err
 	}
 
-	// value name is fifth argument
-	value, err := language.NewValueForField(field, whereClause.UntypedValue.Val)
-	if err != nil {
-		return nil, err
+	// IS NULL and IS NOT NULL do not compare against a value
+	value := backend.Value{Type: field.Type, FieldName: field.Name}
+
+	if whereClause.Operator != backend.OperatorIsNull && whereClause.Operator != backend.OperatorIsNotNull {
+		value, err = whereClause.ValueForField(field)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	filter = &backend.Filter{
@@ -34,22 +119,159 @@ func filterFromWhereClause(whereClause *language.WhereClause, table backend.Oper
 	return filter, nil
 }
 
-func selectRows(ctx context.Context, t backend.OperableTable, fieldsToSelect []string, whereClause *language.WhereClause, filters []backend.Filter) ([]backend.Row, error) {
-	filter, err := filterFromWhereClause(whereClause, t)
+// scanTable reads every row of the table that satisfies the WHERE clause, stopping once limit rows have matched. The
+// values of the rows are named in the format of {tableName}.{fieldName}, as are the returned columns.
+func scanTable(ctx context.Context, t backend.OperableTable, whereClause *language.WhereClause, limit int) ([]backend.Row, []backend.Field, error) {
+	it, columns, err := streamTable(ctx, t, whereClause, limit)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	rows, err := collectRows(it)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rows, columns, nil
+}
+
+// streamTable is like scanTable, except that the rows are read from the table as they are iterated over.
+func streamTable(ctx context.Context, t backend.OperableTable, whereClause *language.WhereClause, limit int) (rowIterator, []backend.Field, error) {
+	filter, err := filterFromWhereClause(whereClause, tableFinder{t})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var filters []backend.Filter
 	if filter != nil {
 		filters = append(filters, *filter)
 	}
 
-	rows, err := t.GetRows(ctx, fieldsToSelect, filters)
+	it, err := t.ScanRows(ctx, nil, filters, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := qualifiedFields(t)
+
+	return &renameIterator{RowIterator: it, columns: columns}, columns, nil
+}
+
+// renameIterator names the values of the rows of a table after the columns, in the order of the fields.
+type renameIterator struct {
+	backend.RowIterator
+	columns []backend.Field
+}
+
+func (r *renameIterator) Row() backend.Row {
+	row := r.RowIterator.Row()
+	for i := range row.Values {
+		row.Values[i].FieldName = r.columns[i].Name
+	}
+
+	return row
+}
+
+// qualifiedFields returns the fields of the table with their names in the format of {tableName}.{fieldName}.
+func qualifiedFields(t backend.OperableTable) []backend.Field {
+	fields := t.GetFields()
+	qualified := make([]backend.Field, len(fields))
+
+	for i, field := range fields {
+		qualified[i] = field
+		qualified[i].Name = qualifiedName(t.GetName(), field.Name)
+	}
+
+	return qualified
+}
+
+// qualifiedName returns the field name in the format of {tableName}.{fieldName}, or just the field name if there is
+// no table name.
+func qualifiedName(tableName string, fieldName string) string {
+	if tableName == "" {
+		return fieldName
+	}
+
+	return tableName + "." + fieldName
+}
+
+// conjuncts splits a WHERE clause into the clauses that must all be true for it to be true.
+func conjuncts(whereClause *language.WhereClause) []language.WhereClause {
+	if whereClause == nil {
+		return nil
+	}
+
+	if whereClause.Logic == backend.LogicalAnd {
+		return whereClause.Clauses
+	}
+
+	return []language.WhereClause{*whereClause}
+}
+
+// conjunction combines clauses that must all be true into a single WHERE clause. It returns nil if there are none.
+func conjunction(clauses []language.WhereClause) *language.WhereClause {
+	switch len(clauses) {
+	case 0:
+		return nil
+	case 1:
+		return &clauses[0]
+	}
+
+	return &language.WhereClause{Logic: backend.LogicalAnd, Clauses: clauses}
+}
+
+// clauseTables appends the name of every table with a field compared by the WHERE clause to tableNames. The columns
+// are used to find the table of fields that are not qualified with one.
+func clauseTables(whereClause *language.WhereClause, columns fieldList, tableNames []string) ([]string, error) {
+	if whereClause.IsCompound() {
+		var err error
+
+		for i := range whereClause.Clauses {
+			tableNames, err = clauseTables(&whereClause.Clauses[i], columns, tableNames)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return tableNames, nil
+	}
+
+	field, err := columns.FieldWithName(whereClause.ColumnName())
 	if err != nil {
 		return nil, err
 	}
 
-	return rows, nil
+	// the field name is after the last dot, as the names of system tables have a dot in them
+	tableName := field.Name
+	if i := strings.LastIndex(field.Name, "."); i != -1 {
+		tableName = field.Name[:i]
+	}
+
+	if !contains(tableNames, tableName) {
+		tableNames = append(tableNames, tableName)
+	}
+
+	return tableNames, nil
+}
+
+// sortKeysForOrderBy resolves each field of an ORDER BY clause to its position in rows with the given columns.
+func sortKeysForOrderBy(columns []backend.Field, orderBy []language.OrderByClause) ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(orderBy))
+
+	for _, clause := range orderBy {
+		index, err := fieldList(columns).indexOf(qualifiedName(clause.TableName, clause.FieldName))
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, sortKey{
+			index:      index,
+			primitive:  columns[index].Type,
+			descending: clause.Descending,
+		})
+	}
+
+	return keys, nil
 }
 
 func contains[T comparable](slice []T, element T) bool {
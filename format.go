@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// formatResult formats the result of a statement as text to be printed. Selected rows are formatted as a table with
+// a column for each selected field.
+func formatResult(r *engine.ResultSet) string {
+	switch r.Command {
+	case language.SelectCommand:
+		return formatRows(r.Columns, r.Rows)
+	case language.InsertCommand:
+		return formatCount(r.RowsAffected, "row", "inserted")
+	case language.UpdateCommand:
+		return formatCount(r.RowsAffected, "row", "updated")
+	case language.DeleteCommand:
+		return formatCount(r.RowsAffected, "row", "deleted")
+	case language.VacuumCommand:
+		return formatCount(r.RowsAffected, "row version", "reclaimed")
+	case language.CreateTableCommand:
+		fields := make([]string, len(r.Table.Fields))
+		for i, field := range r.Table.Fields {
+			fields[i] = fmt.Sprintf("%s %s", field.Name, field.Type)
+			if field.MaxLength > 0 {
+				fields[i] = fmt.Sprintf("%s varchar(%d)", field.Name, field.MaxLength)
+			}
+		}
+
+		return fmt.Sprintf("created table %s (%s)", r.Table.Name, strings.Join(fields, ", "))
+	case language.CreateIndexCommand:
+		return fmt.Sprintf("created index %s on %s", r.Index.Name, r.Index.FieldName)
+	case language.DropIndexCommand:
+		return "dropped index"
+	case language.DropTableCommand:
+		return "dropped table"
+	case language.TruncateTableCommand:
+		return "truncated table"
+	case language.AlterTableCommand:
+		return fmt.Sprintf("altered table %s", r.Table.Name)
+	case language.CreateDatabaseCommand:
+		return "created database"
+	case language.DropDatabaseCommand:
+		return "dropped database"
+	case language.UseCommand:
+		return "changed database"
+	case language.BeginCommand:
+		return "began transaction"
+	case language.CommitCommand:
+		return "committed transaction"
+	case language.RollbackCommand:
+		return "rolled back"
+	case language.SavepointCommand:
+		return "created savepoint"
+	case language.ReleaseSavepointCommand:
+		return "released savepoint"
+	}
+
+	return ""
+}
+
+// formatRows formats the rows as a table, with the names of the columns in the first line.
+func formatRows(columns []engine.Column, rows [][]interface{}) string {
+	cells := make([][]string, len(rows)+1)
+	widths := make([]int, len(columns))
+
+	cells[0] = make([]string, len(columns))
+	for i, column := range columns {
+		cells[0][i] = column.Name
+	}
+
+	for i, row := range rows {
+		cells[i+1] = make([]string, len(row))
+		for j, val := range row {
+			cells[i+1][j] = formatValue(val)
+		}
+	}
+
+	for _, row := range cells {
+		for j, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	for i, row := range cells {
+		for j, cell := range row {
+			if j != 0 {
+				b.WriteString(" | ")
+			}
+
+			b.WriteString(cell)
+
+			if j != len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+		}
+
+		b.WriteString("\n")
+
+		if i == 0 {
+			for j, width := range widths {
+				if j != 0 {
+					b.WriteString("-+-")
+				}
+
+				b.WriteString(strings.Repeat("-", width))
+			}
+
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString(formatCount(len(rows), "row", "selected"))
+
+	return b.String()
+}
+
+// formatValue formats a value of a row, which is NULL if it is nil.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return fmt.Sprint(val)
+}
+
+func formatCount(n int, noun string, verb string) string {
+	if n != 1 {
+		noun += "s"
+	}
+
+	return fmt.Sprintf("%d %s %s", n, noun, verb)
+}
@@ -12,6 +12,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
 	ctx := context.Background()
 
 	scanner := bufio.NewScanner(os.Stdin)
@@ -22,7 +27,7 @@ func main() {
 
 	defer cleanup(sqlEngine)
 	go func() {
-		sigchan := make(chan os.Signal)
+		sigchan := make(chan os.Signal, 1)
 		signal.Notify(sigchan, os.Interrupt)
 		<-sigchan
 	}()
@@ -38,13 +43,13 @@ func main() {
 		}
 		input := scanner.Text()
 
-		cmd, err := sqlEngine.Process(ctx, input)
+		result, err := sqlEngine.Process(ctx, input)
 		if err != nil {
 			fmt.Println(fmt.Errorf("\nerror executing command: %w", err))
 			continue
 		}
 
-		fmt.Printf("\n%v\n", cmd)
+		fmt.Printf("\n%s\n", formatResult(result))
 	}
 }
 
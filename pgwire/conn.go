@@ -0,0 +1,801 @@
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// SQLSTATE codes of the errors that are sent to clients.
+const (
+	codeSyntaxError          = "42601"
+	codeUndefinedTable       = "42P01"
+	codeUniqueViolation      = "23505"
+	codeSerializationFailure = "40001"
+	codeInFailedTransaction  = "25P02"
+	codeQueryCanceled        = "57014"
+	codeProtocolViolation    = "08P01"
+	codeInvalidStatementName = "26000"
+	codeInvalidCursorName    = "34000"
+	codeDuplicateStatement   = "42P05"
+	codeDuplicateCursor      = "42P03"
+	codeFeatureNotSupported  = "0A000"
+	codeInternalError        = "XX000"
+)
+
+// pgError is an error with the SQLSTATE code it is sent to clients with.
+type pgError struct {
+	code string
+	err  error
+}
+
+func (e *pgError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pgError) Unwrap() error {
+	return e.err
+}
+
+func newError(code string, format string, a ...interface{}) error {
+	return &pgError{code: code, err: fmt.Errorf(format, a...)}
+}
+
+// errorCode returns the SQLSTATE code of the error.
+func errorCode(err error) string {
+	var pgErr *pgError
+
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.code
+	case errors.Is(err, backend.ErrTableNotExist):
+		return codeUndefinedTable
+	case errors.Is(err, backend.ErrUniqueViolation):
+		return codeUniqueViolation
+	case errors.Is(err, backend.ErrSerializationFailure):
+		return codeSerializationFailure
+	case errors.Is(err, engine.ErrTransactionFailed):
+		return codeInFailedTransaction
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return codeQueryCanceled
+	}
+
+	return codeInternalError
+}
+
+// errTerminate is returned once the client asks to close the connection.
+var errTerminate = errors.New("connection terminated")
+
+// conn is a connection of a client, whose statements run in a session of the engine.
+//
+// stmts are the statements that the client prepared with Parse and portals the statements that it bound arguments to
+// with Bind, both by name, where the empty name is the unnamed statement or portal. The cursor of a portal of a SELECT
+// statement is opened once it is first executed, and the rows are sent from it as they are requested. Once a message
+// of the extended query protocol fails, every message is ignored until the next Sync.
+type conn struct {
+	server   *Server
+	netConn  net.Conn
+	rd       *bufio.Reader
+	wr       *bufio.Writer
+	msg      messageWriter
+	session  *engine.Session
+	stmts    map[string]*preparedStatement
+	portals  map[string]*portal
+	ignoring bool
+}
+
+// preparedStatement is a statement prepared with Parse. paramOIDs are the types of its parameters.
+type preparedStatement struct {
+	st        *engine.Stmt
+	paramOIDs []uint32
+}
+
+// portal is a prepared statement with the arguments of its parameters, and the formats of the columns it returns.
+// tag is the tag of its command once it has been run, if it is not a SELECT statement.
+type portal struct {
+	stmt    *preparedStatement
+	params  []interface{}
+	formats []int16
+	cursor  *engine.Cursor
+	tag     string
+}
+
+// close closes the cursor of the portal, if it has one.
+func (p *portal) close() {
+	if p.cursor != nil {
+		_ = p.cursor.Close()
+		p.cursor = nil
+	}
+}
+
+func newConn(s *Server, netConn net.Conn) *conn {
+	return &conn{
+		server:  s,
+		netConn: netConn,
+		rd:      bufio.NewReader(netConn),
+		wr:      bufio.NewWriter(netConn),
+		session: s.engine.NewSession(),
+		stmts:   map[string]*preparedStatement{},
+		portals: map[string]*portal{},
+	}
+}
+
+// serve runs the statements of the client until it terminates the connection or the connection is closed.
+func (c *conn) serve() error {
+	defer c.close()
+
+	ok, err := c.startup()
+	if err != nil || !ok {
+		return ignoreClosed(err)
+	}
+
+	for {
+		t, body, err := readMessage(c.rd)
+		if err != nil {
+			return ignoreClosed(err)
+		}
+
+		if c.ignoring && t != msgSync && t != msgTerminate {
+			continue
+		}
+
+		err = c.handle(t, messageReader{b: body})
+		if errors.Is(err, errTerminate) {
+			return nil
+		}
+
+		if err != nil {
+			return ignoreClosed(err)
+		}
+	}
+}
+
+// ignoreClosed returns nil if the error is because the connection was closed.
+func ignoreClosed(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// close closes the portals and the session, rolling back the transaction of the client, and then the connection.
+func (c *conn) close() {
+	c.closePortals()
+	_ = c.session.Close()
+	_ = c.netConn.Close()
+}
+
+// startup reads the startup packet of the client and accepts it. Encryption is refused, and the client can then
+// try again without it. It returns false if the client only wanted to cancel a query, which is not supported.
+func (c *conn) startup() (bool, error) {
+	var params map[string]string
+
+	for params == nil {
+		body, err := readStartupPacket(c.rd)
+		if err != nil {
+			return false, err
+		}
+
+		r := messageReader{b: body}
+
+		switch code := r.int32(); code {
+		case sslRequestCode, gssEncRequestCode:
+			_, err = c.netConn.Write([]byte{'N'})
+			if err != nil {
+				return false, err
+			}
+
+			continue
+		case cancelRequestCode:
+			return false, nil
+		case protocolVersion3:
+		default:
+			c.sendError(newError(codeProtocolViolation, "unsupported protocol version %d.%d", code>>16, code&0xffff))
+			return false, c.wr.Flush()
+		}
+
+		params = map[string]string{}
+
+		for {
+			key := r.string()
+			if key == "" {
+				break
+			}
+
+			params[key] = r.string()
+		}
+
+		if r.err != nil {
+			return false, fmt.Errorf("invalid startup packet: %w", r.err)
+		}
+	}
+
+	c.msg.start(msgAuthentication)
+	c.msg.int32(0)
+	c.send()
+
+	for _, status := range [][2]string{
+		{"server_version", "14.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+		{"application_name", params["application_name"]},
+	} {
+		c.msg.start(msgParameterStatus)
+		c.msg.string(status[0])
+		c.msg.string(status[1])
+		c.send()
+	}
+
+	// queries can not be cancelled, so the key is never checked
+	c.msg.start(msgBackendKeyData)
+	c.msg.int32(rand.Int31())
+	c.msg.int32(rand.Int31())
+	c.send()
+
+	c.readyForQuery()
+
+	return true, c.wr.Flush()
+}
+
+// handle handles a message of the client. Errors of statements are sent to the client, and the error that is returned
+// ends the connection.
+func (c *conn) handle(t byte, r messageReader) error {
+	var err error
+
+	switch t {
+	case msgQuery:
+		query := r.string()
+		if r.err != nil {
+			return r.err
+		}
+
+		return c.simpleQuery(query)
+	case msgParse:
+		err = c.parse(&r)
+	case msgBind:
+		err = c.bind(&r)
+	case msgDescribe:
+		err = c.describe(&r)
+	case msgExecute:
+		err = c.execute(&r)
+	case msgClose:
+		err = c.closeMessage(&r)
+	case msgSync:
+		c.ignoring = false
+
+		// without a transaction every statement has already been committed, so its portals can not be used anymore
+		if !c.session.InTransaction() {
+			c.closePortals()
+		}
+
+		c.readyForQuery()
+
+		return c.wr.Flush()
+	case msgFlush:
+		return c.wr.Flush()
+	case msgTerminate:
+		return errTerminate
+	default:
+		c.sendError(newError(codeProtocolViolation, "unsupported message type %q", t))
+		_ = c.wr.Flush()
+
+		return fmt.Errorf("unsupported message type %q", t)
+	}
+
+	if r.err != nil {
+		err = newError(codeProtocolViolation, "%s", r.err)
+	}
+
+	if err != nil {
+		c.sendError(err)
+		c.ignoring = true
+	}
+
+	return nil
+}
+
+// simpleQuery runs every statement of the query one after the other, stopping at the first one that fails, and sends
+// their rows and results. The unnamed statement and portal are closed first.
+func (c *conn) simpleQuery(query string) error {
+	delete(c.stmts, "")
+	c.closePortal("")
+
+	statements := splitStatements(query)
+	if len(statements) == 0 {
+		c.msg.start(msgEmptyQueryResponse)
+		c.send()
+	}
+
+	for _, statement := range statements {
+		err := c.runStatement(statement)
+		if err != nil {
+			c.sendError(err)
+			break
+		}
+	}
+
+	c.readyForQuery()
+
+	return c.wr.Flush()
+}
+
+// runStatement runs a statement of a simple query and sends its rows and result.
+func (c *conn) runStatement(statement string) error {
+	st, err := c.session.Prepare(c.server.ctx, statement)
+	if err != nil {
+		return &pgError{code: codeSyntaxError, err: err}
+	}
+
+	if st.Command() != language.SelectCommand {
+		tag, err := c.exec(st, nil)
+		if err != nil {
+			return err
+		}
+
+		c.sendCommandComplete(tag)
+
+		return nil
+	}
+
+	cursor, err := st.Query(c.server.ctx)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	c.sendRowDescription(cursor.Columns, nil)
+
+	n, _, err := c.sendRows(cursor, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	c.sendCommandComplete(fmt.Sprintf("SELECT %d", n))
+
+	return nil
+}
+
+// exec runs a statement that is not a SELECT statement and returns the tag of its command. The portals are closed
+// before a transaction ends, since their cursors read from it.
+func (c *conn) exec(st *engine.Stmt, params []interface{}) (string, error) {
+	if cmd := st.Command(); cmd == language.CommitCommand || cmd == language.RollbackCommand {
+		c.closePortals()
+	}
+
+	result, err := st.Exec(c.server.ctx, params...)
+	if err != nil {
+		return "", err
+	}
+
+	return commandTag(result), nil
+}
+
+// commandTag returns the tag of the CommandComplete message of the result.
+func commandTag(result *engine.ResultSet) string {
+	switch result.Command {
+	case language.InsertCommand:
+		return fmt.Sprintf("INSERT 0 %d", result.RowsAffected)
+	case language.UpdateCommand:
+		return fmt.Sprintf("UPDATE %d", result.RowsAffected)
+	case language.DeleteCommand:
+		return fmt.Sprintf("DELETE %d", result.RowsAffected)
+	case language.SelectCommand:
+		return fmt.Sprintf("SELECT %d", len(result.Rows))
+	case language.ReleaseSavepointCommand:
+		return "RELEASE"
+	}
+
+	return result.Command.String()
+}
+
+// parse prepares the statement of a Parse message. The types of parameters that are not given are text.
+func (c *conn) parse(r *messageReader) error {
+	name := r.string()
+	query := r.string()
+
+	oids := make([]uint32, r.count())
+	for i := range oids {
+		oids[i] = uint32(r.int32())
+	}
+
+	if r.err != nil {
+		return nil
+	}
+
+	if _, exists := c.stmts[name]; exists && name != "" {
+		return newError(codeDuplicateStatement, "prepared statement %q already exists", name)
+	}
+
+	st, err := c.session.Prepare(c.server.ctx, query)
+	if err != nil {
+		return &pgError{code: codeSyntaxError, err: err}
+	}
+
+	paramOIDs := make([]uint32, st.NumParams)
+	for i := range paramOIDs {
+		paramOIDs[i] = oidText
+		if i < len(oids) && oids[i] != oidUnspecified {
+			paramOIDs[i] = oids[i]
+		}
+	}
+
+	c.stmts[name] = &preparedStatement{st: st, paramOIDs: paramOIDs}
+
+	c.msg.start(msgParseComplete)
+	c.send()
+
+	return nil
+}
+
+// bind creates the portal of a Bind message, decoding its arguments from their formats.
+func (c *conn) bind(r *messageReader) error {
+	portalName := r.string()
+	stmtName := r.string()
+
+	paramFormats := make([]int16, r.count())
+	for i := range paramFormats {
+		paramFormats[i] = r.int16()
+	}
+
+	values := make([][]byte, r.count())
+	for i := range values {
+		// a length of -1 is a NULL
+		if n := r.int32(); n != -1 {
+			values[i] = r.bytes(int(n))
+		}
+	}
+
+	resultFormats := make([]int16, r.count())
+	for i := range resultFormats {
+		resultFormats[i] = r.int16()
+	}
+
+	if r.err != nil {
+		return newError(codeProtocolViolation, "%s", r.err)
+	}
+
+	stmt, ok := c.stmts[stmtName]
+	if !ok {
+		return newError(codeInvalidStatementName, "prepared statement %q does not exist", stmtName)
+	}
+
+	if _, exists := c.portals[portalName]; exists && portalName != "" {
+		return newError(codeDuplicateCursor, "portal %q already exists", portalName)
+	}
+
+	if len(values) != len(stmt.paramOIDs) {
+		return newError(codeProtocolViolation, "expecting %d parameters, got %d", len(stmt.paramOIDs), len(values))
+	}
+
+	if len(paramFormats) > 1 && len(paramFormats) != len(values) {
+		return newError(codeProtocolViolation, "expecting %d parameter formats, got %d", len(values), len(paramFormats))
+	}
+
+	params := make([]interface{}, len(values))
+	for i, val := range values {
+		param, err := decodeParam(val, stmt.paramOIDs[i], formatFor(paramFormats, i))
+		if err != nil {
+			return newError(codeProtocolViolation, "parameter $%d: %s", i+1, err)
+		}
+
+		params[i] = param
+	}
+
+	c.closePortal(portalName)
+	c.portals[portalName] = &portal{stmt: stmt, params: params, formats: resultFormats}
+
+	c.msg.start(msgBindComplete)
+	c.send()
+
+	return nil
+}
+
+// describe sends the types of the parameters and the columns of a statement, or the columns of a portal in the
+// formats they are sent in.
+func (c *conn) describe(r *messageReader) error {
+	kind := r.byte()
+	name := r.string()
+
+	if r.err != nil {
+		return nil
+	}
+
+	var st *engine.Stmt
+	var formats []int16
+
+	switch kind {
+	case 'S':
+		stmt, ok := c.stmts[name]
+		if !ok {
+			return newError(codeInvalidStatementName, "prepared statement %q does not exist", name)
+		}
+
+		c.msg.start(msgParameterDescription)
+		c.msg.int16(int16(len(stmt.paramOIDs)))
+		for _, oid := range stmt.paramOIDs {
+			c.msg.int32(int32(oid))
+		}
+		c.send()
+
+		st = stmt.st
+	case 'P':
+		p, ok := c.portals[name]
+		if !ok {
+			return newError(codeInvalidCursorName, "portal %q does not exist", name)
+		}
+
+		st, formats = p.stmt.st, p.formats
+	default:
+		return newError(codeProtocolViolation, "invalid Describe kind %q", kind)
+	}
+
+	columns, err := st.Columns(c.server.ctx)
+	if err != nil {
+		return err
+	}
+
+	if st.Command() != language.SelectCommand {
+		c.msg.start(msgNoData)
+		c.send()
+
+		return nil
+	}
+
+	if len(formats) > 1 && len(formats) != len(columns) {
+		return newError(codeProtocolViolation, "expecting %d result formats, got %d", len(columns), len(formats))
+	}
+
+	c.sendRowDescription(columns, formats)
+
+	return nil
+}
+
+// execute runs a portal. The rows of a SELECT statement are sent from its cursor, at most maxRows at a time unless it
+// is 0, and the portal is suspended until it is executed again if there may be more.
+func (c *conn) execute(r *messageReader) error {
+	name := r.string()
+	maxRows := r.int32()
+
+	if r.err != nil {
+		return nil
+	}
+
+	p, ok := c.portals[name]
+	if !ok {
+		return newError(codeInvalidCursorName, "portal %q does not exist", name)
+	}
+
+	st := p.stmt.st
+
+	if st.Command() != language.SelectCommand {
+		if p.tag == "" {
+			tag, err := c.exec(st, p.params)
+			if err != nil {
+				return err
+			}
+
+			p.tag = tag
+		}
+
+		c.sendCommandComplete(p.tag)
+
+		return nil
+	}
+
+	if p.cursor == nil {
+		cursor, err := st.Query(c.server.ctx, p.params...)
+		if err != nil {
+			return err
+		}
+
+		if len(p.formats) > 1 && len(p.formats) != len(cursor.Columns) {
+			cursor.Close()
+			return newError(codeProtocolViolation, "expecting %d result formats, got %d", len(cursor.Columns), len(p.formats))
+		}
+
+		p.cursor = cursor
+	}
+
+	n, more, err := c.sendRows(p.cursor, p.formats, int(maxRows))
+	if err != nil {
+		p.close()
+		return err
+	}
+
+	if more {
+		c.msg.start(msgPortalSuspended)
+		c.send()
+
+		return nil
+	}
+
+	p.close()
+	c.sendCommandComplete(fmt.Sprintf("SELECT %d", n))
+
+	return nil
+}
+
+// closeMessage closes the statement or portal of a Close message. Closing one that does not exist does nothing.
+func (c *conn) closeMessage(r *messageReader) error {
+	kind := r.byte()
+	name := r.string()
+
+	if r.err != nil {
+		return nil
+	}
+
+	switch kind {
+	case 'S':
+		delete(c.stmts, name)
+	case 'P':
+		c.closePortal(name)
+	default:
+		return newError(codeProtocolViolation, "invalid Close kind %q", kind)
+	}
+
+	c.msg.start(msgCloseComplete)
+	c.send()
+
+	return nil
+}
+
+func (c *conn) closePortal(name string) {
+	if p, ok := c.portals[name]; ok {
+		p.close()
+		delete(c.portals, name)
+	}
+}
+
+func (c *conn) closePortals() {
+	for name := range c.portals {
+		c.closePortal(name)
+	}
+}
+
+// send writes the message that was built with msg.
+func (c *conn) send() {
+	// errors are returned by the next flush
+	_, _ = c.wr.Write(c.msg.finish())
+}
+
+// readyForQuery sends that the server is ready for the next query, with whether the session is in a transaction, and
+// whether it failed.
+func (c *conn) readyForQuery() {
+	status := byte('I')
+	if c.session.InFailedTransaction() {
+		status = 'E'
+	} else if c.session.InTransaction() {
+		status = 'T'
+	}
+
+	c.msg.start(msgReadyForQuery)
+	c.msg.byte(status)
+	c.send()
+}
+
+func (c *conn) sendCommandComplete(tag string) {
+	c.msg.start(msgCommandComplete)
+	c.msg.string(tag)
+	c.send()
+}
+
+func (c *conn) sendError(err error) {
+	c.msg.start(msgErrorResponse)
+	c.msg.byte('S')
+	c.msg.string("ERROR")
+	c.msg.byte('V')
+	c.msg.string("ERROR")
+	c.msg.byte('C')
+	c.msg.string(errorCode(err))
+	c.msg.byte('M')
+	c.msg.string(err.Error())
+	c.msg.byte(0)
+	c.send()
+}
+
+func (c *conn) sendRowDescription(columns []engine.Column, formats []int16) {
+	c.msg.start(msgRowDescription)
+	c.msg.int16(int16(len(columns)))
+
+	for i, column := range columns {
+		oid := typeOID(column.Type)
+
+		c.msg.string(column.Name)
+		c.msg.int32(0) // table OID
+		c.msg.int16(0) // column attribute number
+		c.msg.int32(int32(oid))
+		c.msg.int16(typeSize(oid))
+		c.msg.int32(-1) // type modifier
+		c.msg.int16(formatFor(formats, i))
+	}
+
+	c.send()
+}
+
+// sendRows sends the rows of the cursor in the formats, stopping after maxRows rows unless it is 0. It returns the
+// number of rows that were sent, and whether it stopped before the cursor was exhausted.
+func (c *conn) sendRows(cursor *engine.Cursor, formats []int16, maxRows int) (int, bool, error) {
+	n := 0
+
+	for maxRows <= 0 || n < maxRows {
+		if !cursor.Next() {
+			return n, false, cursor.Err()
+		}
+
+		row := cursor.Row()
+
+		c.msg.start(msgDataRow)
+		c.msg.int16(int16(len(row)))
+
+		for i, val := range row {
+			b := encodeValue(val, formatFor(formats, i))
+			if b == nil {
+				c.msg.int32(-1)
+				continue
+			}
+
+			c.msg.int32(int32(len(b)))
+			c.msg.bytes(b)
+		}
+
+		c.send()
+		n++
+	}
+
+	return n, true, nil
+}
+
+// splitStatements splits the query into its statements, which are separated by semicolons outside of quotes. Blank
+// statements are left out.
+func splitStatements(query string) []string {
+	var statements []string
+	var quote byte
+	escaped := false
+	start := 0
+
+	add := func(end int) {
+		if statement := strings.TrimSpace(query[start:end]); statement != "" {
+			statements = append(statements, statement)
+		}
+
+		start = end + 1
+	}
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && ch == '\\':
+			escaped = true
+		case quote != 0:
+			// like the parser, only the kind of quote that opened a quoted string closes it
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == ';':
+			add(i)
+		}
+	}
+
+	add(len(query))
+
+	return statements
+}
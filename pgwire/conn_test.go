@@ -0,0 +1,428 @@
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// testClient speaks the frontend side of the protocol to a server that is started for a test.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	rd   *bufio.Reader
+	msg  messageWriter
+}
+
+// reply is a message of the backend, with the fields of it that the tests look at: the values of a DataRow, the names
+// of the columns of a RowDescription, the tag of a CommandComplete, the status of a ReadyForQuery, and the code and
+// message of an ErrorResponse.
+type reply struct {
+	typ    byte
+	fields []string
+}
+
+func (r reply) String() string {
+	return fmt.Sprintf("%c%q", r.typ, r.fields)
+}
+
+// startServer starts a server of an engine of a new data directory, and connects a client to it.
+func startServer(t *testing.T) *testClient {
+	e, err := engine.New(context.Background(), engine.WithDataDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(e)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Serve(l)
+
+	t.Cleanup(func() {
+		_ = s.Close()
+		_ = e.Cleanup()
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testClient{t: t, conn: conn, rd: bufio.NewReader(conn)}
+
+	var w messageWriter
+	w.b = []byte{0, 0, 0, 0}
+	w.int32(protocolVersion3)
+	w.string("user")
+	w.string("test")
+	w.byte(0)
+	binary.BigEndian.PutUint32(w.b, uint32(len(w.b)))
+
+	_, err = conn.Write(w.b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := c.readUntilReady()
+	if replies[0].typ != msgAuthentication {
+		t.Fatalf("startup was answered with %v", replies)
+	}
+
+	return c
+}
+
+// send sends a message of the type, whose body is written by build.
+func (c *testClient) send(typ byte, build func(w *messageWriter)) {
+	c.msg.start(typ)
+	build(&c.msg)
+
+	_, err := c.conn.Write(c.msg.finish())
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read reads the next message of the server.
+func (c *testClient) read() reply {
+	typ, body, err := readMessage(c.rd)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	r := messageReader{b: body}
+	rep := reply{typ: typ}
+
+	switch typ {
+	case msgDataRow:
+		for i := r.count(); i > 0; i-- {
+			if n := r.int32(); n == -1 {
+				rep.fields = append(rep.fields, "NULL")
+			} else {
+				rep.fields = append(rep.fields, string(r.bytes(int(n))))
+			}
+		}
+	case msgRowDescription:
+		for i := r.count(); i > 0; i-- {
+			rep.fields = append(rep.fields, r.string())
+			r.bytes(18)
+		}
+	case msgCommandComplete:
+		rep.fields = []string{r.string()}
+	case msgReadyForQuery:
+		rep.fields = []string{string(r.byte())}
+	case msgErrorResponse:
+		var code, message string
+
+		for field := r.byte(); field != 0 && r.err == nil; field = r.byte() {
+			switch value := r.string(); field {
+			case 'C':
+				code = value
+			case 'M':
+				message = value
+			}
+		}
+
+		rep.fields = []string{code, message}
+	}
+
+	if r.err != nil {
+		c.t.Fatalf("could not read message %c: %s", typ, r.err)
+	}
+
+	return rep
+}
+
+// readUntilReady reads every message of the server up to and including the next ReadyForQuery.
+func (c *testClient) readUntilReady() []reply {
+	var replies []reply
+
+	for {
+		rep := c.read()
+		replies = append(replies, rep)
+
+		if rep.typ == msgReadyForQuery {
+			return replies
+		}
+	}
+}
+
+// query runs the simple query and returns the replies of the server to it.
+func (c *testClient) query(sql string) []reply {
+	c.send(msgQuery, func(w *messageWriter) {
+		w.string(sql)
+	})
+
+	return c.readUntilReady()
+}
+
+// sync sends a Sync and returns every reply of the server up to it.
+func (c *testClient) sync() []reply {
+	c.send(msgSync, func(w *messageWriter) {})
+
+	return c.readUntilReady()
+}
+
+func (c *testClient) expect(got []reply, want ...reply) {
+	c.t.Helper()
+
+	if !reflect.DeepEqual(got, want) {
+		c.t.Fatalf("got replies %v, want %v", got, want)
+	}
+}
+
+func fields(typ byte, fields ...string) reply {
+	return reply{typ: typ, fields: fields}
+}
+
+func ready(status string) reply {
+	return fields(msgReadyForQuery, status)
+}
+
+// expectError checks that the replies are an error with the code, followed by a ReadyForQuery.
+func (c *testClient) expectError(got []reply, code string) {
+	c.t.Helper()
+
+	if len(got) != 2 || got[0].typ != msgErrorResponse || got[0].fields[0] != code || got[1].typ != msgReadyForQuery {
+		c.t.Fatalf("got replies %v, want an error with code %s", got, code)
+	}
+}
+
+func TestSimpleQuery(t *testing.T) {
+	c := startServer(t)
+
+	c.expect(c.query("CREATE TABLE people (name string, age int); INSERT INTO people VALUES ('a;b', 3)"),
+		fields(msgCommandComplete, "CREATE TABLE"),
+		fields(msgCommandComplete, "INSERT 0 1"),
+		ready("I"))
+
+	c.expect(c.query("INSERT INTO people (name) VALUES ('c')"), fields(msgCommandComplete, "INSERT 0 1"), ready("I"))
+
+	// the other kind of quote does not close a quoted string
+	c.expect(c.query(`INSERT INTO people VALUES ('a "b; c" d', 1); INSERT INTO people VALUES ("e 'f; g", 2)`),
+		fields(msgCommandComplete, "INSERT 0 1"),
+		fields(msgCommandComplete, "INSERT 0 1"),
+		ready("I"))
+
+	c.expect(c.query("SELECT name, age FROM people ORDER BY name"),
+		fields(msgRowDescription, "name", "age"),
+		fields(msgDataRow, `a "b; c" d`, "1"),
+		fields(msgDataRow, "a;b", "3"),
+		fields(msgDataRow, "c", "NULL"),
+		fields(msgDataRow, "e 'f; g", "2"),
+		fields(msgCommandComplete, "SELECT 4"),
+		ready("I"))
+
+	c.expect(c.query(""), fields(msgEmptyQueryResponse), ready("I"))
+
+	c.expectError(c.query("SELEC name FROM people"), codeSyntaxError)
+	c.expectError(c.query("SELECT name FROM nobody"), codeUndefinedTable)
+
+	// the statements after the one that fails are not run
+	got := c.query("INSERT INTO people VALUES ('d', 4); SELECT name FROM nobody; INSERT INTO people VALUES ('e', 5)")
+	if len(got) != 3 || got[1].typ != msgErrorResponse {
+		t.Fatalf("got replies %v", got)
+	}
+
+	c.expect(c.query("SELECT name FROM people WHERE age > 3"),
+		fields(msgRowDescription, "name"),
+		fields(msgDataRow, "d"),
+		fields(msgCommandComplete, "SELECT 1"),
+		ready("I"))
+}
+
+func TestTransactionStatus(t *testing.T) {
+	c := startServer(t)
+
+	c.query("CREATE TABLE t (a int)")
+
+	c.expect(c.query("BEGIN"), fields(msgCommandComplete, "BEGIN"), ready("T"))
+	c.expect(c.query("INSERT INTO t VALUES (1)"), fields(msgCommandComplete, "INSERT 0 1"), ready("T"))
+	c.expect(c.query("ROLLBACK"), fields(msgCommandComplete, "ROLLBACK"), ready("I"))
+
+	c.expect(c.query("SELECT a FROM t"), fields(msgRowDescription, "a"), fields(msgCommandComplete, "SELECT 0"), ready("I"))
+
+	// once a statement fails, the transaction has to be rolled back
+	c.query("BEGIN; INSERT INTO t VALUES (1)")
+
+	got := c.query("INSERT INTO nobody VALUES (2)")
+	if len(got) != 2 || got[0].typ != msgErrorResponse || !reflect.DeepEqual(got[1], ready("E")) {
+		t.Fatalf("got replies %v", got)
+	}
+
+	c.expectError(c.query("INSERT INTO t VALUES (3)"), codeInFailedTransaction)
+
+	got = c.query("COMMIT")
+	if len(got) != 2 || got[0].fields[0] != codeInFailedTransaction || !reflect.DeepEqual(got[1], ready("I")) {
+		t.Fatalf("got replies %v", got)
+	}
+
+	c.expect(c.query("SELECT a FROM t"), fields(msgRowDescription, "a"), fields(msgCommandComplete, "SELECT 0"), ready("I"))
+
+	// rolling back to a savepoint from before the statement that failed keeps the transaction going
+	c.query("BEGIN; INSERT INTO t VALUES (1); SAVEPOINT s")
+	c.expectError(c.query("INSERT INTO nobody VALUES (2)"), codeUndefinedTable)
+	c.expect(c.query("ROLLBACK TO SAVEPOINT s"), fields(msgCommandComplete, "ROLLBACK"), ready("T"))
+	c.expect(c.query("COMMIT"), fields(msgCommandComplete, "COMMIT"), ready("I"))
+
+	c.expect(c.query("SELECT a FROM t"),
+		fields(msgRowDescription, "a"),
+		fields(msgDataRow, "1"),
+		fields(msgCommandComplete, "SELECT 1"),
+		ready("I"))
+}
+
+func TestExtendedQuery(t *testing.T) {
+	c := startServer(t)
+
+	c.query("CREATE TABLE people (name string, age int); INSERT INTO people VALUES ('a', 3); INSERT INTO people VALUES ('b', 4)")
+
+	c.send(msgParse, func(w *messageWriter) {
+		w.string("older")
+		w.string("SELECT name FROM people WHERE age > $1 ORDER BY name")
+		w.int16(0)
+	})
+	c.send(msgBind, func(w *messageWriter) {
+		w.string("")
+		w.string("older")
+		w.int16(0)
+		w.int16(1)
+		w.int32(1)
+		w.bytes([]byte("2"))
+		w.int16(0)
+	})
+	c.send(msgDescribe, func(w *messageWriter) {
+		w.byte('P')
+		w.string("")
+	})
+	c.send(msgExecute, func(w *messageWriter) {
+		w.string("")
+		w.int32(0)
+	})
+
+	c.expect(c.sync(),
+		fields(msgParseComplete),
+		fields(msgBindComplete),
+		fields(msgRowDescription, "name"),
+		fields(msgDataRow, "a"),
+		fields(msgDataRow, "b"),
+		fields(msgCommandComplete, "SELECT 2"),
+		ready("I"))
+
+	// the prepared statement is kept after the sync, and a portal can return its rows a few at a time
+	c.query("BEGIN")
+	c.send(msgBind, func(w *messageWriter) {
+		w.string("p")
+		w.string("older")
+		w.int16(0)
+		w.int16(1)
+		w.int32(1)
+		w.bytes([]byte("0"))
+		w.int16(0)
+	})
+	c.send(msgExecute, func(w *messageWriter) {
+		w.string("p")
+		w.int32(1)
+	})
+
+	c.expect(c.sync(), fields(msgBindComplete), fields(msgDataRow, "a"), fields(msgPortalSuspended), ready("T"))
+
+	c.send(msgExecute, func(w *messageWriter) {
+		w.string("p")
+		w.int32(0)
+	})
+
+	c.expect(c.sync(), fields(msgDataRow, "b"), fields(msgCommandComplete, "SELECT 1"), ready("T"))
+	c.query("COMMIT")
+}
+
+func TestExtendedQueryError(t *testing.T) {
+	c := startServer(t)
+
+	// once a message fails, the ones after it are skipped until the sync
+	c.send(msgBind, func(w *messageWriter) {
+		w.string("")
+		w.string("missing")
+		w.int16(0)
+		w.int16(0)
+		w.int16(0)
+	})
+	c.send(msgExecute, func(w *messageWriter) {
+		w.string("")
+		w.int32(0)
+	})
+
+	c.expectError(c.sync(), codeInvalidStatementName)
+
+	c.expect(c.query("CREATE TABLE t (a int)"), fields(msgCommandComplete, "CREATE TABLE"), ready("I"))
+}
+
+func TestMalformedBind(t *testing.T) {
+	c := startServer(t)
+
+	c.query("CREATE TABLE t (a int)")
+
+	c.send(msgParse, func(w *messageWriter) {
+		w.string("s")
+		w.string("SELECT a FROM t WHERE a = $1")
+		w.int16(0)
+	})
+	c.expect(c.sync(), fields(msgParseComplete), ready("I"))
+
+	for name, build := range map[string]func(w *messageWriter){
+		"negative format count": func(w *messageWriter) {
+			w.int16(-1)
+		},
+		"negative value count": func(w *messageWriter) {
+			w.int16(0)
+			w.int16(-1)
+		},
+		"negative value length": func(w *messageWriter) {
+			w.int16(0)
+			w.int16(1)
+			w.int32(-2)
+			w.int16(0)
+		},
+		"truncated": func(w *messageWriter) {
+			w.int16(0)
+			w.int16(1)
+			w.int32(10)
+		},
+	} {
+		c.send(msgBind, func(w *messageWriter) {
+			w.string("")
+			w.string("s")
+			build(w)
+		})
+
+		got := c.sync()
+		if len(got) != 2 || got[0].typ != msgErrorResponse || got[0].fields[0] != codeProtocolViolation {
+			t.Fatalf("%s: got replies %v, want a protocol violation", name, got)
+		}
+	}
+
+	// the connection can still be used
+	c.send(msgBind, func(w *messageWriter) {
+		w.string("")
+		w.string("s")
+		w.int16(0)
+		w.int16(1)
+		w.int32(-1)
+		w.int16(0)
+	})
+	c.send(msgExecute, func(w *messageWriter) {
+		w.string("")
+		w.int32(0)
+	})
+
+	c.expect(c.sync(), fields(msgBindComplete), fields(msgCommandComplete, "SELECT 0"), ready("I"))
+}
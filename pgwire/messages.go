@@ -0,0 +1,234 @@
+package pgwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The largest message and startup packet that are read from a client, in bytes.
+const (
+	maxMessageSize       = 64 * 1024 * 1024
+	maxStartupPacketSize = 10000
+)
+
+// Request codes of the startup packet. Instead of a protocol version, it can request encryption or the cancellation of
+// a query.
+const (
+	protocolVersion3  = 196608
+	sslRequestCode    = 80877103
+	gssEncRequestCode = 80877104
+	cancelRequestCode = 80877102
+)
+
+// Types of the messages of the frontend.
+const (
+	msgQuery     byte = 'Q'
+	msgParse     byte = 'P'
+	msgBind      byte = 'B'
+	msgDescribe  byte = 'D'
+	msgExecute   byte = 'E'
+	msgSync      byte = 'S'
+	msgFlush     byte = 'H'
+	msgClose     byte = 'C'
+	msgTerminate byte = 'X'
+)
+
+// Types of the messages of the backend.
+const (
+	msgAuthentication       byte = 'R'
+	msgParameterStatus      byte = 'S'
+	msgBackendKeyData       byte = 'K'
+	msgReadyForQuery        byte = 'Z'
+	msgRowDescription       byte = 'T'
+	msgDataRow              byte = 'D'
+	msgCommandComplete      byte = 'C'
+	msgEmptyQueryResponse   byte = 'I'
+	msgErrorResponse        byte = 'E'
+	msgParseComplete        byte = '1'
+	msgBindComplete         byte = '2'
+	msgCloseComplete        byte = '3'
+	msgNoData               byte = 'n'
+	msgParameterDescription byte = 't'
+	msgPortalSuspended      byte = 's'
+)
+
+// errMalformedMessage is returned when a message is shorter than its fields, or a field has a value it can not have.
+var errMalformedMessage = errors.New("malformed message")
+
+// messageReader reads the fields of the body of a message. Once a field can not be read, err is set and every field
+// after it is empty.
+type messageReader struct {
+	b   []byte
+	err error
+}
+
+func (r *messageReader) fail() {
+	if r.err == nil {
+		r.err = errMalformedMessage
+	}
+
+	r.b = nil
+}
+
+// string reads a null terminated string.
+func (r *messageReader) string() string {
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.fail()
+		return ""
+	}
+
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+
+	return s
+}
+
+func (r *messageReader) byte() byte {
+	if len(r.b) < 1 {
+		r.fail()
+		return 0
+	}
+
+	b := r.b[0]
+	r.b = r.b[1:]
+
+	return b
+}
+
+func (r *messageReader) int16() int16 {
+	if len(r.b) < 2 {
+		r.fail()
+		return 0
+	}
+
+	i := int16(binary.BigEndian.Uint16(r.b))
+	r.b = r.b[2:]
+
+	return i
+}
+
+func (r *messageReader) int32() int32 {
+	if len(r.b) < 4 {
+		r.fail()
+		return 0
+	}
+
+	i := int32(binary.BigEndian.Uint32(r.b))
+	r.b = r.b[4:]
+
+	return i
+}
+
+// count reads the number of the fields that follow as an int16, which can not be negative.
+func (r *messageReader) count() int {
+	n := r.int16()
+	if n < 0 {
+		r.fail()
+		return 0
+	}
+
+	return int(n)
+}
+
+// bytes reads the next n bytes.
+func (r *messageReader) bytes(n int) []byte {
+	if n < 0 || len(r.b) < n {
+		r.fail()
+		return nil
+	}
+
+	b := r.b[:n]
+	r.b = r.b[n:]
+
+	return b
+}
+
+// readMessage reads a message of the frontend, which is its type and its body.
+func readMessage(rd io.Reader) (byte, []byte, error) {
+	var header [5]byte
+
+	_, err := io.ReadFull(rd, header[:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n := int64(binary.BigEndian.Uint32(header[1:]))
+	if n < 4 || n > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", n)
+	}
+
+	body := make([]byte, n-4)
+
+	_, err = io.ReadFull(rd, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], body, nil
+}
+
+// readStartupPacket reads the first packet of a connection, which has no type.
+func readStartupPacket(rd io.Reader) ([]byte, error) {
+	var header [4]byte
+
+	_, err := io.ReadFull(rd, header[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n := int64(binary.BigEndian.Uint32(header[:]))
+	if n < 8 || n > maxStartupPacketSize {
+		return nil, fmt.Errorf("invalid startup packet length %d", n)
+	}
+
+	body := make([]byte, n-4)
+
+	_, err = io.ReadFull(rd, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// messageWriter builds a message of the backend.
+type messageWriter struct {
+	b []byte
+}
+
+// start begins a message of the type.
+func (w *messageWriter) start(t byte) {
+	w.b = append(w.b[:0], t, 0, 0, 0, 0)
+}
+
+func (w *messageWriter) string(s string) {
+	w.b = append(w.b, s...)
+	w.b = append(w.b, 0)
+}
+
+func (w *messageWriter) byte(b byte) {
+	w.b = append(w.b, b)
+}
+
+func (w *messageWriter) int16(i int16) {
+	w.b = append(w.b, byte(i>>8), byte(i))
+}
+
+func (w *messageWriter) int32(i int32) {
+	w.b = append(w.b, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+}
+
+func (w *messageWriter) bytes(b []byte) {
+	w.b = append(w.b, b...)
+}
+
+// finish returns the message with its length filled in.
+func (w *messageWriter) finish() []byte {
+	binary.BigEndian.PutUint32(w.b[1:5], uint32(len(w.b)-1))
+
+	return w.b
+}
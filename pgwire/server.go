@@ -0,0 +1,140 @@
+// Package pgwire serves the database over a subset of the PostgreSQL frontend/backend protocol, version 3.0, so that
+// Postgres clients such as psql and drivers can be pointed at it.
+//
+// Connections are not authenticated or encrypted, and every connection is a session of the engine. Both the simple
+// query protocol and the extended query protocol, with Parse, Bind, Describe, Execute, Close, Flush and Sync, are
+// supported. Columns are sent as text, int8, float8 or bool, in text or binary. The types of parameters are text
+// unless the client gives their type, since the engine converts the text of a value to the type of its field.
+package pgwire
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// ErrServerClosed is returned by Serve once the server is closed.
+var ErrServerClosed = errors.New("pgwire: server closed")
+
+// Server accepts connections of Postgres clients to an engine.
+type Server struct {
+	engine *engine.SQLEngine
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[*conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New returns a server of the engine.
+func New(e *engine.SQLEngine) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Server{
+		engine:    e,
+		ctx:       ctx,
+		cancel:    cancel,
+		listeners: map[net.Listener]bool{},
+		conns:     map[*conn]bool{},
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the connections to it, see Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve serves every connection accepted by the listener until the server is closed, after which it returns
+// ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+
+	s.listeners[l] = true
+	s.mu.Unlock()
+
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+
+		c := newConn(s, netConn)
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			netConn.Close()
+			continue
+		}
+
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+
+			err := c.serve()
+			if err != nil {
+				log.Println("pgwire:", err)
+			}
+
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections, cancels the statements that are running, and closes every connection, rolling
+// back their transactions. It returns once every connection is closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+
+	s.closed = true
+	s.cancel()
+
+	var err error
+
+	for l := range s.listeners {
+		if lErr := l.Close(); err == nil {
+			err = lErr
+		}
+	}
+
+	for c := range s.conns {
+		c.netConn.Close()
+	}
+
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
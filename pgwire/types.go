@@ -0,0 +1,178 @@
+package pgwire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+)
+
+// The OIDs of the Postgres types that values are sent and received as.
+const (
+	oidUnspecified uint32 = 0
+	oidBool        uint32 = 16
+	oidInt8        uint32 = 20
+	oidInt2        uint32 = 21
+	oidInt4        uint32 = 23
+	oidText        uint32 = 25
+	oidFloat4      uint32 = 700
+	oidFloat8      uint32 = 701
+	oidUnknown     uint32 = 705
+	oidVarchar     uint32 = 1043
+)
+
+// The formats of values, which are either text or binary.
+const (
+	formatText   int16 = 0
+	formatBinary int16 = 1
+)
+
+// typeOID returns the OID of the Postgres type of a column of the type.
+func typeOID(p backend.Primitive) uint32 {
+	switch p {
+	case backend.PrimitiveBool:
+		return oidBool
+	case backend.PrimitiveInt:
+		return oidInt8
+	case backend.PrimitiveFloat:
+		return oidFloat8
+	}
+
+	return oidText
+}
+
+// typeSize returns the size of the Postgres type of the OID, or -1 if its values vary in size.
+func typeSize(oid uint32) int16 {
+	switch oid {
+	case oidBool:
+		return 1
+	case oidInt8, oidFloat8:
+		return 8
+	}
+
+	return -1
+}
+
+// formatFor returns the format of the column at the index, from the format codes of a Bind message. No codes mean
+// that every column is text, and a single code is the format of every column.
+func formatFor(formats []int16, index int) int16 {
+	switch len(formats) {
+	case 0:
+		return formatText
+	case 1:
+		return formats[0]
+	}
+
+	return formats[index]
+}
+
+// encodeValue returns a value of the engine in the format, which is nil for NULL.
+func encodeValue(val interface{}, format int16) []byte {
+	if format == formatBinary {
+		switch v := val.(type) {
+		case nil:
+			return nil
+		case string:
+			return []byte(v)
+		case int64:
+			return appendUint64(nil, uint64(v))
+		case float64:
+			return appendUint64(nil, math.Float64bits(v))
+		case bool:
+			if v {
+				return []byte{1}
+			}
+
+			return []byte{0}
+		}
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return []byte("Infinity")
+		case math.IsInf(v, -1):
+			return []byte("-Infinity")
+		}
+
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("t")
+		}
+
+		return []byte("f")
+	}
+
+	return []byte(fmt.Sprint(val))
+}
+
+// decodeParam returns the argument of a parameter of the type of the OID from the value in the format. Values in text
+// are strings, which the engine parses as the type of their field, while values in binary are decoded from their
+// type. A nil value is NULL.
+func decodeParam(b []byte, oid uint32, format int16) (interface{}, error) {
+	if b == nil {
+		return nil, nil
+	}
+
+	if format == formatText {
+		return string(b), nil
+	}
+
+	switch oid {
+	case oidBool:
+		if len(b) != 1 {
+			return nil, fmt.Errorf("invalid binary bool")
+		}
+
+		return b[0] != 0, nil
+	case oidInt2:
+		if len(b) != 2 {
+			return nil, fmt.Errorf("invalid binary int2")
+		}
+
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case oidInt4:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("invalid binary int4")
+		}
+
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case oidInt8:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid binary int8")
+		}
+
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case oidFloat4:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("invalid binary float4")
+		}
+
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case oidFloat8:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid binary float8")
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case oidText, oidVarchar, oidUnknown, oidUnspecified:
+		return string(b), nil
+	}
+
+	return nil, fmt.Errorf("binary values of type %d are not supported", oid)
+}
+
+// appendUint64 appends the big endian encoding of an uint64 to the byte slice.
+func appendUint64(b []byte, val uint64) []byte {
+	return append(b, byte(val>>56), byte(val>>48), byte(val>>40), byte(val>>32), byte(val>>24), byte(val>>16), byte(val>>8), byte(val))
+}
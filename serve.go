@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/pgwire"
+	"github.com/Dojo456/simple-sql-db/server"
+)
+
+// shutdownTimeout is how long the server waits for the requests that are running once it is interrupted.
+const shutdownTimeout = 10 * time.Second
+
+// serve runs the REST API server with the arguments of the serve command, until it is interrupted. The database is also
+// served over the PostgreSQL wire protocol if an address is given for it.
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "the address to listen on")
+	pgAddr := flags.String("pg-addr", "", "the address to listen on for Postgres clients, if any")
+	dataDir := flags.String("dir", "database", "the directory that the database files are stored in")
+	bufferPoolMB := flags.Int64("buffer-pool", 32, "the megabytes of table pages to keep in memory")
+	_ = flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sqlEngine, err := engine.New(ctx, engine.WithDataDir(*dataDir), engine.WithBufferPoolSize(*bufferPoolMB*1024*1024))
+	if err != nil {
+		log.Fatalln(fmt.Errorf("could not intialize SQL Engine: %w", err))
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: server.New(sqlEngine)}
+
+	var pgServer *pgwire.Server
+	if *pgAddr != "" {
+		pgServer = pgwire.New(sqlEngine)
+
+		go func() {
+			log.Printf("listening for Postgres clients on %s", *pgAddr)
+
+			err := pgServer.ListenAndServe(*pgAddr)
+			if !errors.Is(err, pgwire.ErrServerClosed) {
+				log.Println("Postgres server stopped:", err)
+			}
+		}()
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Println("could not shut down server:", err)
+		}
+	}()
+
+	log.Printf("listening on %s", *addr)
+
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-shutdown
+	} else {
+		log.Println("server stopped:", err)
+	}
+
+	if pgServer != nil {
+		_ = pgServer.Close()
+	}
+
+	cleanup(sqlEngine)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// queryRequest is the body of a request to /query. Args are the arguments of the parameters of the statement, the
+// first one being $1.
+type queryRequest struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args"`
+}
+
+// queryResponse is the result of a statement. Columns and Rows are only filled in for SELECT statements, where each
+// row has a value for each column, in the same order.
+type queryResponse struct {
+	Command      string          `json:"command"`
+	Columns      []column        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowsAffected int             `json:"rowsAffected"`
+	Table        *tableSchema    `json:"table,omitempty"`
+	Index        *indexSchema    `json:"index,omitempty"`
+}
+
+type column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type indexSchema struct {
+	Name      string `json:"name"`
+	FieldName string `json:"fieldName"`
+	Unique    bool   `json:"unique"`
+}
+
+func newQueryResponse(result *engine.ResultSet) queryResponse {
+	response := queryResponse{
+		Command:      result.Command.String(),
+		Columns:      make([]column, len(result.Columns)),
+		Rows:         result.Rows,
+		RowsAffected: result.RowsAffected,
+	}
+
+	for i, c := range result.Columns {
+		response.Columns[i] = column{Name: c.Name, Type: string(c.Type)}
+	}
+
+	if response.Rows == nil {
+		response.Rows = [][]interface{}{}
+	}
+
+	if result.Table != nil {
+		schema := newTableSchema(result.Table)
+		response.Table = &schema
+	}
+
+	if result.Index != nil {
+		response.Index = &indexSchema{Name: result.Index.Name, FieldName: result.Index.FieldName, Unique: result.Index.Unique}
+	}
+
+	return response
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req queryRequest
+
+	err := decodeBody(r, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	args := make([]interface{}, len(req.Args))
+	for i, arg := range req.Args {
+		args[i], err = jsonArg(arg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	session := s.engine.NewSession()
+	defer closeSession(session)
+
+	result, err := run(r.Context(), session, req.SQL, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newQueryResponse(result))
+}
+
+// run prepares the statement in the session and runs it with the arguments.
+func run(ctx context.Context, session *engine.Session, statement string, args []interface{}) (*engine.ResultSet, error) {
+	st, err := session.Prepare(ctx, statement)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	if st.NumParams != len(args) {
+		return nil, badRequest("expecting %d arguments, got %d", st.NumParams, len(args))
+	}
+
+	return st.Exec(ctx, args...)
+}
+
+// closeSession closes the session, rolling back the transaction that the request did not end.
+func closeSession(session *engine.Session) {
+	err := session.Close()
+	if err != nil {
+		log.Println("could not close session:", err)
+	}
+}
+
+// jsonArg returns the argument of a parameter from its JSON value. Integers are int64 and other numbers float64.
+func jsonArg(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return nil, badRequest("invalid number %s: %w", v, err)
+		}
+
+		return f, nil
+	case nil, string, bool:
+		return v, nil
+	}
+
+	return nil, badRequest("arguments can only be strings, numbers, booleans or null")
+}
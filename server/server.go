@@ -0,0 +1,144 @@
+// Package server serves the database over HTTP with a JSON REST API:
+//
+//	POST /query                runs a statement, {"sql": "...", "args": [...]}
+//	GET  /tables               lists the tables and their fields
+//	GET  /tables/{name}        describes a table
+//	GET  /tables/{name}/rows   selects rows, filtered by ?{field}={value} and limited by ?limit={n}
+//	POST /tables/{name}/rows   inserts a row, or an array of rows, given as objects of field names to values
+//
+// Every request runs in its own session of the engine, so a transaction that is begun by /query is rolled back once
+// the request is done. Statements that can not be parsed, or that are given the wrong number of arguments, are
+// answered with 400 Bad Request, statements on a table that does not exist with 404 Not Found, and statements that
+// fail while they run with 422 Unprocessable Entity.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// Server is the http.Handler of the REST API of an engine.
+type Server struct {
+	engine *engine.SQLEngine
+	mux    *http.ServeMux
+}
+
+// New returns the handler of the REST API of the engine.
+func New(e *engine.SQLEngine) *Server {
+	s := &Server{engine: e, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("/query", s.handleQuery)
+	s.mux.HandleFunc("/tables", s.handleTables)
+	s.mux.HandleFunc("/tables/", s.handleTable)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// statusError is an error that is answered with its status code.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// badRequest returns an error that is answered with 400 Bad Request.
+func badRequest(format string, a ...interface{}) error {
+	return &statusError{status: http.StatusBadRequest, err: fmt.Errorf(format, a...)}
+}
+
+// parseError returns the error of a statement that could not be parsed, which is answered with 400 Bad Request.
+func parseError(err error) error {
+	return &statusError{status: http.StatusBadRequest, err: err}
+}
+
+// statusOf returns the status code that the error is answered with. Errors that are not a statusError are errors of
+// statements that failed while they ran.
+func statusOf(err error) int {
+	var sErr *statusError
+
+	switch {
+	case errors.As(err, &sErr):
+		return sErr.status
+	case errors.Is(err, backend.ErrTableNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusUnprocessableEntity
+}
+
+// errorResponse is the body of every response to a request that failed.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError answers the request with the error.
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusOf(err), errorResponse{Error: err.Error()})
+}
+
+// writeJSON answers the request with the status code and the value encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(errorResponse{Error: fmt.Sprintf("could not encode response: %s", err)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(append(body, '\n'))
+	if err != nil {
+		log.Println("could not write response:", err)
+	}
+}
+
+// allowMethods answers the request with 405 Method Not Allowed and returns false if its method is not one of the
+// methods.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s is not allowed", r.Method)})
+
+	return false
+}
+
+// decodeBody decodes the JSON body of the request into v. Numbers are decoded as json.Number, so that integers keep
+// their precision.
+func decodeBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		return badRequest("could not decode request body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// TestServer runs requests against the REST API of an engine in order, and checks the status code of every response
+// and the body of the ones that succeed. Failed requests are only checked to have an error.
+func TestServer(t *testing.T) {
+	e, err := engine.New(context.Background(), engine.WithDataDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := e.Cleanup()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	ts := httptest.NewServer(New(e))
+	defer ts.Close()
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/query", `{"sql": "CREATE TABLE people (name varchar(10), age int, score float)"}`, http.StatusOK,
+			`{"command":"CREATE TABLE","columns":[],"rows":[],"rowsAffected":0,"table":{"name":"people","fields":[{"name":"name","type":"string","maxLength":10},{"name":"age","type":"int"},{"name":"score","type":"float"}]}}`},
+		{"POST", "/query", `{"sql": "CREATE UNIQUE INDEX people_name ON people (name)"}`, http.StatusOK,
+			`{"command":"CREATE INDEX","columns":[],"rows":[],"rowsAffected":0,"index":{"name":"people_name","fieldName":"name","unique":true}}`},
+		{"POST", "/query", `{"sql": "INSERT INTO people VALUES (?, ?, ?)", "args": ["a, b", 9007199254740993, 1.5]}`, http.StatusOK,
+			`{"command":"INSERT","columns":[],"rows":[],"rowsAffected":1}`},
+		// integers keep their precision and NULL is null
+		{"POST", "/query", `{"sql": "INSERT INTO people (name) VALUES ($1)", "args": ["c"]}`, http.StatusOK,
+			`{"command":"INSERT","columns":[],"rows":[],"rowsAffected":1}`},
+		{"POST", "/query", `{"sql": "SELECT * FROM people WHERE age = $1 OR age IS NULL", "args": [9007199254740993]}`, http.StatusOK,
+			`{"command":"SELECT","columns":[{"name":"name","type":"string"},{"name":"age","type":"int"},{"name":"score","type":"float"}],"rows":[["a, b",9007199254740993,1.5],["c",null,null]],"rowsAffected":0}`},
+
+		// statements that can not be parsed, or have the wrong arguments, are bad requests
+		{"POST", "/query", `{"sql": "SELEC * FROM people"}`, http.StatusBadRequest, ""},
+		{"POST", "/query", `{"sql": "SELECT * FROM people WHERE age = ?", "args": []}`, http.StatusBadRequest, ""},
+		{"POST", "/query", `{"sql": "SELECT * FROM people WHERE age = ?", "args": [[1]]}`, http.StatusBadRequest, ""},
+		{"POST", "/query", `{"sql": `, http.StatusBadRequest, ""},
+		{"POST", "/query", `{"sql": "SELECT * FROM nobody"}`, http.StatusNotFound, ""},
+		// while statements that fail when they run are not
+		{"POST", "/query", `{"sql": "SELECT * FROM people WHERE age = ?", "args": ["x"]}`, http.StatusUnprocessableEntity, ""},
+		{"GET", "/query", ``, http.StatusMethodNotAllowed, ""},
+
+		{"POST", "/tables/people/rows", `[{"name": "d", "age": 4, "score": 2}, {"name": "e", "score": 2.5}]`, http.StatusCreated,
+			`{"rowsAffected":2}`},
+		// either every row is inserted or none are
+		{"POST", "/tables/people/rows", `[{"name": "f", "age": 4}, {"name": "d"}]`, http.StatusUnprocessableEntity, ""},
+		{"POST", "/tables/people/rows", `{"name": "f", "other": 4}`, http.StatusBadRequest, ""},
+		{"POST", "/tables/people/rows", `{"name": "f", "age": 4.5}`, http.StatusBadRequest, ""},
+		{"POST", "/tables/people/rows", `{}`, http.StatusBadRequest, ""},
+		{"GET", "/tables/people/rows?age=4&limit=5", ``, http.StatusOK,
+			`{"rows":[{"age":4,"name":"d","score":2}]}`},
+		{"GET", "/tables/people/rows?limit=2", ``, http.StatusOK,
+			`{"rows":[{"age":9007199254740993,"name":"a, b","score":1.5},{"age":null,"name":"c","score":null}]}`},
+		{"GET", "/tables/people/rows?limit=-1", ``, http.StatusBadRequest, ""},
+		{"GET", "/tables/people/rows?other=4", ``, http.StatusBadRequest, ""},
+
+		{"GET", "/tables", ``, http.StatusOK,
+			`[{"name":"people","fields":[{"name":"name","type":"string","maxLength":10},{"name":"age","type":"int"},{"name":"score","type":"float"}]}]`},
+		{"GET", "/tables/nobody", ``, http.StatusNotFound, ""},
+		{"GET", "/tables/nobody/rows", ``, http.StatusNotFound, ""},
+		{"DELETE", "/tables/people", ``, http.StatusMethodNotAllowed, ""},
+
+		// the transaction of a request is rolled back once it is done
+		{"POST", "/query", `{"sql": "BEGIN; DELETE FROM people"}`, http.StatusBadRequest, ""},
+		{"POST", "/query", `{"sql": "BEGIN"}`, http.StatusOK,
+			`{"command":"BEGIN","columns":[],"rows":[],"rowsAffected":0}`},
+		{"POST", "/query", `{"sql": "SELECT COUNT(*) FROM people"}`, http.StatusOK,
+			`{"command":"SELECT","columns":[{"name":"count(*)","type":"int"}],"rows":[[4]],"rowsAffected":0}`},
+	} {
+		req, err := http.NewRequest(c.method, ts.URL+c.path, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != c.status {
+			t.Errorf("%s %s %s: got %d %s, want %d", c.method, c.path, c.body, res.StatusCode, body, c.status)
+			continue
+		}
+
+		if res.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: got the content type %s", c.method, c.path, res.Header.Get("Content-Type"))
+		}
+
+		if c.want != "" {
+			if got := strings.TrimSpace(string(body)); got != c.want {
+				t.Errorf("%s %s %s:\ngot  %s\nwant %s", c.method, c.path, c.body, got, c.want)
+			}
+
+			continue
+		}
+
+		var response errorResponse
+		if err := json.Unmarshal(body, &response); err != nil || response.Error == "" {
+			t.Errorf("%s %s %s: got %s, want an error", c.method, c.path, c.body, body)
+		}
+	}
+}
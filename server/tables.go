@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// tableSchema describes a table and its fields. MaxLength is only set for strings whose length is limited.
+type tableSchema struct {
+	Name   string  `json:"name"`
+	Fields []field `json:"fields"`
+}
+
+type field struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	MaxLength int64  `json:"maxLength,omitempty"`
+}
+
+func newTableSchema(schema *engine.TableSchema) tableSchema {
+	fields := make([]field, len(schema.Fields))
+	for i, f := range schema.Fields {
+		fields[i] = field{Name: f.Name, Type: string(f.Type), MaxLength: f.MaxLength}
+	}
+
+	return tableSchema{Name: schema.Name, Fields: fields}
+}
+
+// rowsResponse is the body of a response with rows, each of which is an object of column names to values.
+type rowsResponse struct {
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// rowsAffectedResponse is the body of a response to a request that changed rows.
+type rowsAffectedResponse struct {
+	RowsAffected int `json:"rowsAffected"`
+}
+
+func (s *Server) handleTables(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	schemas, err := s.engine.Tables(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tables := make([]tableSchema, len(schemas))
+	for i, schema := range schemas {
+		tables[i] = newTableSchema(schema)
+	}
+
+	writeJSON(w, http.StatusOK, tables)
+}
+
+// handleTable handles /tables/{name} and /tables/{name}/rows.
+func (s *Server) handleTable(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tables/"), "/")
+	if parts[0] == "" || len(parts) > 2 || len(parts) == 2 && parts[1] != "rows" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+
+		schema, err := s.engine.Table(r.Context(), parts[0])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newTableSchema(schema))
+
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	schema, err := s.engine.Table(r.Context(), parts[0])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.getRows(w, r, schema)
+	} else {
+		s.insertRows(w, r, schema)
+	}
+}
+
+// getRows answers with the rows of the table whose fields are equal to the values of the query parameters of the
+// same name, or with at most limit rows if the limit query parameter is given.
+func (s *Server) getRows(w http.ResponseWriter, r *http.Request, schema *engine.TableSchema) {
+	query := r.URL.Query()
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var conditions []string
+	var args []interface{}
+	limit := ""
+
+	for _, key := range keys {
+		if key == "limit" {
+			n, err := strconv.Atoi(query.Get(key))
+			if err != nil || n < 0 {
+				writeError(w, badRequest("limit must be a number that is not negative"))
+				return
+			}
+
+			limit = fmt.Sprintf(" LIMIT %d", n)
+
+			continue
+		}
+
+		if _, ok := fieldOf(schema, key); !ok {
+			writeError(w, badRequest(`field "%s" does not exist on table "%s"`, key, schema.Name))
+			return
+		}
+
+		for _, val := range query[key] {
+			args = append(args, val)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", key, len(args)))
+		}
+	}
+
+	statement := "SELECT * FROM " + schema.Name
+	if len(conditions) != 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	statement += limit
+
+	session := s.engine.NewSession()
+	defer closeSession(session)
+
+	result, err := run(r.Context(), session, statement, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := rowsResponse{Rows: make([]map[string]interface{}, len(result.Rows))}
+	for i, row := range result.Rows {
+		response.Rows[i] = make(map[string]interface{}, len(row))
+		for j, val := range row {
+			response.Rows[i][result.Columns[j].Name] = val
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// insertRows inserts the row, or every row of an array of rows, of the body into the table, each an object of field
+// names to values. Fields that are left out are NULL. The rows are inserted in one transaction, so either all of them
+// are inserted or none are.
+func (s *Server) insertRows(w http.ResponseWriter, r *http.Request, schema *engine.TableSchema) {
+	var body json.RawMessage
+
+	err := decodeBody(r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var rows []map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) != 0 && trimmed[0] == '[' {
+		err = decoder.Decode(&rows)
+	} else {
+		rows = make([]map[string]interface{}, 1)
+		err = decoder.Decode(&rows[0])
+	}
+
+	if err != nil {
+		writeError(w, badRequest("rows must be objects of field names to values: %w", err))
+		return
+	}
+
+	session := s.engine.NewSession()
+	defer closeSession(session)
+
+	_, err = session.Execute(r.Context(), language.BeginCommand, &language.BeginArgs{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	inserted := 0
+
+	for _, row := range rows {
+		statement, args, err := insertStatement(schema, row)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		result, err := run(r.Context(), session, statement, args)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		inserted += result.RowsAffected
+	}
+
+	_, err = session.Execute(r.Context(), language.CommitCommand, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rowsAffectedResponse{RowsAffected: inserted})
+}
+
+// insertStatement returns the INSERT statement of the row and the arguments of its parameters. Numbers are converted
+// to the type of their field.
+func insertStatement(schema *engine.TableSchema, row map[string]interface{}) (string, []interface{}, error) {
+	var names, params []string
+	var args []interface{}
+
+	// the fields are inserted in the order of the table, so the statement is the same for every order of the keys
+	for _, f := range schema.Fields {
+		val, ok := row[f.Name]
+		if !ok {
+			continue
+		}
+
+		arg, err := fieldArg(f, val)
+		if err != nil {
+			return "", nil, err
+		}
+
+		names = append(names, f.Name)
+		args = append(args, arg)
+		params = append(params, fmt.Sprintf("$%d", len(args)))
+	}
+
+	if len(names) != len(row) {
+		for name := range row {
+			if _, ok := fieldOf(schema, name); !ok {
+				return "", nil, badRequest(`field "%s" does not exist on table "%s"`, name, schema.Name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return "", nil, badRequest("rows must have at least one field")
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", schema.Name, strings.Join(names, ", "), strings.Join(params, ", "))
+
+	return statement, args, nil
+}
+
+// fieldArg returns the argument for the field from its JSON value.
+func fieldArg(f backend.Field, val interface{}) (interface{}, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return jsonArg(val)
+	}
+
+	switch f.Type {
+	case backend.PrimitiveInt:
+		i, err := n.Int64()
+		if err != nil {
+			return nil, badRequest("%s must be an integer", f.Name)
+		}
+
+		return i, nil
+	case backend.PrimitiveFloat:
+		fl, err := n.Float64()
+		if err != nil {
+			return nil, badRequest("%s must be a number", f.Name)
+		}
+
+		return fl, nil
+	}
+
+	return jsonArg(val)
+}
+
+// fieldOf returns the field of the table with the name.
+func fieldOf(schema *engine.TableSchema, name string) (backend.Field, bool) {
+	for _, f := range schema.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return backend.Field{}, false
+}
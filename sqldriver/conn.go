@@ -0,0 +1,221 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// conn is a connection to the database, which runs its statements in a session of the engine. release is called once
+// it is closed, if it is not nil.
+type conn struct {
+	session *engine.Session
+	release func() error
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext parses the query, so it can be run any number of times with arguments bound to its parameters.
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	st, err := c.session.Prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{st: st}, nil
+}
+
+// Close rolls back the transaction of the connection, if it has one.
+func (c *conn) Close() error {
+	err := c.session.Close()
+
+	if c.release != nil {
+		releaseErr := c.release()
+		if err == nil {
+			err = releaseErr
+		}
+	}
+
+	return err
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx begins a transaction with the isolation level of the options. The default level and read committed are
+// backend.IsolationReadCommitted, while snapshot and repeatable read are backend.IsolationSnapshot. Other levels and
+// read only transactions are not supported.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly {
+		return nil, fmt.Errorf("read only transactions are not supported")
+	}
+
+	var isolation backend.IsolationLevel
+
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelReadCommitted:
+		isolation = backend.IsolationReadCommitted
+	case sql.LevelSnapshot, sql.LevelRepeatableRead:
+		isolation = backend.IsolationSnapshot
+	default:
+		return nil, fmt.Errorf("isolation level %s is not supported", sql.IsolationLevel(opts.Isolation))
+	}
+
+	_, err := c.session.Execute(ctx, language.BeginCommand, &language.BeginArgs{Isolation: isolation})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	st, err := c.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.(*stmt).ExecContext(ctx, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	st, err := c.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.(*stmt).QueryContext(ctx, args)
+}
+
+// stmt is a prepared statement of a connection.
+type stmt struct {
+	st *engine.Stmt
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.st.NumParams
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	params, err := engineArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.st.Exec(ctx, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return execResult{rowsAffected: int64(result.RowsAffected)}, nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+// QueryContext runs the statement, reading the rows of a SELECT statement as they are scanned. Any other statement is
+// run to completion and has no rows.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	params, err := engineArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.st.Command() != language.SelectCommand {
+		result, err := s.st.Exec(ctx, params...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rows{columns: result.Columns, values: result.Rows}, nil
+	}
+
+	cursor, err := s.st.Query(ctx, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{columns: cursor.Columns, cursor: cursor}, nil
+}
+
+// namedValues returns the values as positional arguments.
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return named
+}
+
+// engineArgs returns the arguments of the parameters of a statement of the engine, in order. Byte slices are strings
+// and times are strings in the RFC 3339 format, since the database has no types for them. Named arguments are not
+// supported.
+func engineArgs(args []driver.NamedValue) ([]interface{}, error) {
+	params := make([]interface{}, len(args))
+
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named argument %s is not supported", arg.Name)
+		}
+
+		switch v := arg.Value.(type) {
+		case []byte:
+			params[i] = string(v)
+		case time.Time:
+			params[i] = v.Format(time.RFC3339Nano)
+		default:
+			params[i] = v
+		}
+	}
+
+	return params, nil
+}
+
+// tx is the transaction of the session of a connection.
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	_, err := t.conn.session.Execute(context.Background(), language.CommitCommand, nil)
+
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.conn.session.Execute(context.Background(), language.RollbackCommand, &language.RollbackArgs{})
+
+	return err
+}
+
+// execResult is the result of a statement run with Exec. The database has no ids that are generated on insert.
+type execResult struct {
+	rowsAffected int64
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("LastInsertId is not supported")
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,212 @@
+// Package sqldriver registers the database as a database/sql driver named "simplesql", so it can be used through the
+// standard library:
+//
+//	db, err := sql.Open("simplesql", "dir=./database")
+//
+// The data source name is a list of key=value pairs separated by spaces. dir is the directory that the database files
+// are stored in, which is "database" if it is not given. database is the database that connections start in, which
+// is backend.DefaultDatabase if it is not given. It must already exist, and can be changed with USE.
+//
+// Every connection is a session of the engine of its data directory, which is shared by every database opened with
+// the driver whose data source name has the same dir, whatever database it starts in. Databases of different
+// directories can be open at the same time. A directory can only be used by one engine of a process, so it should not
+// also be opened with engine.New while the driver uses it. The engine is cleaned up once the last database of its
+// directory is closed.
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Dojo456/simple-sql-db/engine"
+	"github.com/Dojo456/simple-sql-db/engine/language"
+)
+
+// DriverName is the name the driver is registered with.
+const DriverName = "simplesql"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver of the database.
+type Driver struct{}
+
+// Open returns a new connection to the database of the data source name. Its engine is released once the connection
+// is closed.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cn, err := c.Connect(context.Background())
+	if err != nil {
+		_ = c.(*connector).Close()
+		return nil, err
+	}
+
+	cn.(*conn).release = c.(*connector).Close
+
+	return cn, nil
+}
+
+// OpenConnector parses the data source name and returns a connector to its database, which holds on to the engine
+// until it is closed.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	dir, database, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := acquireEngine(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connector{driver: d, engine: e, dir: dir, database: database}, nil
+}
+
+// parseDSN returns the data directory and the database of the data source name. The database is empty if it is not
+// given.
+func parseDSN(dsn string) (dir string, database string, err error) {
+	dir = "database"
+
+	for _, pair := range strings.Fields(dsn) {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return "", "", fmt.Errorf("invalid data source name %q: expecting key=value", pair)
+		}
+
+		switch key {
+		case "dir":
+			dir = val
+		case "database":
+			database = val
+		default:
+			return "", "", fmt.Errorf("invalid data source name: unknown key %s", key)
+		}
+	}
+
+	if dir == "" {
+		return "", "", fmt.Errorf("invalid data source name: dir can not be empty")
+	}
+
+	return filepath.Clean(dir), database, nil
+}
+
+// sharedEngine is an engine of the driver, with the number of connectors that use it.
+type sharedEngine struct {
+	engine *engine.SQLEngine
+	refs   int
+}
+
+// sharedEngines are the engines of the driver, by the absolute path of their data directory.
+var (
+	sharedMu      sync.Mutex
+	sharedEngines = map[string]*sharedEngine{}
+)
+
+// engineKey returns the key of the engine of the data directory in sharedEngines, which is the same for every path of
+// the directory.
+func engineKey(dir string) (string, error) {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("could not find data directory: %w", err)
+	}
+
+	return key, nil
+}
+
+// acquireEngine returns the engine of the data directory, creating it if it does not exist yet. releaseEngine must be
+// called once it is no longer used.
+func acquireEngine(dir string) (*engine.SQLEngine, error) {
+	key, err := engineKey(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if shared, ok := sharedEngines[key]; ok {
+		shared.refs++
+
+		return shared.engine, nil
+	}
+
+	e, err := engine.New(context.Background(), engine.WithDataDir(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	sharedEngines[key] = &sharedEngine{engine: e, refs: 1}
+
+	return e, nil
+}
+
+// releaseEngine releases the engine of the data directory, which is cleaned up if nothing else uses it.
+func releaseEngine(dir string) error {
+	key, err := engineKey(dir)
+	if err != nil {
+		return err
+	}
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	shared, ok := sharedEngines[key]
+	if !ok {
+		return fmt.Errorf("no database in %s is open", dir)
+	}
+
+	shared.refs--
+	if shared.refs != 0 {
+		return nil
+	}
+
+	delete(sharedEngines, key)
+
+	return shared.engine.Cleanup()
+}
+
+// connector opens connections to the engine. It is closed by sql.DB.Close.
+type connector struct {
+	driver   *Driver
+	engine   *engine.SQLEngine
+	dir      string
+	database string
+	once     sync.Once
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	session := c.engine.NewSession()
+
+	if c.database != "" {
+		_, err := session.Execute(ctx, language.UseCommand, &language.UseArgs{DatabaseName: c.database})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &conn{session: session}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *connector) Close() error {
+	var err error
+
+	c.once.Do(func() {
+		err = releaseEngine(c.dir)
+	})
+
+	return err
+}
@@ -0,0 +1,230 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// openTestDB opens a database of the driver in the directory, which is closed when the test ends.
+func openTestDB(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(DriverName, "dir="+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := db.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
+
+func exec(t *testing.T, db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, query string, args ...interface{}) int64 {
+	t.Helper()
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func countRows(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+
+	err := db.QueryRow("SELECT COUNT(*) FROM people").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+// TestRoundTrip inserts rows with arguments for their placeholders, and scans them back, with and without NULLs.
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := sql.Open(DriverName, "dir="+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, db, "CREATE TABLE people (name string, age int, score float, member bool)")
+
+	insert, err := db.Prepare("INSERT INTO people VALUES (?, ?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values that look like syntax are only values once they are arguments
+	names := []string{"ann", `it's "quoted", (1, 2)`, "NULL", "?"}
+	for i, name := range names {
+		result, err := insert.Exec(name, i, 1.5*float64(i), i%2 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, _ := result.RowsAffected(); n != 1 {
+			t.Errorf("inserted %d rows, want 1", n)
+		}
+	}
+
+	if err := insert.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, db, "INSERT INTO people VALUES ($1, $2, $3, $4)", nil, nil, nil, nil)
+
+	if n := exec(t, db, "UPDATE people SET score = $2 WHERE age = $1", 1, 10); n != 1 {
+		t.Errorf("updated %d rows, want 1", n)
+	}
+
+	rows, err := db.Query("SELECT name, age, score, member FROM people WHERE age >= ? OR age IS NULL ORDER BY age", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type person struct {
+		name   sql.NullString
+		age    sql.NullInt64
+		score  sql.NullFloat64
+		member sql.NullBool
+	}
+
+	var got []person
+	for rows.Next() {
+		var p person
+
+		err := rows.Scan(&p.name, &p.age, &p.score, &p.member)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []person{
+		{sql.NullString{String: names[1], Valid: true}, sql.NullInt64{Int64: 1, Valid: true}, sql.NullFloat64{Float64: 10, Valid: true}, sql.NullBool{Bool: false, Valid: true}},
+		{sql.NullString{String: "NULL", Valid: true}, sql.NullInt64{Int64: 2, Valid: true}, sql.NullFloat64{Float64: 3, Valid: true}, sql.NullBool{Bool: true, Valid: true}},
+		{sql.NullString{String: "?", Valid: true}, sql.NullInt64{Int64: 3, Valid: true}, sql.NullFloat64{Float64: 4.5, Valid: true}, sql.NullBool{Bool: false, Valid: true}},
+		{},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("selected %d rows, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got row %d %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// NULL can be scanned into a pointer, but not into a plain value
+	var age *int64
+
+	err = db.QueryRow("SELECT age FROM people WHERE name IS NULL").Scan(&age)
+	if err != nil || age != nil {
+		t.Errorf("got %v %v, want a nil age", age, err)
+	}
+
+	var plain int64
+	if err := db.QueryRow("SELECT age FROM people WHERE name IS NULL").Scan(&plain); err == nil {
+		t.Error("scanned NULL into an int64")
+	}
+
+	for _, c := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"SELECT * FROM people WHERE age = ?", []interface{}{"not a number"}},
+		{"SELECT * FROM people WHERE age = ? AND name = $2", []interface{}{1, "ann"}},
+		{"SELECT * FROM people WHERE age = ?", nil},
+		{"SELECT * FROM people WHERE age = ?", []interface{}{sql.Named("age", 1)}},
+	} {
+		if _, err := db.Exec(c.query, c.args...); err == nil {
+			t.Errorf("%s with %v did not fail", c.query, c.args)
+		}
+	}
+
+	// the rows are still there once every database of the directory is closed and it is opened again
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countRows(t, openTestDB(t, dir)); n != len(names)+1 {
+		t.Errorf("counted %d rows after opening the database again, want %d", n, len(names)+1)
+	}
+}
+
+func TestTx(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+
+	exec(t, db, "CREATE TABLE people (name string, age int)")
+	exec(t, db, "INSERT INTO people VALUES (?, ?)", "ann", 1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, tx, "INSERT INTO people VALUES (?, ?)", "bob", 2)
+	exec(t, tx, "DELETE FROM people WHERE name = ?", "ann")
+
+	// the changes of the transaction are only seen by it until it is committed
+	var name string
+	if err := tx.QueryRow("SELECT name FROM people").Scan(&name); err != nil || name != "bob" {
+		t.Errorf("got %s %v in the transaction, want bob", name, err)
+	}
+
+	if n := countRows(t, db); n != 1 {
+		t.Errorf("counted %d rows outside of the transaction, want 1", n)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.QueryRow("SELECT name FROM people").Scan(&name); err != nil || name != "ann" {
+		t.Errorf("got %s %v after rolling back, want ann", name, err)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, tx, "INSERT INTO people VALUES (?, ?)", "bob", 2)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countRows(t, db); n != 2 {
+		t.Errorf("counted %d rows after committing, want 2", n)
+	}
+
+	if _, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("began a read only transaction")
+	}
+}
@@ -0,0 +1,94 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/Dojo456/simple-sql-db/backend"
+	"github.com/Dojo456/simple-sql-db/engine"
+)
+
+// rows are the rows of a query, read from the cursor of a SELECT statement, or from values for any other statement.
+//
+// The values of the engine are already driver values: a string, int64, float64 or bool for a column of type
+// backend.PrimitiveString, backend.PrimitiveInt, backend.PrimitiveFloat or backend.PrimitiveBool, or nil if it is
+// NULL, so they are scanned into Go types by database/sql.
+type rows struct {
+	columns []engine.Column
+	cursor  *engine.Cursor
+	values  [][]interface{}
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.Name
+	}
+
+	return names
+}
+
+func (r *rows) Close() error {
+	if r.cursor == nil {
+		return nil
+	}
+
+	return r.cursor.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	var row []interface{}
+
+	if r.cursor != nil {
+		if !r.cursor.Next() {
+			if err := r.cursor.Err(); err != nil {
+				return err
+			}
+
+			return io.EOF
+		}
+
+		row = r.cursor.Row()
+	} else {
+		if len(r.values) == 0 {
+			return io.EOF
+		}
+
+		row = r.values[0]
+		r.values = r.values[1:]
+	}
+
+	for i, val := range row {
+		dest[i] = val
+	}
+
+	return nil
+}
+
+// ColumnTypeScanType returns the Go type that the values of the column are scanned as.
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.columns[index].Type {
+	case backend.PrimitiveString:
+		return reflect.TypeOf("")
+	case backend.PrimitiveInt:
+		return reflect.TypeOf(int64(0))
+	case backend.PrimitiveFloat:
+		return reflect.TypeOf(float64(0))
+	case backend.PrimitiveBool:
+		return reflect.TypeOf(false)
+	}
+
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+// ColumnTypeDatabaseTypeName returns the name of the type of the column, such as "INT".
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(string(r.columns[index].Type))
+}
+
+// ColumnTypeNullable returns that every column can be NULL.
+func (r *rows) ColumnTypeNullable(index int) (nullable bool, ok bool) {
+	return true, true
+}